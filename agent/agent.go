@@ -8,12 +8,11 @@ import (
 	"io"
 	"log"
 	"net/http"
-	"os"
 	"strings"
 	"sync"
+	"sync/atomic"
 	"time"
 
-	"github.com/chromedp/cdproto/cdp"
 	"github.com/chromedp/cdproto/domstorage"
 	"github.com/chromedp/cdproto/network"
 	"github.com/chromedp/chromedp"
@@ -32,74 +31,138 @@ type Agent struct {
 	// BaseUrl specifies the URL of the cadview instance with a trailing
 	// slash, like "https://cadview.somepsap.org/".
 	BaseUrl string
+	// Credentials supplies the login credentials each time the agent logs
+	// in. If nil, Username and Password are used.
+	Credentials CredentialProvider
 	// Username is the login user credential for the cadview instance.
+	//
+	// Deprecated: Set Credentials to a StaticCredentials, or to a provider
+	// which keeps the password out of memory between logins.
 	Username string
 	// Password is the login password credential for the cadview instance.
+	// It is not copied by MakeCopy.
+	//
+	// Deprecated: Set Credentials to a StaticCredentials, or to a provider
+	// which keeps the password out of memory between logins.
 	Password string
 	// FDID is the ORI/FDID associated with the login credentials.
 	FDID string
+	// Profile describes the login page. If nil, the profile registered for
+	// BaseUrl with RegisterLoginProfile is used, or DefaultLoginProfile.
+	Profile *LoginProfile
+	// MFA answers a second factor challenge during login, if CadView asks
+	// for one. Logins which ask for a second factor fail without it.
+	MFA MFAProvider
 	// CDP is the URL of a remote devtools instance, usually on port :9222.
 	// If this variable is not empty, a remote rather than local instance
 	// will be utilized.
 	CDP string
-
-	reqMap  map[string]network.RequestID
-	urlMap  map[string]string
-	bodyMap map[string][]byte
-	attr    map[string]string
-	auth    OidcObj
+	// Remote, if set, logs in within a tab of a remote browser which is
+	// kept open and watched by the session. It takes precedence over CDP.
+	Remote *RemoteSession
+	// Launch controls the browser used to log in.
+	Launch LaunchOptions
+	// TokenAudience, if set, is the audience required of the access token.
+	TokenAudience string
+	// VerifyToken checks the access token signature against the signing
+	// keys of the instance after login, when they can be retrieved.
+	VerifyToken bool
+	// Capture records the browser traffic, for HAR exports and looking up
+	// responses. If nil, Init sets a Capture with the default filters.
+	Capture *Capture
+	// Observer, if set, is notified of requests, logins and pings.
+	Observer Observer
+	// Tagger, if set, annotates the calls returned by GetActiveCalls,
+	// GetClearedCalls and GetCallDetails, such as with a geo.Set.
+	Tagger Tagger
+	// DiagnosticsDir, if set, is where a screenshot, the page HTML and the
+	// browser console and network logs are saved when a login fails.
+	DiagnosticsDir string
+
+	attr   map[string]string
+	auth   OidcObj
+	status Status
+	trace  loginTrace
+	// From the login traffic, or retrieved when first asked for
+	settings *UserSettings
+	oris     []ORIObj
+	browser  *browserSession
 
 	initialized bool
-	cancelled   bool
-	wg          *sync.WaitGroup
-	l           sync.Mutex
+	cancelled   atomic.Bool
+	// The login in progress for Reauthorize, and when one last succeeded
+	reauth   *reauthCall
+	reauthAt time.Time
+	wg       *sync.WaitGroup
+	l        sync.Mutex
+}
+
+// reauthCall is a login shared by the callers of Reauthorize.
+type reauthCall struct {
+	done chan struct{}
+	err  error
 }
 
 // Init logs in and initializes the agent
-func (a *Agent) Init() error {
+func (a *Agent) Init() (err error) {
 	if a.initialized {
 		return fmt.Errorf("already initialized")
 	}
+	a.setBrowser(BrowserLoggingIn)
+	defer func() {
+		a.recordLogin(err)
+		switch {
+		case err != nil:
+			a.setBrowser(BrowserFailed)
+		case a.keepBrowser():
+			a.setBrowser(BrowserOpen)
+		default:
+			a.setBrowser(BrowserClosed)
+		}
+		if a.Observer != nil {
+			a.Observer.ObserveLogin(err)
+		}
+	}()
+
+	// Fetched for each login and not kept
+	creds, err := a.credentials()
+	if err != nil {
+		return err
+	}
 
 	// Initialize all maps to avoid NPE
-	a.reqMap = map[string]network.RequestID{}
-	a.urlMap = map[string]string{}
-	a.bodyMap = map[string][]byte{}
 	a.attr = map[string]string{}
+	a.l.Lock()
+	a.trace = loginTrace{step: loginStepLoad, netByID: map[network.RequestID]*netEntry{}}
+	a.l.Unlock()
 	if a.wg == nil {
 		a.wg = &sync.WaitGroup{}
 	}
 
-	var _ctx context.Context
-	var _cancel context.CancelFunc
-	var cancel context.CancelFunc
-
-	if a.CDP != "" {
-		log.Printf("INFO: Remote devtools URL %s", a.CDP)
-		_ctx, cancel = chromedp.NewRemoteAllocator(context.Background(), a.CDP)
-		defer cancel()
-	} else {
-		opts := append(
-			chromedp.DefaultExecAllocatorOptions[:],
-			chromedp.UserDataDir(os.TempDir()),
-			chromedp.Flag("enable-privacy-sandbox-ads-apis", true),
-		)
-		_ctx, cancel = chromedp.NewExecAllocator(context.Background(), opts...)
-		defer cancel()
-	}
-	if a.Debug {
-		_ctx, _cancel = chromedp.NewContext(
-			_ctx,
-			chromedp.WithDebugf(log.Printf),
-		)
-	} else {
-		_ctx, _cancel = chromedp.NewContext(
-			_ctx,
-		)
+	_ctx, closeBrowser, err := a.newBrowser()
+	if err != nil {
+		return err
 	}
-	defer _cancel()
+	defer func() {
+		if err == nil && a.keepBrowser() {
+			a.Close()
+			a.l.Lock()
+			a.browser = &browserSession{ctx: _ctx, close: closeBrowser}
+			a.l.Unlock()
+			if a.Remote != nil {
+				a.Remote.attach(a)
+			}
+			return
+		}
+		closeBrowser()
+	}()
 
-	ctx, cancel := context.WithTimeout(_ctx, 60*time.Second)
+	timeout := 60 * time.Second
+	if a.MFA != nil {
+		// Allow for a person to enter a code
+		timeout += 3 * time.Minute
+	}
+	ctx, cancel := context.WithTimeout(_ctx, timeout)
 	defer cancel()
 
 	// ensure that the browser process is started
@@ -108,106 +171,72 @@ func (a *Agent) Init() error {
 		return err
 	}
 
-	// Listen to all network events and save content for whatever comes in
+	// Record the traffic for lookups and the HAR, for as long as the
+	// browser is open
+	if a.Capture == nil {
+		a.Capture = &Capture{Debug: a.Debug}
+	}
+	if err := a.Capture.Listen(_ctx); err != nil {
+		return err
+	}
+
+	// Listen to all network events for login diagnostics
 	chromedp.ListenTarget(ctx, func(v interface{}) {
-		if a.cancelled {
+		if a.cancelled.Load() {
 			return
 		}
-		switch ev := v.(type) {
-		case *network.EventRequestWillBeSent:
-			//log.Printf("network.EventRequestWillBeSent")
-			if unwantedTraffic(ev.Request.URL) {
-				break
-			}
-			if a.Debug {
+		a.l.Lock()
+		a.traceEvent(v)
+		a.l.Unlock()
+		if a.Debug {
+			switch ev := v.(type) {
+			case *network.EventRequestWillBeSent:
 				log.Printf("EventRequestWillBeSent: %v: %v", ev.RequestID, ev.Request.URL)
-			}
-			a.l.Lock()
-			a.reqMap[ev.Request.URL] = ev.RequestID
-			a.l.Unlock()
-		case *network.EventResponseReceived:
-			//log.Printf("network.EventResponseReceived")
-			if unwantedTraffic(ev.Response.URL) {
-				break
-			}
-
-			if a.Debug {
+			case *network.EventResponseReceived:
 				log.Printf("EventResponseReceived: %v: %v", ev.RequestID, ev.Response.URL)
 				log.Printf("EventResponseReceived: status = %d, headers = %#v", ev.Response.Status, ev.Response.Headers)
-			}
-			a.l.Lock()
-			a.urlMap[ev.RequestID.String()] = ev.Response.URL
-			a.l.Unlock()
-		case *network.EventLoadingFinished:
-			//log.Printf("network.EventLoadingFinished")
-			if a.Debug {
+			case *network.EventLoadingFinished:
 				log.Printf("EventLoadingFinished: %v", ev.RequestID)
 			}
-			if a.cancelled {
-				return
-			}
-			a.wg.Add(1)
-			go func() {
-				c := chromedp.FromContext(ctx)
-				body, err := network.GetResponseBody(ev.RequestID).Do(cdp.WithExecutor(ctx, c.Target))
-				if err != nil {
-					a.wg.Done()
-					return
-				}
-
-				a.l.Lock()
-				url := a.urlMap[ev.RequestID.String()]
-				a.bodyMap[url] = body
-				a.l.Unlock()
-
-				if a.Debug {
-					log.Printf("%s: %s", url, string(body))
-				}
-
-				a.wg.Done()
-			}()
 		}
 	})
 
 	// Use a Chrome web browser to log in to the interface and obtain the
 	// appropriate authentication token from local storage.
 
+	profile := a.LoginProfile()
 	if err := chromedp.Run(ctx,
-		chromedp.Navigate(a.BaseUrl+"newworld.cadview/account/login"),
+		chromedp.Navigate(a.BaseUrl+profile.LoginPath),
 		chromedp.Tasks{
 			// Login sequence
 			//a.waitForLoadEvent(ctx),
 
-			chromedp.ActionFunc(func(ctx context.Context) error {
-				log.Printf("INFO: Attempting to load page")
-				return nil
-			}),
+			a.loginStep(loginStepLoad, "Attempting to load page"),
 
 			//chromedp.WaitVisible("//div.signin-text"),
 
+			a.loginStep(loginStepUsername, "Filling out login form"),
+			chromedp.SendKeys(profile.UsernameSelector, creds.Username),
+			a.loginStep(loginStepPassword, ""),
+			chromedp.SendKeys(profile.PasswordSelector, creds.Password),
 			chromedp.ActionFunc(func(ctx context.Context) error {
-				log.Printf("INFO: Filling out login form")
+				// Only needed for the form
+				creds = Credentials{}
 				return nil
 			}),
 
-			chromedp.SendKeys("//input[@id='Username']", a.Username),
-			chromedp.SendKeys("//input[@id='passwordField']", a.Password),
+			a.loginStep(loginStepSubmit, "Attempting to submit form"),
+			chromedp.Submit(profile.SubmitSelector),
 
-			chromedp.ActionFunc(func(ctx context.Context) error {
-				log.Printf("INFO: Attempting to submit form")
-				return nil
-			}),
-
-			chromedp.Submit("//button[@id='loginbtn']"),
+			a.loginStep(loginStepAwait, "Attempting to wait for dashboard to be visible"),
 
+			// Answer a second factor challenge, if one is shown, and wait
+			// for the dashboard
 			chromedp.ActionFunc(func(ctx context.Context) error {
-				log.Printf("INFO: Attempting to wait for dashboard to be visible")
-				return nil
+				return a.awaitLogin(ctx, profile)
 			}),
 
-			// Don't continue until the dashboard is visible
-			chromedp.WaitVisible(`//*[contains(., 'Dashboard')]`),
-
+			a.loginStep(loginStepToken, ""),
 			chromedp.ActionFunc(func(ctx context.Context) error {
 				// if the default profile is not loaded,
 				// it just gets the entries added by the navigation action in the previous step.
@@ -215,31 +244,14 @@ func (a *Agent) Init() error {
 				// and this action gets nothing.
 				// in this case, it's better to listen to the DOMStorage events.
 				log.Printf("INFO: Security Origin = %s", "https://"+strings.Split(a.BaseUrl, "/")[2])
-				entries, err := domstorage.GetDOMStorageItems(&domstorage.StorageID{
-					StorageKey:     domstorage.SerializedStorageKey("https://" + strings.Split(a.BaseUrl, "/")[2] + "/"),
-					IsLocalStorage: true,
-				}).Do(ctx)
-
-				if err != nil {
-					log.Printf("ERR: domstorage: %s", err.Error())
-					return err
-				}
-
-				//log.Printf("localStorage entries: %#v", entries)
-				for _, entry := range entries {
-					if strings.HasPrefix(entry[0], "oidc.user:") {
-						var oidc OidcObj
-						err = json.Unmarshal([]byte(entry[1]), &oidc)
-						//log.Printf("JSON user obj : %s", entry[1])
-						if err != nil {
-							log.Printf("ERR: Deserializing OIDC token: %s", err.Error())
-						} else {
-							a.auth = oidc
-							log.Printf("INFO: oidc.expiresat = %d, oidc.auth_time = %d", a.auth.ExpiresAt, a.auth.Profile.AuthTime)
-							if a.Debug {
-								log.Printf("DEBUG: %#v", a.auth)
-							}
-						}
+				oidc, found, err := a.storedAuth(ctx, profile.StorageKeyPrefix)
+				if found && err == nil {
+					a.l.Lock()
+					a.auth = oidc
+					a.l.Unlock()
+					log.Printf("INFO: oidc.expiresat = %d, oidc.auth_time = %d", oidc.ExpiresAt, oidc.Profile.AuthTime)
+					if a.Debug {
+						log.Printf("DEBUG: %#v", oidc)
 					}
 				}
 
@@ -248,29 +260,79 @@ func (a *Agent) Init() error {
 		},
 	); err != nil {
 		log.Printf("ERR: Failed to login: %s", err.Error())
-		return err
+		// The login context may have expired, so use the browser context
+		le := a.diagnoseLogin(_ctx, err)
+		log.Printf("ERR: Login failure cause: %s (%s)", le.Cause, le.Step)
+		if le.Dir != "" {
+			log.Printf("INFO: Login diagnostics saved to %s", le.Dir)
+		}
+		return le
 	}
 
 	if a.Debug {
 		log.Printf("DEBUG: Wait for all data to be received.")
 	}
-	a.wg.Wait()
+	a.Capture.Wait()
+
+	// Refuse tokens which CadView would reject, or which are not its own
+	if err := a.checkToken(); err != nil {
+		log.Printf("ERR: Login token: %s", err.Error())
+		return err
+	}
+	if a.VerifyToken {
+		if err := a.verifyToken(); err != nil {
+			log.Printf("ERR: Login token: %s", err.Error())
+			return err
+		}
+	}
+	a.loadUserInfo()
 
 	if a.Debug {
 		log.Printf("attr : %#v", a.attr)
-		log.Printf("urlMap : %#v", a.urlMap)
-		log.Printf("/api/CadView/GetAllUserSettings : %s", string(a.bodyMap[a.BaseUrl+"NewWorld.CadView/api/CadView/GetAllUserSettings"]))
+		settings, _ := a.Capture.Body("*/NewWorld.CadView/api/CadView/GetAllUserSettings")
+		log.Printf("/api/CadView/GetAllUserSettings : %s", string(settings))
 	}
 
 	if a.Debug {
-		log.Printf("auth : %#v", a.auth)
+		log.Printf("auth : %#v", a.GetAuth())
 	}
 
+	a.l.Lock()
 	a.initialized = true
+	a.l.Unlock()
 
 	return nil
 }
 
+// storedAuth reads the OIDC token which the CadView single page app keeps
+// in localStorage, under a key starting with prefix.
+func (a *Agent) storedAuth(ctx context.Context, prefix string) (OidcObj, bool, error) {
+	var oidc OidcObj
+	entries, err := domstorage.GetDOMStorageItems(&domstorage.StorageID{
+		StorageKey:     domstorage.SerializedStorageKey("https://" + strings.Split(a.BaseUrl, "/")[2] + "/"),
+		IsLocalStorage: true,
+	}).Do(ctx)
+	if err != nil {
+		log.Printf("ERR: domstorage: %s", err.Error())
+		return oidc, false, err
+	}
+
+	//log.Printf("localStorage entries: %#v", entries)
+	found := false
+	for _, entry := range entries {
+		if strings.HasPrefix(entry[0], prefix) {
+			//log.Printf("JSON user obj : %s", entry[1])
+			err = json.Unmarshal([]byte(entry[1]), &oidc)
+			if err != nil {
+				log.Printf("ERR: Deserializing OIDC token: %s", err.Error())
+			} else {
+				found = true
+			}
+		}
+	}
+	return oidc, found, err
+}
+
 func (a *Agent) Run() {
 	go func() {
 		for {
@@ -281,9 +343,12 @@ func (a *Agent) Run() {
 			if err != nil {
 				log.Printf("Run(): %s", err.Error())
 			}
+			if a.Observer != nil {
+				a.Observer.ObservePing(err)
+			}
 			for i := 0; i < 15; i++ {
 				time.Sleep(time.Second)
-				if a.cancelled {
+				if a.cancelled.Load() {
 					return
 				}
 			}
@@ -390,16 +455,20 @@ func (a *Agent) RetrieveCADCall(call CallObj) (CADCall, error) {
 
 // authorizedGet uses the current authentication mechanism to
 func (a *Agent) authorizedGet(url string) ([]byte, error) {
-	if a.auth.TokenType == "" {
+	auth := a.GetAuth()
+	if auth.TokenType == "" {
 		return []byte{}, fmt.Errorf("not authenticated")
 	}
+	if err := a.checkToken(); err != nil {
+		return []byte{}, err
+	}
 
 	client := &http.Client{}
 	req, err := http.NewRequest("GET", url, nil)
 	if err != nil {
 		return []byte{}, err
 	}
-	req.Header.Add("Authorization", a.auth.TokenType+" "+a.auth.AccessToken)
+	req.Header.Add("Authorization", auth.TokenType+" "+auth.AccessToken)
 	if req.Body != nil {
 		defer req.Body.Close()
 	}
@@ -408,8 +477,12 @@ func (a *Agent) authorizedGet(url string) ([]byte, error) {
 		log.Printf("DEBUG: authorizedGet: Headers : %#v", req.Header)
 	}
 
+	start := time.Now()
 	res, err := client.Do(req)
 	if err != nil {
+		if a.Observer != nil {
+			a.Observer.ObserveRequest(endpointName(url), 0, time.Since(start))
+		}
 		return []byte{}, err
 	}
 	body, err := io.ReadAll(res.Body)
@@ -417,6 +490,9 @@ func (a *Agent) authorizedGet(url string) ([]byte, error) {
 		defer res.Body.Close()
 	}
 	defer res.Body.Close()
+	if a.Observer != nil {
+		a.Observer.ObserveRequest(endpointName(url), res.StatusCode, time.Since(start))
+	}
 
 	// Check for not being authorized
 	if err == nil {
@@ -432,10 +508,14 @@ func (a *Agent) SetAuth(auth OidcObj) {
 	if a.Debug {
 		log.Printf("SetAuth: %#v", auth)
 	}
+	a.l.Lock()
+	defer a.l.Unlock()
 	a.auth = auth
 }
 
 func (a *Agent) GetAuth() OidcObj {
+	a.l.Lock()
+	defer a.l.Unlock()
 	return a.auth
 }
 
@@ -462,15 +542,34 @@ func (a *Agent) waitForLoadEvent(ctx context.Context) chromedp.Action {
 }
 */
 
+// MakeCopy returns a new agent with the settings of a, which has not logged
+// in. The copy records its own traffic, in a Capture with the same
+// settings as that of a. The copy logs in with the credentials of a; if those are the
+// deprecated Username and Password fields, it reads them from a rather than
+// holding its own copy of the password.
 func (a *Agent) MakeCopy() *Agent {
+	creds := a.Credentials
+	if creds == nil && a.HasCredentials() {
+		creds = agentCredentials{a}
+	}
 	return &Agent{
-		Debug:    a.Debug,
-		BaseUrl:  a.BaseUrl,
-		Username: a.Username,
-		Password: a.Password,
-		FDID:     a.FDID,
-		CDP:      a.CDP,
-		wg:       a.wg,
+		Debug:          a.Debug,
+		BaseUrl:        a.BaseUrl,
+		Username:       a.Username,
+		Credentials:    creds,
+		Profile:        a.Profile,
+		MFA:            a.MFA,
+		FDID:           a.FDID,
+		CDP:            a.CDP,
+		Launch:         a.Launch,
+		Remote:         a.Remote,
+		Capture:        a.Capture.empty(),
+		TokenAudience:  a.TokenAudience,
+		VerifyToken:    a.VerifyToken,
+		Observer:       a.Observer,
+		Tagger:         a.Tagger,
+		DiagnosticsDir: a.DiagnosticsDir,
+		wg:             a.wg,
 	}
 }
 
@@ -479,12 +578,86 @@ func (a *Agent) WaitGroup() *sync.WaitGroup {
 }
 
 func (a *Agent) Cancel() {
-	a.cancelled = true
+	a.cancelled.Store(true)
+}
+
+// Reauthorize logs in again with a copy of the agent and transfers the new
+// token, for use when requests start failing with ErrNotAuthorized. Callers
+// which ask while a login is in progress share its result rather than
+// logging in again.
+func (a *Agent) Reauthorize() error {
+	return a.ReauthorizeSince(time.Now())
+}
+
+// ReauthorizeSince is Reauthorize for a request which failed after starting
+// at since. It does not log in again if a login has succeeded since then,
+// as the request may have used the old token.
+func (a *Agent) ReauthorizeSince(since time.Time) error {
+	a.l.Lock()
+	if c := a.reauth; c != nil {
+		a.l.Unlock()
+		<-c.done
+		return c.err
+	}
+	if a.reauthAt.After(since) {
+		a.l.Unlock()
+		return nil
+	}
+	c := &reauthCall{done: make(chan struct{})}
+	a.reauth = c
+	a.l.Unlock()
+
+	log.Printf("INFO: Session for %s is no longer authorized, logging in again", a.BaseUrl)
+	c.err = a.reauthorize()
+
+	a.l.Lock()
+	a.reauth = nil
+	if c.err == nil {
+		a.reauthAt = time.Now()
+	}
+	a.l.Unlock()
+	close(c.done)
+	return c.err
+}
+
+func (a *Agent) reauthorize() error {
+	a2 := a.MakeCopy()
+	a.setBrowser(BrowserLoggingIn)
+	err := a2.Init()
+	st := a2.Status()
+	a.l.Lock()
+	a.status.Browser = st.Browser
+	a.status.LastLogin = st.LastLogin
+	a.status.LoginError = st.LoginError
+	a.l.Unlock()
+	if err != nil {
+		return err
+	}
+	a.TransferAuthFrom(a2)
+	if a.Remote != nil {
+		// The session watches a, which takes over the tab
+		a.Remote.detach(a2)
+	}
+	if a2.browser != nil {
+		// Replace the old browser with the new one
+		a.Close()
+		a.l.Lock()
+		a.browser = a2.browser
+		a.status.Browser = BrowserOpen
+		a.l.Unlock()
+	}
+	return nil
 }
 
 func (a *Agent) TransferAuthFrom(a2 *Agent) {
+	a2.l.Lock()
+	auth, settings, oris := a2.auth, a2.settings, a2.oris
+	a2.l.Unlock()
+	a.l.Lock()
+	defer a.l.Unlock()
 	if a.Debug {
-		log.Printf("TransferAuthFrom: %s (old) -> %s (new)", a.auth.AccessToken, a2.auth.AccessToken)
+		log.Printf("TransferAuthFrom: %s (old) -> %s (new)", a.auth.AccessToken, auth.AccessToken)
 	}
-	a.auth = a2.auth
+	a.auth = auth
+	a.settings, a.oris = settings, oris
 }
@@ -1,15 +1,31 @@
 package agent
 
-import "testing"
+import (
+	"errors"
+	"os"
+	"sync"
+	"testing"
+	"time"
+)
 
-func Test_Agent_Refresh(t *testing.T) {
-	a := Agent{
-		Username: DEFAULT_USERNAME,
-		Password: DEFAULT_PASSWORD,
-		BaseUrl:  DEFAULT_URL,
-		FDID:     DEFAULT_FDID,
-		//Debug:    true,
+// liveAgent returns an agent for the CadView instance in CADVIEW_URL and
+// CADVIEW_FDID, logging in with CADVIEW_USERNAME and CADVIEW_PASSWORD, or
+// skips the test when they are unset.
+func liveAgent(t *testing.T) *Agent {
+	creds := EnvCredentials{}
+	if _, err := creds.Credentials(); err != nil || os.Getenv("CADVIEW_URL") == "" || os.Getenv("CADVIEW_FDID") == "" {
+		t.Skip("CADVIEW_URL, CADVIEW_FDID, CADVIEW_USERNAME and CADVIEW_PASSWORD are required for live tests")
+	}
+	return &Agent{
+		Credentials: creds,
+		BaseUrl:     os.Getenv("CADVIEW_URL"),
+		FDID:        os.Getenv("CADVIEW_FDID"),
+		//Debug:       true,
 	}
+}
+
+func Test_Agent_Refresh(t *testing.T) {
+	a := liveAgent(t)
 	err := a.Init()
 	if err != nil {
 		t.Fatalf("ERR: %s", err.Error())
@@ -18,13 +34,7 @@ func Test_Agent_Refresh(t *testing.T) {
 }
 
 func Test_Agent_API(t *testing.T) {
-	a := Agent{
-		Username: DEFAULT_USERNAME,
-		Password: DEFAULT_PASSWORD,
-		BaseUrl:  DEFAULT_URL,
-		FDID:     DEFAULT_FDID,
-		//Debug:    true,
-	}
+	a := liveAgent(t)
 	err := a.Init()
 	if err != nil {
 		t.Fatalf("ERR: Init: %s", err.Error())
@@ -54,3 +64,35 @@ func Test_Agent_API(t *testing.T) {
 		break
 	}
 }
+
+func Test_Reauthorize_Shared(t *testing.T) {
+	a := &Agent{}
+
+	// Requests which started before the last login do not log in again
+	a.reauthAt = time.Now()
+	if err := a.ReauthorizeSince(a.reauthAt.Add(-time.Minute)); err != nil {
+		t.Fatalf("ERR: %s", err.Error())
+	}
+
+	// Callers share the login in progress
+	c := &reauthCall{done: make(chan struct{})}
+	a.reauth = c
+	errs := make(chan error, 5)
+	var wg sync.WaitGroup
+	for i := 0; i < 5; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			errs <- a.ReauthorizeSince(time.Now())
+		}()
+	}
+	c.err = errors.New("login failed")
+	close(c.done)
+	wg.Wait()
+	close(errs)
+	for err := range errs {
+		if err != c.err {
+			t.Fatalf("expected the shared login error, got %v", err)
+		}
+	}
+}
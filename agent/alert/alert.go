@@ -0,0 +1,76 @@
+// Package alert evaluates alerting rules against watcher events and routes
+// matches to notifiers.
+package alert
+
+import (
+	"log"
+	"sync"
+
+	"github.com/dayvillefire/newworld-cadview-agent/agent"
+	"github.com/dayvillefire/newworld-cadview-agent/agent/notify"
+)
+
+// Router delivers alert events to named notifiers.
+type Router struct {
+	// Notifiers maps a notifier name, as referenced by rules, to the
+	// notifier.
+	Notifiers map[string]notify.Notifier
+}
+
+// Route sends an event to the named notifiers, or to every notifier if no
+// names are given. Unknown names and delivery errors are logged.
+func (r *Router) Route(ev agent.Event, names []string) {
+	if r == nil {
+		return
+	}
+	if len(names) == 0 {
+		for name, n := range r.Notifiers {
+			if err := n.Notify(ev); err != nil {
+				log.Printf("ERR: alert: %s: %s", name, err.Error())
+			}
+		}
+		return
+	}
+	for _, name := range names {
+		n, ok := r.Notifiers[name]
+		if !ok {
+			log.Printf("ERR: alert: unknown notifier %s", name)
+			continue
+		}
+		if err := n.Notify(ev); err != nil {
+			log.Printf("ERR: alert: %s: %s", name, err.Error())
+		}
+	}
+}
+
+// once tracks which rules have already fired for each call, so that a rule
+// fires only once per call.
+type once struct {
+	fired map[int64]map[string]bool
+	l     sync.Mutex
+}
+
+// first records that a rule fired for a call, returning false if it had
+// already fired.
+func (o *once) first(callID int64, rule string) bool {
+	o.l.Lock()
+	defer o.l.Unlock()
+	if o.fired == nil {
+		o.fired = map[int64]map[string]bool{}
+	}
+	if o.fired[callID] == nil {
+		o.fired[callID] = map[string]bool{}
+	}
+	if o.fired[callID][rule] {
+		return false
+	}
+	o.fired[callID][rule] = true
+	return true
+}
+
+// forget drops the record of a call once it has closed.
+func (o *once) forget(callID int64) {
+	o.l.Lock()
+	defer o.l.Unlock()
+	delete(o.fired, callID)
+}
@@ -0,0 +1,115 @@
+package alert
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/dayvillefire/newworld-cadview-agent/agent"
+	"github.com/dayvillefire/newworld-cadview-agent/agent/notify"
+)
+
+type recorder []agent.Event
+
+func (r *recorder) Notify(ev agent.Event) error {
+	*r = append(*r, ev)
+	return nil
+}
+
+func Test_KeywordEngine(t *testing.T) {
+	fire := &recorder{}
+	hazmat := &recorder{}
+	e := &KeywordEngine{
+		Rules: []*KeywordRule{
+			{Name: "working-fire", Keywords: []string{"working fire", "MAYDAY"}, Notifiers: []string{"fire"}},
+			{Name: "hazmat", Patterns: []string{`\bUN ?\d{4}\b`}, Notifiers: []string{"hazmat"}},
+			{Name: "police", Keywords: []string{"10-50/PI", "(MVA)"}, Fields: []string{FieldCallType}, Notifiers: []string{"hazmat"}},
+		},
+		Router: &Router{Notifiers: map[string]notify.Notifier{"fire": fire, "hazmat": hazmat}},
+	}
+	if err := e.Compile(); err != nil {
+		t.Fatalf("ERR: Compile: %s", err.Error())
+	}
+
+	call := agent.CallObj{CallID: 1, NatureOfCall: "/SMOKE SHOWING/"}
+	e.Handle(agent.Event{Type: agent.EventNewCall, Call: call})
+	e.Handle(agent.Event{Type: agent.EventNarrative, Call: call, Narrative: &agent.NarrativeObj{Narrative: "E70 reports working fire"}})
+	e.Handle(agent.Event{Type: agent.EventNarrative, Call: call, Narrative: &agent.NarrativeObj{Narrative: "mayday mayday mayday"}})
+	e.Handle(agent.Event{Type: agent.EventNarrative, Call: call, Narrative: &agent.NarrativeObj{Narrative: "placard UN 1203 on tanker"}})
+	// Not a whole word
+	e.Handle(agent.Event{Type: agent.EventNarrative, Call: call, Narrative: &agent.NarrativeObj{Narrative: "UNIT 12034"}})
+
+	if len(*fire) != 1 || (*fire)[0].Alert.Match != "working fire" {
+		t.Fatalf("expected one working fire alert, got %#v", *fire)
+	}
+	if len(*hazmat) != 1 || (*hazmat)[0].Alert.Match != "UN 1203" {
+		t.Fatalf("expected one hazmat alert, got %#v", *hazmat)
+	}
+
+	// Keywords starting or ending with punctuation
+	e.Handle(agent.Event{Type: agent.EventNewCall, Call: agent.CallObj{CallID: 2, CallType: "10-50/PI"}})
+	e.Handle(agent.Event{Type: agent.EventNewCall, Call: agent.CallObj{CallID: 3, CallType: "ACCIDENT (MVA)"}})
+	e.Handle(agent.Event{Type: agent.EventNewCall, Call: agent.CallObj{CallID: 4, CallType: "10-50/PIN"}})
+	if len(*hazmat) != 3 || (*hazmat)[1].Alert.Match != "10-50/PI" || (*hazmat)[2].Alert.Match != "(MVA)" {
+		t.Fatalf("expected alerts for 10-50/PI and (MVA), got %#v", *hazmat)
+	}
+
+	// Rules fire again for a new call once the old one closes
+	e.Handle(agent.Event{Type: agent.EventCallClosed, Call: call})
+	e.Handle(agent.Event{Type: agent.EventNewCall, Call: agent.CallObj{CallID: 1, NatureOfCall: "MAYDAY"}})
+	if len(*fire) != 2 {
+		t.Fatalf("expected a second alert after close, got %d", len(*fire))
+	}
+}
+
+func Test_KeywordEngine_DuplicateRule(t *testing.T) {
+	e := &KeywordEngine{Rules: []*KeywordRule{
+		{Name: "fire", Keywords: []string{"working fire"}},
+		{Name: "fire", Keywords: []string{"MAYDAY"}},
+	}}
+	if err := e.Compile(); err == nil {
+		t.Fatalf("expected an error for duplicate rule names")
+	}
+}
+
+func Test_WatchlistEngine(t *testing.T) {
+	dir := t.TempDir()
+	units := filepath.Join(dir, "units.txt")
+	addresses := filepath.Join(dir, "addresses.txt")
+	os.WriteFile(units, []byte("# Chief wants to know\nTANKER70 | Tanker 70\n"), 0644)
+	os.WriteFile(addresses, []byte("120 Freedley Road\n"), 0644)
+
+	rec := &recorder{}
+	e := &WatchlistEngine{
+		Lists: []*Watchlist{
+			{Name: "chief", Kind: WatchUnits, Path: units},
+			{Name: "target-hazards", Kind: WatchAddresses, Path: addresses},
+		},
+		Router: &Router{Notifiers: map[string]notify.Notifier{"chief": rec}},
+	}
+	if err := e.Load(); err != nil {
+		t.Fatalf("ERR: Load: %s", err.Error())
+	}
+
+	call := agent.CallObj{CallID: 1, Location: "120 FREEDLEY RD, Pomfret"}
+	e.Handle(agent.Event{Type: agent.EventNewCall, Call: call})
+	e.Handle(agent.Event{Type: agent.EventUnitStatus, Call: call, Unit: &agent.UnitObj{UnitNumber: "tanker70"}})
+	e.Handle(agent.Event{Type: agent.EventUnitStatus, Call: call, Unit: &agent.UnitObj{UnitNumber: "TANKER70"}})
+	if len(*rec) != 2 || (*rec)[0].Alert.Rule != "target-hazards" || (*rec)[1].Alert.Match != "TANKER70 (Tanker 70)" {
+		t.Fatalf("unexpected alerts %#v", *rec)
+	}
+
+	// Hot reload picks up new entries
+	os.WriteFile(units, []byte("ENG70\n"), 0644)
+	os.Chtimes(units, time.Now().Add(time.Minute), time.Now().Add(time.Minute))
+	if err := e.Lists[0].Reload(); err != nil {
+		t.Fatalf("ERR: Reload: %s", err.Error())
+	}
+	if _, ok := e.Lists[0].Match("TANKER70"); ok {
+		t.Fatalf("stale entry after reload")
+	}
+	if _, ok := e.Lists[0].Match("ENG70"); !ok {
+		t.Fatalf("missing entry after reload")
+	}
+}
@@ -0,0 +1,91 @@
+package alert
+
+import (
+	"github.com/dayvillefire/newworld-cadview-agent/agent"
+	"github.com/dayvillefire/newworld-cadview-agent/agent/geo"
+)
+
+// GeofenceRule raises an alert when a call lands in one of its zones.
+type GeofenceRule struct {
+	// Name identifies the rule in alerts.
+	Name string `json:"name"`
+	// Zones are the names of the watched zones. Defaults to every zone.
+	Zones []string `json:"zones"`
+	// Notifiers are the names of the notifiers which receive matches.
+	// Defaults to all notifiers.
+	Notifiers []string `json:"notifiers"`
+}
+
+func (r *GeofenceRule) watches(zone string) bool {
+	if len(r.Zones) == 0 {
+		return true
+	}
+	for _, z := range r.Zones {
+		if z == zone {
+			return true
+		}
+	}
+	return false
+}
+
+// GeofenceEngine matches new and updated calls against a set of zones. Each
+// rule fires at most once per call.
+type GeofenceEngine struct {
+	Set    *geo.Set
+	Rules  []*GeofenceRule
+	Router *Router
+
+	once once
+}
+
+// Evaluate returns an alert event for each rule watching a zone which
+// contains the call.
+func (e *GeofenceEngine) Evaluate(ev agent.Event) []agent.Event {
+	out := []agent.Event{}
+	switch ev.Type {
+	case agent.EventCallClosed:
+		e.once.forget(ev.Call.CallID)
+		return out
+	case agent.EventNewCall, agent.EventCallUpdated:
+	default:
+		return out
+	}
+
+	zones := e.Set.Names(ev.Call)
+	call := ev.Call
+	call.Zones = zones
+	for _, r := range e.Rules {
+		for _, z := range zones {
+			if !r.watches(z) {
+				continue
+			}
+			if e.once.first(ev.Call.CallID, r.Name) {
+				out = append(out, agent.Event{
+					Type: agent.EventAlert,
+					Time: ev.Time,
+					Call: call,
+					Alert: &agent.Alert{
+						Rule:   r.Name,
+						Source: "geofence",
+						Field:  FieldLocation,
+						Match:  z,
+					},
+				})
+			}
+			break
+		}
+	}
+	return out
+}
+
+// Handle evaluates an event and routes any alerts. It can be passed to
+// agent.Watcher.Subscribe.
+func (e *GeofenceEngine) Handle(ev agent.Event) {
+	for _, a := range e.Evaluate(ev) {
+		for _, r := range e.Rules {
+			if r.Name == a.Alert.Rule {
+				e.Router.Route(a, r.Notifiers)
+			}
+		}
+	}
+}
@@ -0,0 +1,206 @@
+package alert
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+
+	"github.com/dayvillefire/newworld-cadview-agent/agent"
+)
+
+// Fields which can be matched by a KeywordRule
+const (
+	FieldNarrative    = "narrative"
+	FieldNatureOfCall = "natureOfCall"
+	FieldCallType     = "callType"
+	FieldLocation     = "location"
+	FieldCommonName   = "commonName"
+)
+
+// KeywordRule matches keywords or regular expressions against call fields
+// and narratives.
+type KeywordRule struct {
+	// Name identifies the rule in alerts.
+	Name string `json:"name"`
+	// Keywords are matched as whole words, like "working fire". Keywords
+	// which start or end with punctuation, like "(MVA)", match wherever
+	// that punctuation appears.
+	Keywords []string `json:"keywords"`
+	// Patterns are regular expressions, like `\bUN ?\d{4}\b`.
+	Patterns []string `json:"patterns"`
+	// CaseSensitive disables case folding for keywords and patterns.
+	CaseSensitive bool `json:"caseSensitive"`
+	// Fields limits the fields which are checked. Defaults to narrative and
+	// natureOfCall.
+	Fields []string `json:"fields"`
+	// Notifiers are the names of the notifiers which receive matches.
+	// Defaults to all notifiers.
+	Notifiers []string `json:"notifiers"`
+
+	compiled []*regexp.Regexp
+}
+
+// Compile prepares the keywords and patterns of a rule for matching.
+func (r *KeywordRule) Compile() error {
+	if r.Name == "" {
+		return fmt.Errorf("keyword rule has no name")
+	}
+	flags := "(?i)"
+	if r.CaseSensitive {
+		flags = ""
+	}
+	r.compiled = []*regexp.Regexp{}
+	for _, k := range r.Keywords {
+		k = strings.TrimSpace(k)
+		if k == "" {
+			continue
+		}
+		r.compiled = append(r.compiled, regexp.MustCompile(flags+wordBoundary(k[0])+regexp.QuoteMeta(k)+wordBoundary(k[len(k)-1])))
+	}
+	for _, p := range r.Patterns {
+		re, err := regexp.Compile(flags + p)
+		if err != nil {
+			return fmt.Errorf("rule %s: %w", r.Name, err)
+		}
+		r.compiled = append(r.compiled, re)
+	}
+	for _, f := range r.Fields {
+		switch f {
+		case FieldNarrative, FieldNatureOfCall, FieldCallType, FieldLocation, FieldCommonName:
+		default:
+			return fmt.Errorf("rule %s: unknown field %s", r.Name, f)
+		}
+	}
+	return nil
+}
+
+// wordBoundary returns the pattern which keeps a keyword from matching
+// within a word. \b only applies next to word characters, so none is used
+// for punctuation.
+func wordBoundary(c byte) string {
+	if c == '_' || ('0' <= c && c <= '9') || ('a' <= c && c <= 'z') || ('A' <= c && c <= 'Z') {
+		return `\b`
+	}
+	return ""
+}
+
+// Match returns the first match of the rule within text.
+func (r *KeywordRule) Match(text string) (string, bool) {
+	for _, re := range r.compiled {
+		if m := re.FindString(text); m != "" {
+			return m, true
+		}
+	}
+	return "", false
+}
+
+func (r *KeywordRule) checks(field string) bool {
+	if len(r.Fields) == 0 {
+		return field == FieldNarrative || field == FieldNatureOfCall
+	}
+	for _, f := range r.Fields {
+		if f == field {
+			return true
+		}
+	}
+	return false
+}
+
+// KeywordEngine evaluates keyword rules against watcher events. Each rule
+// fires at most once per call.
+type KeywordEngine struct {
+	Rules  []*KeywordRule
+	Router *Router
+
+	once once
+}
+
+// Compile compiles every rule in the engine. Rule names must be unique, as
+// they identify the rules which have fired for a call.
+func (e *KeywordEngine) Compile() error {
+	names := map[string]bool{}
+	for _, r := range e.Rules {
+		if err := r.Compile(); err != nil {
+			return err
+		}
+		if names[r.Name] {
+			return fmt.Errorf("duplicate keyword rule %s", r.Name)
+		}
+		names[r.Name] = true
+	}
+	return nil
+}
+
+// Evaluate returns an alert event for each rule which matches an event and
+// has not yet fired for the call.
+func (e *KeywordEngine) Evaluate(ev agent.Event) []agent.Event {
+	if ev.Type == agent.EventCallClosed {
+		e.once.forget(ev.Call.CallID)
+		return []agent.Event{}
+	}
+
+	type text struct{ field, value string }
+	texts := []text{}
+	switch ev.Type {
+	case agent.EventNewCall, agent.EventCallUpdated:
+		texts = append(texts,
+			text{FieldNatureOfCall, ev.Call.NatureOfCall},
+			text{FieldCallType, ev.Call.CallType},
+			text{FieldCallType, ev.Call.FireCallType},
+			text{FieldLocation, ev.Call.Location},
+			text{FieldCommonName, ev.Call.CommonName},
+		)
+		for _, n := range ev.Narratives {
+			texts = append(texts, text{FieldNarrative, n.Narrative})
+		}
+	case agent.EventNarrative:
+		if ev.Narrative != nil {
+			texts = append(texts, text{FieldNarrative, ev.Narrative.Narrative})
+		}
+	}
+
+	out := []agent.Event{}
+	for _, r := range e.Rules {
+		for _, t := range texts {
+			if t.value == "" || !r.checks(t.field) {
+				continue
+			}
+			m, ok := r.Match(t.value)
+			if !ok {
+				continue
+			}
+			if e.once.first(ev.Call.CallID, r.Name) {
+				out = append(out, agent.Event{
+					Type: agent.EventAlert,
+					Time: ev.Time,
+					Call: ev.Call,
+					Alert: &agent.Alert{
+						Rule:   r.Name,
+						Source: "keyword",
+						Field:  t.field,
+						Match:  m,
+					},
+				})
+			}
+			break
+		}
+	}
+	return out
+}
+
+// Handle evaluates an event and routes any alerts. It can be passed to
+// agent.Watcher.Subscribe.
+func (e *KeywordEngine) Handle(ev agent.Event) {
+	for _, a := range e.Evaluate(ev) {
+		e.Router.Route(a, e.rule(a.Alert.Rule).Notifiers)
+	}
+}
+
+func (e *KeywordEngine) rule(name string) *KeywordRule {
+	for _, r := range e.Rules {
+		if r.Name == name {
+			return r
+		}
+	}
+	return &KeywordRule{}
+}
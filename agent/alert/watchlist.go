@@ -0,0 +1,292 @@
+package alert
+
+import (
+	"bufio"
+	"fmt"
+	"log"
+	"os"
+	"regexp"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/dayvillefire/newworld-cadview-agent/agent"
+)
+
+// Watchlist kinds
+const (
+	WatchUnits     = "unit"
+	WatchAddresses = "address"
+)
+
+// Watchlist is a list of unit numbers or addresses loaded from a file. The
+// file holds one entry per line, optionally followed by "|" and a note,
+// like "TANKER70 | Tanker 70". Blank lines and lines starting with "#" are
+// ignored.
+type Watchlist struct {
+	// Name identifies the watchlist in alerts.
+	Name string `json:"name"`
+	// Kind is WatchUnits or WatchAddresses.
+	Kind string `json:"kind"`
+	// Path is the file the watchlist is loaded from.
+	Path string `json:"path"`
+	// Notifiers are the names of the notifiers which receive matches.
+	// Defaults to all notifiers.
+	Notifiers []string `json:"notifiers"`
+
+	entries map[string]string
+	modTime time.Time
+	l       sync.RWMutex
+}
+
+// Load reads the watchlist file, replacing the current entries.
+func (w *Watchlist) Load() error {
+	if w.Kind != WatchUnits && w.Kind != WatchAddresses {
+		return fmt.Errorf("watchlist %s: unknown kind %q", w.Name, w.Kind)
+	}
+	f, err := os.Open(w.Path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	st, err := f.Stat()
+	if err != nil {
+		return err
+	}
+
+	entries := map[string]string{}
+	s := bufio.NewScanner(f)
+	for s.Scan() {
+		line := strings.TrimSpace(s.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		entry, note, _ := strings.Cut(line, "|")
+		entry = strings.TrimSpace(entry)
+		label := entry
+		if note = strings.TrimSpace(note); note != "" {
+			label = fmt.Sprintf("%s (%s)", entry, note)
+		}
+		entries[w.normalize(entry)] = label
+	}
+	if err = s.Err(); err != nil {
+		return err
+	}
+
+	w.l.Lock()
+	w.entries = entries
+	w.modTime = st.ModTime()
+	w.l.Unlock()
+	return nil
+}
+
+// Reload reloads the watchlist if its file has changed since it was last
+// loaded.
+func (w *Watchlist) Reload() error {
+	st, err := os.Stat(w.Path)
+	if err != nil {
+		return err
+	}
+	w.l.RLock()
+	changed := !st.ModTime().Equal(w.modTime)
+	w.l.RUnlock()
+	if !changed {
+		return nil
+	}
+	log.Printf("INFO: Reloading watchlist %s from %s", w.Name, w.Path)
+	return w.Load()
+}
+
+// Match returns the label of the entry matching a unit number or location.
+// Address entries without a town match the address in any town.
+func (w *Watchlist) Match(value string) (string, bool) {
+	n := w.normalize(value)
+	if n == "" {
+		return "", false
+	}
+	w.l.RLock()
+	defer w.l.RUnlock()
+	if label, ok := w.entries[n]; ok {
+		return label, true
+	}
+	if w.Kind == WatchAddresses {
+		street, _, _ := strings.Cut(n, ",")
+		if label, ok := w.entries[strings.TrimSpace(street)]; ok {
+			return label, true
+		}
+	}
+	return "", false
+}
+
+func (w *Watchlist) normalize(s string) string {
+	if w.Kind == WatchAddresses {
+		return NormalizeLocation(s)
+	}
+	return strings.ToUpper(strings.TrimSpace(s))
+}
+
+var (
+	locationPunct = regexp.MustCompile(`[^A-Z0-9, ]+`)
+	locationSpace = regexp.MustCompile(`\s+`)
+)
+
+var streetSuffixes = map[string]string{
+	"AVENUE":    "AVE",
+	"BOULEVARD": "BLVD",
+	"CIRCLE":    "CIR",
+	"COURT":     "CT",
+	"DRIVE":     "DR",
+	"EXTENSION": "EXT",
+	"HIGHWAY":   "HWY",
+	"LANE":      "LN",
+	"PARKWAY":   "PKWY",
+	"PLACE":     "PL",
+	"ROAD":      "RD",
+	"ROUTE":     "RTE",
+	"STREET":    "ST",
+	"TERRACE":   "TER",
+	"TURNPIKE":  "TPKE",
+	"NORTH":     "N",
+	"SOUTH":     "S",
+	"EAST":      "E",
+	"WEST":      "W",
+}
+
+// NormalizeLocation reduces a CAD location to a comparable form by upper
+// casing, removing punctuation other than the town separator, collapsing
+// whitespace and abbreviating common street suffixes, so that
+// "120 Freedley Road,  Pomfret" becomes "120 FREEDLEY RD, POMFRET".
+func NormalizeLocation(s string) string {
+	s = locationPunct.ReplaceAllString(strings.ToUpper(s), " ")
+	parts := strings.Split(s, ",")
+	for i, part := range parts {
+		words := strings.Fields(locationSpace.ReplaceAllString(part, " "))
+		for j, word := range words {
+			if abbr, ok := streetSuffixes[word]; ok {
+				words[j] = abbr
+			}
+		}
+		parts[i] = strings.Join(words, " ")
+	}
+	return strings.Trim(strings.Join(parts, ", "), ", ")
+}
+
+// WatchlistEngine raises alerts when a watched unit is assigned to a call or
+// a call is at a watched address. Each entry fires at most once per call.
+type WatchlistEngine struct {
+	Lists  []*Watchlist
+	Router *Router
+	// ReloadInterval is how often Start checks the watchlist files for
+	// changes. Defaults to 30 seconds.
+	ReloadInterval time.Duration
+
+	once      once
+	cancelled atomic.Bool
+}
+
+// Load loads every watchlist.
+func (e *WatchlistEngine) Load() error {
+	for _, w := range e.Lists {
+		if err := w.Load(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Start reloads changed watchlist files in the background until Stop is
+// called.
+func (e *WatchlistEngine) Start() {
+	interval := e.ReloadInterval
+	if interval <= 0 {
+		interval = 30 * time.Second
+	}
+	go func() {
+		for {
+			for i := time.Duration(0); i < interval; i += time.Second {
+				time.Sleep(time.Second)
+				if e.cancelled.Load() {
+					return
+				}
+			}
+			for _, w := range e.Lists {
+				if err := w.Reload(); err != nil {
+					log.Printf("ERR: watchlist %s: %s", w.Name, err.Error())
+				}
+			}
+		}
+	}()
+}
+
+// Stop ends the reload loop started with Start.
+func (e *WatchlistEngine) Stop() {
+	e.cancelled.Store(true)
+}
+
+// Evaluate returns an alert event for each watchlist entry matching an
+// event which has not yet fired for the call.
+func (e *WatchlistEngine) Evaluate(ev agent.Event) []agent.Event {
+	out := []agent.Event{}
+	units := []string{}
+	location := ""
+	switch ev.Type {
+	case agent.EventCallClosed:
+		e.once.forget(ev.Call.CallID)
+		return out
+	case agent.EventNewCall:
+		for _, u := range ev.Units {
+			units = append(units, u.UnitNumber)
+		}
+		units = append(units, ev.Call.PrimaryUnit)
+		location = ev.Call.Location
+	case agent.EventCallUpdated:
+		units = append(units, ev.Call.PrimaryUnit)
+		location = ev.Call.Location
+	case agent.EventUnitStatus:
+		if ev.Unit != nil {
+			units = append(units, ev.Unit.UnitNumber)
+		}
+	default:
+		return out
+	}
+
+	for _, w := range e.Lists {
+		values := units
+		field := "unitNumber"
+		if w.Kind == WatchAddresses {
+			values = []string{location}
+			field = FieldLocation
+		}
+		for _, v := range values {
+			label, ok := w.Match(v)
+			if !ok || !e.once.first(ev.Call.CallID, w.Name+":"+label) {
+				continue
+			}
+			out = append(out, agent.Event{
+				Type: agent.EventAlert,
+				Time: ev.Time,
+				Call: ev.Call,
+				Alert: &agent.Alert{
+					Rule:   w.Name,
+					Source: "watchlist",
+					Field:  field,
+					Match:  label,
+				},
+			})
+		}
+	}
+	return out
+}
+
+// Handle evaluates an event and routes any alerts. It can be passed to
+// agent.Watcher.Subscribe.
+func (e *WatchlistEngine) Handle(ev agent.Event) {
+	for _, a := range e.Evaluate(ev) {
+		for _, w := range e.Lists {
+			if w.Name == a.Alert.Rule {
+				e.Router.Route(a, w.Notifiers)
+			}
+		}
+	}
+}
@@ -27,30 +27,50 @@ func (a *Agent) RefreshToken() error {
 }
 */
 
-func (a *Agent) IsAuthorized() error {
+// IsAuthorized checks the session with CadView, returning ErrNotAuthorized
+// if it answers false.
+func (a *Agent) IsAuthorized() (err error) {
 	// https://cadview.qvec.org/NewWorld.CadView/api/CadView/IsAuthorized
 
+	defer func() {
+		a.recordAuthorized(err)
+	}()
 	var out bool
 	url := a.BaseUrl + "NewWorld.CadView/api/CadView/IsAuthorized"
 	body, err := a.authorizedGet(url)
 	if err != nil {
 		return err
 	}
-	err = json.Unmarshal(body, &out)
-	return err
+	if err = json.Unmarshal(body, &out); err != nil {
+		return err
+	}
+	if !out {
+		return ErrNotAuthorized
+	}
+	return nil
 }
 
-func (a *Agent) Ping() error {
+// Ping keeps the session alive, returning ErrNotAuthorized if CadView
+// answers false.
+func (a *Agent) Ping() (err error) {
 	// https://cadview.qvec.org/NewWorld.CadView/api/CadView/Ping
 
+	defer func() {
+		a.recordPing(err)
+	}()
 	var out bool
 	url := a.BaseUrl + "NewWorld.CadView/api/CadView/Ping"
 	body, err := a.authorizedGet(url)
 	if err != nil {
 		return err
 	}
-	err = json.Unmarshal(body, &out)
-	return err
+	if err = json.Unmarshal(body, &out); err != nil {
+		return err
+	}
+	if !out {
+		return ErrNotAuthorized
+	}
+	return nil
 }
 
 func (a *Agent) GetORIs() ([]ORIObj, error) {
@@ -66,6 +86,13 @@ func (a *Agent) GetORIs() ([]ORIObj, error) {
 	return out, err
 }
 
+// tag applies the Tagger of the agent, if any, to calls.
+func (a *Agent) tag(calls []CallObj) {
+	if a.Tagger != nil {
+		a.Tagger.Tag(calls)
+	}
+}
+
 func (a *Agent) GetActiveCalls() ([]CallObj, error) {
 	// https://cadview.qvec.org/NewWorld.CadView/api/Call/GetActiveCalls
 
@@ -76,6 +103,14 @@ func (a *Agent) GetActiveCalls() ([]CallObj, error) {
 		return out, err
 	}
 	err = json.Unmarshal(body, &out)
+	if err == nil {
+		a.tag(out)
+		oris := []string{a.FDID}
+		for _, c := range out {
+			oris = append(oris, c.AllowedORI...)
+		}
+		a.recordSync(oris...)
+	}
 	return out, err
 }
 
@@ -101,6 +136,10 @@ func (a *Agent) GetClearedCalls(fromDate time.Time, toDate time.Time, ori string
 		log.Printf("DEBUG: %s", string(body))
 	}
 	err = json.Unmarshal(body, &out)
+	if err == nil {
+		a.tag(out)
+		a.recordSync(ori)
+	}
 	return out, err
 }
 
@@ -122,6 +161,11 @@ func (a *Agent) GetCallDetails(cobj CallObj) (CallObj, error) {
 		log.Printf("DEBUG: %s", string(body))
 	}
 	err = json.Unmarshal(body, &out)
+	if err == nil {
+		calls := []CallObj{out}
+		a.tag(calls)
+		out = calls[0]
+	}
 	return out, err
 }
 
@@ -0,0 +1,160 @@
+package agent
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"os"
+	"strings"
+
+	"github.com/chromedp/chromedp"
+)
+
+// LaunchOptions controls the local Chrome started by Init. Only KeepAlive
+// applies when using a remote devtools instance, and none of them with a
+// RemoteSession.
+type LaunchOptions struct {
+	// Headless runs Chrome without a window. Defaults to true.
+	Headless *bool
+	// ExecPath is the Chrome binary. If empty, the usual install locations
+	// and the PATH are searched.
+	ExecPath string
+	// Proxy is the proxy server for all browser traffic, like
+	// "http://proxy.example.org:3128" or "socks5://127.0.0.1:1080".
+	Proxy string
+	// WindowWidth and WindowHeight set the window size, which also
+	// determines the size of login failure screenshots.
+	WindowWidth  int
+	WindowHeight int
+	// Flags are extra Chrome command line flags, like "--lang=en-US" or
+	// "--disable-gpu".
+	Flags []string
+	// UserDataDir is a Chrome profile directory to use and keep. If empty,
+	// each login uses a new temporary profile which is removed afterwards.
+	UserDataDir string
+	// KeepAlive leaves the browser running after a successful login, for
+	// use with BrowserContext, until Close is called.
+	KeepAlive bool
+}
+
+// browserSession is a browser kept open after login.
+type browserSession struct {
+	ctx   context.Context
+	close func()
+}
+
+// execOptions returns the allocator options for a local Chrome, using the
+// given profile directory.
+func (o LaunchOptions) execOptions(dir string) []chromedp.ExecAllocatorOption {
+	opts := append(
+		chromedp.DefaultExecAllocatorOptions[:],
+		chromedp.UserDataDir(dir),
+		chromedp.Flag("enable-privacy-sandbox-ads-apis", true),
+	)
+	if o.Headless != nil && !*o.Headless {
+		opts = append(opts, chromedp.Flag("headless", false), chromedp.Flag("hide-scrollbars", false))
+	}
+	if o.ExecPath != "" {
+		opts = append(opts, chromedp.ExecPath(o.ExecPath))
+	}
+	if o.Proxy != "" {
+		opts = append(opts, chromedp.ProxyServer(o.Proxy))
+	}
+	if o.WindowWidth > 0 && o.WindowHeight > 0 {
+		opts = append(opts, chromedp.WindowSize(o.WindowWidth, o.WindowHeight))
+	}
+	for _, f := range o.Flags {
+		name, value, ok := strings.Cut(strings.TrimLeft(f, "-"), "=")
+		if name == "" {
+			continue
+		}
+		if ok {
+			opts = append(opts, chromedp.Flag(name, value))
+		} else {
+			opts = append(opts, chromedp.Flag(name, true))
+		}
+	}
+	return opts
+}
+
+// newBrowser starts a local Chrome, or connects to the remote devtools
+// instance, returning a browser context and a function which closes the
+// browser and removes its temporary profile.
+func (a *Agent) newBrowser() (context.Context, func(), error) {
+	if a.Remote != nil {
+		return a.Remote.newTab()
+	}
+
+	var allocCtx context.Context
+	var allocCancel context.CancelFunc
+	dir := ""
+
+	if a.CDP != "" {
+		log.Printf("INFO: Remote devtools URL %s", a.CDP)
+		allocCtx, allocCancel = chromedp.NewRemoteAllocator(context.Background(), a.CDP)
+	} else {
+		profileDir := a.Launch.UserDataDir
+		if profileDir == "" {
+			// Separate profiles keep concurrent logins from colliding
+			var err error
+			dir, err = os.MkdirTemp("", "cadview-chrome-")
+			if err != nil {
+				return nil, nil, fmt.Errorf("creating browser profile: %w", err)
+			}
+			profileDir = dir
+		}
+		if a.Debug {
+			log.Printf("DEBUG: Chrome profile %s", profileDir)
+		}
+		allocCtx, allocCancel = chromedp.NewExecAllocator(context.Background(), a.Launch.execOptions(profileDir)...)
+	}
+
+	var ctx context.Context
+	var cancel context.CancelFunc
+	if a.Debug {
+		ctx, cancel = chromedp.NewContext(allocCtx, chromedp.WithDebugf(log.Printf))
+	} else {
+		ctx, cancel = chromedp.NewContext(allocCtx)
+	}
+
+	return ctx, func() {
+		cancel()
+		// Waits for a local browser to exit, so the profile can be removed
+		allocCancel()
+		if dir != "" {
+			if err := os.RemoveAll(dir); err != nil {
+				log.Printf("WARN: Removing browser profile %s: %s", dir, err.Error())
+			}
+		}
+	}, nil
+}
+
+// keepBrowser reports whether the browser is kept open after login.
+func (a *Agent) keepBrowser() bool {
+	return a.Launch.KeepAlive || a.Remote != nil
+}
+
+// BrowserContext returns the context of the browser kept open after login
+// with LaunchOptions.KeepAlive or a RemoteSession, for running further
+// chromedp actions.
+func (a *Agent) BrowserContext() (context.Context, bool) {
+	a.l.Lock()
+	defer a.l.Unlock()
+	if a.browser == nil {
+		return nil, false
+	}
+	return a.browser.ctx, true
+}
+
+// Close closes the browser kept open after login, if any.
+func (a *Agent) Close() {
+	a.l.Lock()
+	b := a.browser
+	a.browser = nil
+	a.l.Unlock()
+	if b == nil {
+		return
+	}
+	b.close()
+	a.setBrowser(BrowserClosed)
+}
@@ -0,0 +1,404 @@
+package agent
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"regexp"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/chromedp/cdproto/cdp"
+	"github.com/chromedp/cdproto/network"
+	"github.com/chromedp/chromedp"
+)
+
+// DefaultCaptureExclude leaves out scripts, stylesheets, fonts and images,
+// which are large and of no use for finding API responses.
+var DefaultCaptureExclude = []string{
+	"re:^[^h]", // data:, blob: and the like
+	"*.css", "*.css?*",
+	"*.js", "*.js?*",
+	"*.svg", "*.woff", "*.woff2", "*.png", "*.gif", "*.ico",
+}
+
+// Exchange is a request made by the browser, paired with its response by
+// request ID.
+type Exchange struct {
+	ID       network.RequestID
+	Started  time.Time
+	Request  *network.Request
+	Response *network.Response
+	// Body is the response body, if it could be retrieved.
+	Body []byte
+	// Size is the number of bytes received, including headers.
+	Size int64
+	// Error is set if loading failed.
+	Error string
+
+	// Monotonic times in seconds, for timings
+	sent     float64
+	finished float64
+}
+
+// Capture records the requests made by a browser, with their responses,
+// within limits on what is kept and how much memory it uses. Patterns are
+// globs, where * matches any characters, like "*/api/CadView/*", or
+// regular expressions prefixed with "re:", like "re:/api/Call/\d+".
+type Capture struct {
+	// Include, if not empty, limits capture to URLs matching one of the
+	// patterns. Exclude skips URLs matching any of its patterns, and
+	// defaults to DefaultCaptureExclude if neither is set.
+	Include []string
+	Exclude []string
+	// IncludeMIME and ExcludeMIME filter responses by MIME type, like
+	// "application/json" or "text/*".
+	IncludeMIME []string
+	ExcludeMIME []string
+	// MaxBytes caps the memory used by captured bodies and URLs, evicting
+	// the oldest exchanges first. Defaults to 32 MiB.
+	MaxBytes int64
+	// MaxEntries caps the number of exchanges kept. Defaults to 1000.
+	MaxEntries int
+	Debug      bool
+
+	include, exclude         []*regexp.Regexp
+	includeMIME, excludeMIME []*regexp.Regexp
+	compiled                 bool
+	exchanges                []*Exchange
+	byID                     map[network.RequestID]*Exchange
+	bytes                    int64
+	wg                       sync.WaitGroup
+	l                        sync.Mutex
+}
+
+// exchangeOverhead approximates the memory used by an exchange besides its
+// body and URL.
+const exchangeOverhead = 512
+
+// empty returns a new Capture with the same settings and nothing captured,
+// or nil if c is nil.
+func (c *Capture) empty() *Capture {
+	if c == nil {
+		return nil
+	}
+	return &Capture{
+		Include:     slices.Clone(c.Include),
+		Exclude:     slices.Clone(c.Exclude),
+		IncludeMIME: slices.Clone(c.IncludeMIME),
+		ExcludeMIME: slices.Clone(c.ExcludeMIME),
+		MaxBytes:    c.MaxBytes,
+		MaxEntries:  c.MaxEntries,
+		Debug:       c.Debug,
+	}
+}
+
+// Listen records the network traffic of a browser context until the
+// context is done.
+func (c *Capture) Listen(ctx context.Context) error {
+	c.l.Lock()
+	err := c.compile()
+	c.l.Unlock()
+	if err != nil {
+		return err
+	}
+	chromedp.ListenTarget(ctx, func(v interface{}) {
+		if !c.event(v) {
+			return
+		}
+		ev := v.(*network.EventLoadingFinished)
+		c.wg.Add(1)
+		go func() {
+			defer c.wg.Done()
+			t := chromedp.FromContext(ctx)
+			if t == nil || t.Target == nil {
+				return
+			}
+			body, err := network.GetResponseBody(ev.RequestID).Do(cdp.WithExecutor(ctx, t.Target))
+			if err != nil {
+				if c.Debug {
+					log.Printf("DEBUG: GetResponseBody(%s): %s", ev.RequestID, err.Error())
+				}
+				return
+			}
+			c.setBody(ev.RequestID, body)
+		}()
+	})
+	return nil
+}
+
+// Wait waits for the bodies of finished requests to be retrieved.
+func (c *Capture) Wait() {
+	c.wg.Wait()
+}
+
+// Exchanges returns the captured exchanges, oldest first.
+func (c *Capture) Exchanges() []Exchange {
+	return c.Find("")
+}
+
+// Find returns the captured exchanges with URLs matching a pattern, oldest
+// first. An empty pattern matches all of them.
+func (c *Capture) Find(pattern string) []Exchange {
+	re, err := compilePattern(pattern)
+	if err != nil {
+		log.Printf("ERR: Capture: %s", err.Error())
+		return nil
+	}
+	c.l.Lock()
+	defer c.l.Unlock()
+	out := []Exchange{}
+	for _, x := range c.exchanges {
+		if pattern == "" || re.MatchString(x.Request.URL) {
+			out = append(out, *x)
+		}
+	}
+	return out
+}
+
+// Last returns the most recent exchange with a URL matching a pattern which
+// has a response.
+func (c *Capture) Last(pattern string) (Exchange, bool) {
+	found := c.Find(pattern)
+	for i := len(found) - 1; i >= 0; i-- {
+		if found[i].Response != nil {
+			return found[i], true
+		}
+	}
+	return Exchange{}, false
+}
+
+// Body returns the body of the most recent response to a URL matching a
+// pattern.
+func (c *Capture) Body(pattern string) ([]byte, bool) {
+	found := c.Find(pattern)
+	for i := len(found) - 1; i >= 0; i-- {
+		if found[i].Body != nil {
+			return found[i].Body, true
+		}
+	}
+	return nil, false
+}
+
+// Bytes returns the approximate memory used by the captured exchanges.
+func (c *Capture) Bytes() int64 {
+	c.l.Lock()
+	defer c.l.Unlock()
+	return c.bytes
+}
+
+// Clear discards the captured exchanges.
+func (c *Capture) Clear() {
+	c.l.Lock()
+	defer c.l.Unlock()
+	c.exchanges = nil
+	c.byID = nil
+	c.bytes = 0
+}
+
+// event records a network event, reporting whether the response body of a
+// captured request is ready to be retrieved.
+func (c *Capture) event(v interface{}) bool {
+	c.l.Lock()
+	defer c.l.Unlock()
+	if !c.compiled {
+		// Listen reports errors
+		_ = c.compile()
+	}
+	if c.byID == nil {
+		c.byID = map[network.RequestID]*Exchange{}
+	}
+
+	switch ev := v.(type) {
+	case *network.EventRequestWillBeSent:
+		if prev, ok := c.byID[ev.RequestID]; ok && ev.RedirectResponse != nil {
+			// Redirects reuse the request ID
+			prev.Response = ev.RedirectResponse
+			prev.finished = monotonic(ev.Timestamp)
+			delete(c.byID, ev.RequestID)
+		}
+		if !c.wanted(ev.Request.URL) {
+			return false
+		}
+		if c.Debug {
+			log.Printf("DEBUG: Capture: %s %s", ev.Request.Method, ev.Request.URL)
+		}
+		x := &Exchange{
+			ID:      ev.RequestID,
+			Started: time.Now(),
+			Request: ev.Request,
+			sent:    monotonic(ev.Timestamp),
+		}
+		if ev.WallTime != nil {
+			x.Started = ev.WallTime.Time()
+		}
+		c.exchanges = append(c.exchanges, x)
+		c.byID[ev.RequestID] = x
+		c.bytes += int64(len(ev.Request.URL)) + exchangeOverhead
+	case *network.EventResponseReceived:
+		x, ok := c.byID[ev.RequestID]
+		if !ok {
+			return false
+		}
+		if !c.wantedMIME(ev.Response.MimeType) {
+			c.remove(x)
+			return false
+		}
+		x.Response = ev.Response
+		// Only evict for exchanges which passed the MIME filter, so that
+		// dropped responses cannot displace wanted ones
+		c.evict()
+	case *network.EventLoadingFinished:
+		if x, ok := c.byID[ev.RequestID]; ok {
+			x.Size = int64(ev.EncodedDataLength)
+			x.finished = monotonic(ev.Timestamp)
+			return x.Response != nil
+		}
+	case *network.EventLoadingFailed:
+		if x, ok := c.byID[ev.RequestID]; ok {
+			x.Error = ev.ErrorText
+			x.finished = monotonic(ev.Timestamp)
+			c.evict()
+		}
+	}
+	return false
+}
+
+// setBody stores the response body of a request.
+func (c *Capture) setBody(id network.RequestID, body []byte) {
+	c.l.Lock()
+	defer c.l.Unlock()
+	x, ok := c.byID[id]
+	if !ok {
+		// Evicted meanwhile
+		return
+	}
+	c.bytes += int64(len(body)) - int64(len(x.Body))
+	x.Body = body
+	c.evict()
+}
+
+// evict drops the oldest exchanges until the capture is within its limits.
+// Only exchanges with a response or an error count towards MaxEntries, as
+// requests still in flight may yet be dropped by the MIME filters.
+func (c *Capture) evict() {
+	maxBytes, maxEntries := c.MaxBytes, c.MaxEntries
+	if maxBytes <= 0 {
+		maxBytes = 32 << 20
+	}
+	if maxEntries <= 0 {
+		maxEntries = 1000
+	}
+	settled := func(x *Exchange) bool {
+		return x.Response != nil || x.Error != ""
+	}
+	entries := 0
+	for _, x := range c.exchanges {
+		if settled(x) {
+			entries++
+		}
+	}
+	for len(c.exchanges) > 0 && (c.bytes > maxBytes || entries > maxEntries) {
+		if settled(c.exchanges[0]) {
+			entries--
+		}
+		c.remove(c.exchanges[0])
+	}
+}
+
+// remove drops an exchange.
+func (c *Capture) remove(x *Exchange) {
+	for i, e := range c.exchanges {
+		if e == x {
+			c.exchanges = append(c.exchanges[:i], c.exchanges[i+1:]...)
+			break
+		}
+	}
+	if c.byID[x.ID] == x {
+		delete(c.byID, x.ID)
+	}
+	c.bytes -= int64(len(x.Request.URL)+len(x.Body)) + exchangeOverhead
+}
+
+func (c *Capture) wanted(url string) bool {
+	if len(c.include) > 0 && !matchAny(c.include, url) {
+		return false
+	}
+	return !matchAny(c.exclude, url)
+}
+
+func (c *Capture) wantedMIME(mimeType string) bool {
+	mimeType, _, _ = strings.Cut(mimeType, ";")
+	if len(c.includeMIME) > 0 && !matchAny(c.includeMIME, mimeType) {
+		return false
+	}
+	return !matchAny(c.excludeMIME, mimeType)
+}
+
+// compile compiles the filter patterns. It is called with the capture
+// locked.
+func (c *Capture) compile() error {
+	if c.compiled {
+		return nil
+	}
+	exclude := c.Exclude
+	if len(c.Include) == 0 && len(c.Exclude) == 0 {
+		exclude = DefaultCaptureExclude
+	}
+	var err error
+	for _, f := range []struct {
+		patterns []string
+		dst      *[]*regexp.Regexp
+	}{
+		{c.Include, &c.include},
+		{exclude, &c.exclude},
+		{c.IncludeMIME, &c.includeMIME},
+		{c.ExcludeMIME, &c.excludeMIME},
+	} {
+		*f.dst = nil
+		for _, p := range f.patterns {
+			re, perr := compilePattern(p)
+			if perr != nil {
+				err = perr
+				continue
+			}
+			*f.dst = append(*f.dst, re)
+		}
+	}
+	// Compile again next time, so that each Listen reports the error
+	c.compiled = err == nil
+	return err
+}
+
+// compilePattern compiles a glob, or a regular expression prefixed with
+// "re:".
+func compilePattern(p string) (*regexp.Regexp, error) {
+	if expr, ok := strings.CutPrefix(p, "re:"); ok {
+		re, err := regexp.Compile(expr)
+		if err != nil {
+			return nil, fmt.Errorf("pattern %q: %w", p, err)
+		}
+		return re, nil
+	}
+	expr := regexp.QuoteMeta(p)
+	expr = strings.ReplaceAll(expr, `\*`, ".*")
+	return regexp.Compile("^" + expr + "$")
+}
+
+func matchAny(res []*regexp.Regexp, s string) bool {
+	for _, re := range res {
+		if re.MatchString(s) {
+			return true
+		}
+	}
+	return false
+}
+
+func monotonic(t *cdp.MonotonicTime) float64 {
+	if t == nil {
+		return 0
+	}
+	return float64(t.Time().UnixNano()) / float64(time.Second)
+}
@@ -0,0 +1,85 @@
+package agent
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/chromedp/cdproto/network"
+)
+
+func Test_Capture_Filters(t *testing.T) {
+	c := &Capture{
+		Include:     []string{"*/api/*", `re:/identity/\w+$`},
+		Exclude:     []string{"*/api/CadView/Ping*"},
+		ExcludeMIME: []string{"image/*"},
+		MaxEntries:  3,
+	}
+	send := func(id, url, mimeType string, body string) {
+		rid := network.RequestID(id)
+		c.event(&network.EventRequestWillBeSent{RequestID: rid, Request: &network.Request{Method: "GET", URL: url}})
+		c.event(&network.EventResponseReceived{RequestID: rid, Response: &network.Response{Status: 200, MimeType: mimeType}})
+		if c.event(&network.EventLoadingFinished{RequestID: rid}) {
+			c.setBody(rid, []byte(body))
+		}
+	}
+	send("1", "https://cad/identity/login", "text/html", "<html>")
+	send("2", "https://cad/api/CadView/Ping", "application/json", "true")
+	send("3", "https://cad/app.js", "application/javascript", "")
+	send("4", "https://cad/api/Call/GetCall?callId=1", "application/json", `{"CallId":1}`)
+	send("5", "https://cad/api/Call/GetCall?callId=1", "application/json; charset=utf-8", `{"CallId":1,"Status":"Cleared"}`)
+	send("6", "https://cad/api/Map/Tile", "image/png", "PNG")
+
+	// The image request was dropped without displacing the login
+	found := c.Exchanges()
+	if len(found) != 3 || found[0].ID != "1" || found[1].ID != "4" || found[2].ID != "5" {
+		t.Fatalf("unexpected exchanges %v", found)
+	}
+	if calls := c.Find("*GetCall*"); len(calls) != 2 {
+		t.Errorf("expected repeated requests to be kept, got %d", len(calls))
+	}
+	if body, ok := c.Body(`re:GetCall\?callId=1$`); !ok || !strings.Contains(string(body), "Cleared") {
+		t.Errorf("expected the latest body, got %s", body)
+	}
+	if _, ok := c.Last("*/api/Unit/*"); ok {
+		t.Error("expected no match")
+	}
+
+	// The oldest exchanges go first when over the memory cap
+	c.MaxBytes = 2*exchangeOverhead + 200
+	send("7", "https://cad/api/Call/GetCalls", "application/json", "[]")
+	if found := c.Exchanges(); len(found) != 2 || found[0].ID != "5" || c.Bytes() > c.MaxBytes {
+		t.Errorf("unexpected exchanges after eviction %v (%d bytes)", found, c.Bytes())
+	}
+	c.Clear()
+	if len(c.Exchanges()) != 0 || c.Bytes() != 0 {
+		t.Error("expected capture to be cleared")
+	}
+
+	// Pattern errors are reported by every Listen, not only the first
+	bad := &Capture{Include: []string{"re:("}}
+	if err := bad.compile(); err == nil {
+		t.Error("expected pattern error")
+	}
+	if err := bad.compile(); err == nil {
+		t.Error("expected pattern error again")
+	}
+}
+
+func Test_Capture_MakeCopy(t *testing.T) {
+	a := &Agent{Capture: &Capture{Include: []string{"*/api/*"}, MaxEntries: 3, Debug: true}}
+	rid := network.RequestID("1")
+	a.Capture.event(&network.EventRequestWillBeSent{RequestID: rid, Request: &network.Request{Method: "GET", URL: "https://cad/api/Call/GetCall"}})
+
+	// Copies keep the settings but not the traffic of the original
+	c := a.MakeCopy().Capture
+	if c == a.Capture || len(c.Exchanges()) != 0 {
+		t.Fatalf("expected a new, empty capture")
+	}
+	if !reflect.DeepEqual(c.Include, a.Capture.Include) || c.MaxEntries != 3 || !c.Debug {
+		t.Errorf("unexpected capture settings %#v", c)
+	}
+	if (&Agent{}).MakeCopy().Capture != nil {
+		t.Errorf("expected no capture for a copy of an agent without one")
+	}
+}
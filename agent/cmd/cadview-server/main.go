@@ -0,0 +1,279 @@
+// Command cadview-server holds a single authenticated CadView session and
+// serves its data as JSON to other tools.
+package main
+
+import (
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/dayvillefire/newworld-cadview-agent/agent"
+	"github.com/dayvillefire/newworld-cadview-agent/agent/alert"
+	"github.com/dayvillefire/newworld-cadview-agent/agent/config"
+	"github.com/dayvillefire/newworld-cadview-agent/agent/metrics"
+	"github.com/dayvillefire/newworld-cadview-agent/agent/notify"
+	"github.com/dayvillefire/newworld-cadview-agent/agent/rpc"
+	"github.com/dayvillefire/newworld-cadview-agent/agent/server"
+)
+
+var (
+	configFile = flag.String("config", os.Getenv("CADVIEW_CONFIG"), "YAML or TOML configuration file")
+	listen     = flag.String("listen", ":8080", "Address to listen on")
+	baseUrl    = flag.String("url", os.Getenv("CADVIEW_URL"), "CadView base URL, with trailing slash")
+	fdid       = flag.String("fdid", os.Getenv("CADVIEW_FDID"), "FDID associated with the login")
+	cdp        = flag.String("cdp", os.Getenv("CADVIEW_CDP"), "Remote devtools URL, if not using a local browser")
+	apiKeys    = flag.String("api-keys", os.Getenv("CADVIEW_API_KEYS"), "Comma separated list of accepted API keys")
+	cacheTTL   = flag.Duration("cache-ttl", 15*time.Second, "How long to cache responses")
+	proxy      = flag.String("proxy-listen", "", "Address for the authenticated CadView API proxy, like 127.0.0.1:8081")
+	grpcAddr   = flag.String("grpc-listen", "", "Address for the gRPC service, like :9090")
+	poll       = flag.Duration("poll", 30*time.Second, "Active call poll interval for the live feed, 0 to disable")
+	diagDir    = flag.String("diagnostics", os.Getenv("CADVIEW_DIAGNOSTICS_DIR"), "Directory for login failure diagnostics")
+	debug      = flag.Bool("debug", false, "Enable debug logging")
+)
+
+func main() {
+	flag.Parse()
+
+	cfg, err := loadConfig()
+	if err != nil {
+		log.Fatalf("ERR: %s", err.Error())
+	}
+	in, err := cfg.Instance(cfg.Server.Instance)
+	if err != nil {
+		log.Fatalf("ERR: %s", err.Error())
+	}
+
+	zones, err := cfg.Zones.Load()
+	if err != nil {
+		log.Fatalf("ERR: zones: %s", err.Error())
+	}
+
+	// Sinks, exports and alerts may use other instances, each with its own
+	// session
+	agents := map[string]*agent.Agent{}
+	watchers := map[string]*agent.Watcher{}
+	remotes := map[string]*agent.RemoteSession{}
+	session := func(in config.Instance) (*agent.Agent, *agent.Watcher) {
+		if a, ok := agents[in.Name]; ok {
+			return a, watchers[in.Name]
+		}
+		a := in.Agent()
+		if zones != nil {
+			a.Tagger = zones
+		}
+		if in.CDPSession {
+			r, ok := remotes[in.CDP]
+			if !ok {
+				r = &agent.RemoteSession{CDP: in.CDP, Debug: in.Debug}
+				if err := r.Start(); err != nil {
+					log.Fatalf("ERR: %s", err.Error())
+				}
+				remotes[in.CDP] = r
+			}
+			a.Remote = r
+		}
+		w := in.Watcher(a)
+		agents[in.Name], watchers[in.Name] = a, w
+		return a, w
+	}
+	a, w := session(in)
+	m := metrics.New(a, w)
+
+	sinks := map[*agent.Watcher]notify.Multi{}
+	router := &alert.Router{Notifiers: map[string]notify.Notifier{}}
+	for i, sc := range cfg.Sinks {
+		sin, _ := cfg.Instance(sc.Instance)
+		_, sw := session(sin)
+		if sw == nil {
+			log.Fatalf("ERR: sink %q: polling is disabled for its instance", sc.Name)
+		}
+		n, err := sc.Notifier()
+		if err != nil {
+			log.Fatalf("ERR: sink %q: %s", sc.Name, err.Error())
+		}
+		sinks[sw] = append(sinks[sw], n)
+		name := sc.Name
+		if name == "" {
+			name = fmt.Sprintf("sinks[%d]", i)
+		}
+		router.Notifiers[name] = n
+	}
+	engines, err := cfg.Alerts.Engines(zones, router)
+	if err != nil {
+		log.Fatalf("ERR: alerts: %s", err.Error())
+	}
+	handlers := engines.Handlers()
+	var aw *agent.Watcher
+	if len(handlers) > 0 {
+		ain, _ := cfg.Instance(cfg.Alerts.Instance)
+		if _, aw = session(ain); aw == nil {
+			log.Fatalf("ERR: alerts: polling is disabled for their instance")
+		}
+	}
+	jobs := []config.Job{}
+	for _, ec := range cfg.Exports {
+		ein, _ := cfg.Instance(ec.Instance)
+		ea, _ := session(ein)
+		job, err := ec.Job(ea)
+		if err != nil {
+			log.Fatalf("ERR: export %q: %s", ec.Name, err.Error())
+		}
+		jobs = append(jobs, job)
+	}
+
+	for name, sa := range agents {
+		if err := sa.Init(); err != nil {
+			log.Fatalf("ERR: Init(%s): %s", name, err.Error())
+		}
+		sa.Run()
+	}
+
+	s := &server.Server{
+		Agent:    a,
+		APIKeys:  cfg.Server.APIKeys,
+		CacheTTL: time.Duration(cfg.Server.CacheTTL),
+		Metrics:  m.Handler(),
+		Debug:    *debug,
+	}
+	if w != nil {
+		s.Feed = &server.Feed{Watcher: w}
+	}
+	for sw, n := range sinks {
+		sw.Subscribe(func(ev agent.Event) {
+			if err := n.Notify(ev); err != nil {
+				log.Printf("ERR: Notify: %s", err.Error())
+			}
+		})
+	}
+	for _, fn := range handlers {
+		aw.Subscribe(fn)
+	}
+	if engines.Watchlists != nil {
+		engines.Watchlists.Start()
+	}
+	for _, sw := range watchers {
+		if sw == nil {
+			continue
+		}
+		if s.Feed != nil && sw == s.Feed.Watcher {
+			s.Feed.Start()
+		} else {
+			sw.Start()
+		}
+	}
+	for _, job := range jobs {
+		if err := job.Start(); err != nil {
+			log.Fatalf("ERR: %s", err.Error())
+		}
+	}
+
+	if cfg.Server.GRPCListen != "" {
+		g := &rpc.Server{Agent: a, Feed: s.Feed, APIKeys: s.APIKeys}
+		go func() {
+			log.Fatal(g.ListenAndServe(cfg.Server.GRPCListen))
+		}()
+	}
+
+	if cfg.Server.ProxyListen != "" {
+		p := &server.Proxy{Agent: a, APIKeys: s.APIKeys}
+		go func() {
+			log.Fatal(p.ListenAndServe(cfg.Server.ProxyListen))
+		}()
+	}
+
+	log.Fatal(s.ListenAndServe(cfg.Server.Listen))
+}
+
+// loadConfig reads the configuration file given with -config, applying any
+// flags given with it, or else builds the configuration from the flags and
+// environment.
+func loadConfig() (*config.Config, error) {
+	if *configFile == "" {
+		return flagConfig()
+	}
+	cfg, err := config.Load(*configFile)
+	if err != nil {
+		return nil, err
+	}
+	overrideConfig(cfg)
+	return cfg, cfg.Validate()
+}
+
+// flagConfig builds a configuration from the command line flags and
+// environment, for use without a configuration file.
+func flagConfig() (*config.Config, error) {
+	cfg := &config.Config{
+		Instances: []config.Instance{{
+			URL:         *baseUrl,
+			FDID:        *fdid,
+			CDP:         *cdp,
+			Credentials: config.Credentials{Source: config.CredentialsEnv},
+			MFA:         config.MFA{TOTPSecret: os.Getenv("CADVIEW_TOTP_SECRET")},
+		}},
+	}
+	overrideConfig(cfg)
+	if err := cfg.Validate(); err != nil {
+		return nil, fmt.Errorf("%w\nUse -config, or -url with CADVIEW_USERNAME and CADVIEW_PASSWORD", err)
+	}
+	if _, err := cfg.Instances[0].Credentials.Provider().Credentials(); err != nil {
+		return nil, err
+	}
+	return cfg, nil
+}
+
+// overrideConfig applies the server flags to a configuration. Flags which
+// were not given on the command line only replace empty values.
+func overrideConfig(cfg *config.Config) {
+	set := map[string]bool{}
+	flag.Visit(func(f *flag.Flag) {
+		set[f.Name] = true
+	})
+	str := func(name string, dst *string, v string) {
+		if set[name] || *dst == "" {
+			*dst = v
+		}
+	}
+	str("listen", &cfg.Server.Listen, *listen)
+	str("proxy-listen", &cfg.Server.ProxyListen, *proxy)
+	str("grpc-listen", &cfg.Server.GRPCListen, *grpcAddr)
+	if set["cache-ttl"] || cfg.Server.CacheTTL == 0 {
+		cfg.Server.CacheTTL = config.Duration(*cacheTTL)
+	}
+	if set["api-keys"] || len(cfg.Server.APIKeys) == 0 {
+		cfg.Server.APIKeys = nil
+		for _, k := range strings.Split(*apiKeys, ",") {
+			if k = strings.TrimSpace(k); k != "" {
+				cfg.Server.APIKeys = append(cfg.Server.APIKeys, k)
+			}
+		}
+	}
+
+	for i := range cfg.Instances {
+		in := &cfg.Instances[i]
+		if in.Name != cfg.Server.Instance && !(cfg.Server.Instance == "" && i == 0) {
+			continue
+		}
+		if set["url"] {
+			in.URL = *baseUrl
+		}
+		if set["fdid"] {
+			in.FDID = *fdid
+		}
+		if set["cdp"] {
+			in.CDP = *cdp
+		}
+		str("diagnostics", &in.DiagnosticsDir, *diagDir)
+		if set["poll"] || in.Poll == 0 {
+			in.Poll = config.Duration(*poll)
+			if *poll <= 0 {
+				in.Poll = -1
+			}
+		}
+		if *debug {
+			in.Debug = true
+		}
+	}
+}
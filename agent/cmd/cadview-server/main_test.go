@@ -0,0 +1,41 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func Test_LoadConfig_File(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "cadview.yaml")
+	err := os.WriteFile(path, []byte(`
+instances:
+  - name: qvec
+    url: https://cadview.example.org/
+    fdid: "04040"
+    credentials:
+      username: user
+      password: pass
+server:
+  listen: 127.0.0.1:8088
+`), 0600)
+	if err != nil {
+		t.Fatalf("ERR: %s", err.Error())
+	}
+
+	// No -url or CADVIEW_* variables are needed with a file
+	*baseUrl = ""
+	*configFile = path
+	defer func() { *configFile = "" }()
+	cfg, err := loadConfig()
+	if err != nil {
+		t.Fatalf("ERR: loadConfig: %s", err.Error())
+	}
+	in, err := cfg.Instance(cfg.Server.Instance)
+	if err != nil {
+		t.Fatalf("ERR: Instance: %s", err.Error())
+	}
+	if in.URL != "https://cadview.example.org/" || cfg.Server.Listen != "127.0.0.1:8088" {
+		t.Fatalf("unexpected configuration %#v", cfg)
+	}
+}
@@ -0,0 +1,419 @@
+// Command cadview looks up calls in a NewWorld CadView instance from the
+// command line.
+//
+//	cadview login
+//	cadview active
+//	cadview -o csv cleared --from 2022-10-01 --to 2022-10-31 --fdid 04040
+//	cadview call 2022-00000345
+//	cadview oris
+//	cadview whoami
+//	cadview -o json export --from 2022-10-01 > october.json
+//
+// The session obtained by login is cached, so that later commands do not
+// need to start a browser until it expires.
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/dayvillefire/newworld-cadview-agent/agent"
+	"github.com/dayvillefire/newworld-cadview-agent/agent/config"
+	"github.com/dayvillefire/newworld-cadview-agent/agent/export"
+)
+
+var (
+	configFile = flag.String("config", os.Getenv("CADVIEW_CONFIG"), "YAML or TOML configuration file")
+	instance   = flag.String("instance", "", "Name of the configured instance to use, defaults to the first")
+	baseUrl    = flag.String("url", os.Getenv("CADVIEW_URL"), "CadView base URL, with trailing slash")
+	fdid       = flag.String("fdid", os.Getenv("CADVIEW_FDID"), "FDID associated with the login")
+	cdp        = flag.String("cdp", os.Getenv("CADVIEW_CDP"), "Remote devtools URL, if not using a local browser")
+	format     = flag.String("o", "table", "Output format: table, json or csv")
+	session    = flag.String("session", defaultSessionPath(), "Session cache file")
+	diagDir    = flag.String("diagnostics", os.Getenv("CADVIEW_DIAGNOSTICS_DIR"), "Directory for login failure diagnostics")
+	harFile    = flag.String("har", "", "Write the browser traffic of a login to this HAR file")
+	headless   = flag.Bool("headless", true, "Run the login browser without a window")
+	debug      = flag.Bool("debug", false, "Enable debug logging")
+)
+
+type command struct {
+	usage string
+	run   func(a *agent.Agent, args []string) error
+}
+
+var commands = map[string]command{
+	"login":   {"login", cmdLogin},
+	"active":  {"active", cmdActive},
+	"cleared": {"cleared [--from date] [--to date] [--fdid fdid]", cmdCleared},
+	"call":    {"call <callId|incidentNumber> [--days n]", cmdCall},
+	"oris":    {"oris", cmdORIs},
+	"whoami":  {"whoami", cmdWhoami},
+	"export":  {"export [--from date] [--to date] [--fdid fdid]", cmdExport},
+}
+
+func main() {
+	log.SetFlags(0)
+	flag.Usage = usage
+	flag.Parse()
+	if flag.NArg() < 1 {
+		usage()
+		os.Exit(2)
+	}
+	cmd, ok := commands[flag.Arg(0)]
+	if !ok {
+		usage()
+		os.Exit(2)
+	}
+	switch *format {
+	case "table", "json", "csv":
+	default:
+		log.Fatalf("ERR: unknown output format %q", *format)
+	}
+
+	a, err := newAgent()
+	if err != nil {
+		log.Fatalf("ERR: %s", err.Error())
+	}
+	if a.BaseUrl != "" && !strings.HasSuffix(a.BaseUrl, "/") {
+		a.BaseUrl += "/"
+	}
+	if flag.Arg(0) != "login" {
+		if err := resume(a); err != nil {
+			log.Fatalf("ERR: %s", err.Error())
+		}
+	}
+
+	err = cmd.run(a, flag.Args()[1:])
+	if errors.Is(err, agent.ErrNotAuthorized) && a.HasCredentials() {
+		// The cached session was revoked before it expired
+		if err = login(a); err == nil {
+			err = cmd.run(a, flag.Args()[1:])
+		}
+	}
+	if err != nil {
+		log.Fatalf("ERR: %s", err.Error())
+	}
+}
+
+// newAgent builds the agent from the configuration file, if any, with the
+// flags given on the command line taking precedence.
+func newAgent() (*agent.Agent, error) {
+	a := &agent.Agent{
+		Debug:          *debug,
+		BaseUrl:        *baseUrl,
+		FDID:           *fdid,
+		CDP:            *cdp,
+		DiagnosticsDir: *diagDir,
+		Launch:         agent.LaunchOptions{Headless: headless},
+	}
+	if os.Getenv("CADVIEW_USERNAME") != "" {
+		a.Credentials = agent.EnvCredentials{}
+	}
+	if *configFile == "" {
+		return withMFA(a), nil
+	}
+	cfg, err := config.Load(*configFile)
+	if err != nil {
+		return nil, err
+	}
+	in, err := cfg.Instance(*instance)
+	if err != nil {
+		return nil, err
+	}
+	ca := in.Agent()
+	zones, err := cfg.Zones.Load()
+	if err != nil {
+		return nil, err
+	}
+	if zones != nil {
+		ca.Tagger = zones
+	}
+	flag.Visit(func(f *flag.Flag) {
+		switch f.Name {
+		case "url":
+			ca.BaseUrl = a.BaseUrl
+		case "fdid":
+			ca.FDID = a.FDID
+		case "cdp":
+			ca.CDP = a.CDP
+		case "headless":
+			ca.Launch.Headless = a.Launch.Headless
+		case "diagnostics":
+			ca.DiagnosticsDir = a.DiagnosticsDir
+		case "debug":
+			ca.Debug = a.Debug
+		}
+	})
+	return withMFA(ca), nil
+}
+
+// withMFA answers second factor challenges with a TOTP code generated from
+// CADVIEW_TOTP_SECRET, or by asking for a code on the terminal.
+func withMFA(a *agent.Agent) *agent.Agent {
+	if a.MFA != nil {
+		return a
+	}
+	if secret := os.Getenv("CADVIEW_TOTP_SECRET"); secret != "" {
+		a.MFA = agent.TOTP{Secret: secret}
+		return a
+	}
+	if fi, err := os.Stdin.Stat(); err == nil && fi.Mode()&os.ModeCharDevice != 0 {
+		a.MFA = agent.MFAFunc(func(ch agent.MFAChallenge) (string, error) {
+			fmt.Fprintf(os.Stderr, "CadView is asking for a second factor")
+			if ch.Attempt > 1 {
+				fmt.Fprintf(os.Stderr, " (the last code was not accepted)")
+			}
+			fmt.Fprintf(os.Stderr, ".\nCode: ")
+			var code string
+			_, err := fmt.Fscanln(os.Stdin, &code)
+			return code, err
+		})
+	}
+	return a
+}
+
+func usage() {
+	fmt.Fprintf(os.Stderr, "Usage: cadview [flags] <command>\n\nCommands:\n")
+	for _, name := range []string{"login", "active", "cleared", "call", "oris", "whoami", "export"} {
+		fmt.Fprintf(os.Stderr, "  %s\n", commands[name].usage)
+	}
+	fmt.Fprintf(os.Stderr, "\nCredentials are read from CADVIEW_USERNAME and CADVIEW_PASSWORD.\n\nFlags:\n")
+	flag.PrintDefaults()
+}
+
+func cmdLogin(a *agent.Agent, args []string) error {
+	if err := login(a); err != nil {
+		return err
+	}
+	log.Printf("INFO: Logged in, session expires %s", time.Unix(a.GetAuth().ExpiresAt, 0).Format(time.RFC1123))
+	return nil
+}
+
+func cmdActive(a *agent.Agent, args []string) error {
+	calls, err := a.GetActiveCalls()
+	if err != nil {
+		return err
+	}
+	return render(os.Stdout, calls, callTable(calls))
+}
+
+func cmdCleared(a *agent.Agent, args []string) error {
+	calls, err := clearedCalls(a, "cleared", args)
+	if err != nil {
+		return err
+	}
+	return render(os.Stdout, calls, callTable(calls))
+}
+
+func cmdCall(a *agent.Agent, args []string) error {
+	fs := flag.NewFlagSet("call", flag.ExitOnError)
+	days := fs.Int("days", 30, "Days of cleared calls to search for an incident number")
+	fs.Parse(reorder(args))
+	if fs.NArg() != 1 {
+		return fmt.Errorf("usage: cadview call <callId|incidentNumber> [--days n]")
+	}
+
+	call, err := findCall(a, fs.Arg(0), *days)
+	if err != nil {
+		return err
+	}
+	cad, err := a.RetrieveCADCall(call)
+	if err != nil {
+		return err
+	}
+	if cad.Call.AllowedORI == nil {
+		cad.Call.AllowedORI = call.AllowedORI
+	}
+	return render(os.Stdout, cad, cadCallTable(cad))
+}
+
+func cmdORIs(a *agent.Agent, args []string) error {
+	oris, err := a.GetORIs()
+	if err != nil {
+		return err
+	}
+	t := table{header: []string{"ORI", "FDID", "AGENCY"}}
+	for _, o := range oris {
+		t.rows = append(t.rows, []string{o.ORI, o.FDID, o.AgencyName})
+	}
+	return render(os.Stdout, oris, t)
+}
+
+func cmdWhoami(a *agent.Agent, args []string) error {
+	id := a.Identity()
+	oris, err := a.PermittedORIs()
+	if err != nil {
+		return err
+	}
+	t := table{header: []string{"CLAIM", "VALUE"}}
+	t.rows = append(t.rows,
+		[]string{"sub", id.Subject},
+		[]string{"idp", id.IDP},
+		[]string{"auth_time", id.AuthTime.Format(time.RFC1123)},
+	)
+	if tok, err := a.AccessToken(); err == nil {
+		t.rows = append(t.rows,
+			[]string{"iss", tok.Issuer()},
+			[]string{"scope", strings.Join(tok.Scopes(), " ")},
+			[]string{"exp", tok.Expiry().Format(time.RFC1123)},
+		)
+	}
+	for _, o := range oris {
+		t.rows = append(t.rows, []string{"ori", fmt.Sprintf("%s (%s) %s", o.ORI, o.FDID, o.AgencyName)})
+	}
+	return render(os.Stdout, struct {
+		agent.Identity
+		ORIs []agent.ORIObj `json:"oris"`
+	}{id, oris}, t)
+}
+
+func cmdExport(a *agent.Agent, args []string) error {
+	calls, err := clearedCalls(a, "export", args)
+	if err != nil {
+		return err
+	}
+	out := make([]agent.CADCall, 0, len(calls))
+	for i, call := range calls {
+		if *debug {
+			log.Printf("DEBUG: Exporting %d/%d: %s", i+1, len(calls), call.IncidentNumber)
+		}
+		cad, err := a.RetrieveCADCall(call)
+		if err != nil {
+			return fmt.Errorf("%s: %w", call.IncidentNumber, err)
+		}
+		out = append(out, cad)
+	}
+	if *format != "table" {
+		return export.Write(os.Stdout, *format, out)
+	}
+	header, rows := export.Rows(out)
+	return render(os.Stdout, out, table{header: header, rows: rows})
+}
+
+// clearedCalls parses the --from, --to and --fdid flags of a command and
+// searches for cleared calls.
+func clearedCalls(a *agent.Agent, name string, args []string) ([]agent.CallObj, error) {
+	fs := flag.NewFlagSet(name, flag.ExitOnError)
+	from := fs.String("from", "", "Start date, like 2022-10-01 or an RFC 3339 time. Defaults to 24 hours ago.")
+	to := fs.String("to", "", "End date, like 2022-10-31 or an RFC 3339 time. Defaults to now.")
+	fd := fs.String("fdid", a.FDID, "FDID or ORI to search")
+	fs.Parse(reorder(args))
+	if fs.NArg() > 0 {
+		return nil, fmt.Errorf("usage: cadview %s [--from date] [--to date] [--fdid fdid]", name)
+	}
+
+	end := time.Now()
+	if *to != "" {
+		t, err := parseDate(*to, true)
+		if err != nil {
+			return nil, err
+		}
+		end = t
+	}
+	start := end.Add(-24 * time.Hour)
+	if *from != "" {
+		t, err := parseDate(*from, false)
+		if err != nil {
+			return nil, err
+		}
+		start = t
+	}
+	ori, err := resolveORI(a, *fd)
+	if err != nil {
+		return nil, err
+	}
+	return a.GetClearedCalls(start, end, ori)
+}
+
+// findCall finds a call by call ID or incident number, searching the active
+// calls and then the cleared calls of the last days.
+func findCall(a *agent.Agent, id string, days int) (agent.CallObj, error) {
+	isIncident := strings.Contains(id, "-")
+	if !isIncident {
+		var callID int64
+		if _, err := fmt.Sscanf(id, "%d", &callID); err != nil {
+			return agent.CallObj{}, fmt.Errorf("invalid call ID or incident number %q", id)
+		}
+		return agent.CallObj{CallID: callID}, nil
+	}
+
+	active, err := a.GetActiveCalls()
+	if err != nil {
+		return agent.CallObj{}, err
+	}
+	for _, c := range active {
+		if c.IncidentNumber == id {
+			return c, nil
+		}
+	}
+	ori, err := resolveORI(a, a.FDID)
+	if err != nil {
+		return agent.CallObj{}, err
+	}
+	cleared, err := a.GetClearedCalls(time.Now().AddDate(0, 0, -days), time.Now(), ori)
+	if err != nil {
+		return agent.CallObj{}, err
+	}
+	for _, c := range cleared {
+		if c.IncidentNumber == id {
+			return c, nil
+		}
+	}
+	return agent.CallObj{}, fmt.Errorf("incident %s not found in the last %d days", id, days)
+}
+
+// resolveORI converts an FDID to the ORI used for searches, passing ORIs
+// through unchanged.
+func resolveORI(a *agent.Agent, fdid string) (string, error) {
+	if fdid == "" {
+		return "", fmt.Errorf("no FDID given, use -fdid or CADVIEW_FDID")
+	}
+	oris, err := a.GetORIs()
+	if err != nil {
+		return "", err
+	}
+	if ori := agent.FDIDToORI(oris, fdid); ori != "" {
+		return ori, nil
+	}
+	return fdid, nil
+}
+
+// parseDate parses a date or RFC 3339 time. Dates used as the end of a range
+// include the whole day.
+func parseDate(s string, end bool) (time.Time, error) {
+	if t, err := time.Parse(time.RFC3339, s); err == nil {
+		return t, nil
+	}
+	t, err := time.ParseInLocation("2006-01-02", s, time.Local)
+	if err != nil {
+		return t, fmt.Errorf("invalid date %q, expected YYYY-MM-DD or RFC 3339", s)
+	}
+	if end {
+		// Days are not always 24 hours long
+		t = t.AddDate(0, 0, 1).Add(-time.Second)
+	}
+	return t, nil
+}
+
+// reorder moves flags ahead of positional arguments, so that flags may
+// follow them.
+func reorder(args []string) []string {
+	flags, positional := []string{}, []string{}
+	for i := 0; i < len(args); i++ {
+		if strings.HasPrefix(args[i], "-") {
+			flags = append(flags, args[i])
+			if !strings.Contains(args[i], "=") && i+1 < len(args) {
+				flags = append(flags, args[i+1])
+				i++
+			}
+			continue
+		}
+		positional = append(positional, args[i])
+	}
+	return append(flags, positional...)
+}
@@ -0,0 +1,131 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/dayvillefire/newworld-cadview-agent/agent"
+)
+
+func Test_ParseDate(t *testing.T) {
+	ny, err := time.LoadLocation("America/New_York")
+	if err != nil {
+		t.Skipf("no time zone database: %s", err.Error())
+	}
+	local := time.Local
+	time.Local = ny
+	defer func() { time.Local = local }()
+
+	for _, tc := range []struct {
+		in   string
+		end  bool
+		want time.Time
+	}{
+		{"2022-10-13", false, time.Date(2022, 10, 13, 0, 0, 0, 0, ny)},
+		{"2022-10-13", true, time.Date(2022, 10, 13, 23, 59, 59, 0, ny)},
+		// The end of a 25 hour day
+		{"2022-11-06", true, time.Date(2022, 11, 6, 23, 59, 59, 0, ny)},
+		{"2022-10-13T06:00:00Z", true, time.Date(2022, 10, 13, 6, 0, 0, 0, time.UTC)},
+	} {
+		got, err := parseDate(tc.in, tc.end)
+		if err != nil {
+			t.Fatalf("ERR: parseDate(%q): %s", tc.in, err.Error())
+		}
+		if !got.Equal(tc.want) {
+			t.Errorf("parseDate(%q, %v): expected %s, got %s", tc.in, tc.end, tc.want, got)
+		}
+	}
+	if _, err := parseDate("10/13/2022", false); err == nil {
+		t.Errorf("expected an error for an unsupported date format")
+	}
+}
+
+func Test_Reorder(t *testing.T) {
+	for _, tc := range []struct {
+		in   []string
+		want []string
+	}{
+		{[]string{"2022-00000345", "--days", "7"}, []string{"--days", "7", "2022-00000345"}},
+		{[]string{"2022-00000345", "--days=7"}, []string{"--days=7", "2022-00000345"}},
+		{[]string{"--from", "2022-10-01", "--fdid", "04040"}, []string{"--from", "2022-10-01", "--fdid", "04040"}},
+	} {
+		if got := reorder(tc.in); !reflect.DeepEqual(got, tc.want) {
+			t.Errorf("reorder(%q): expected %q, got %q", tc.in, tc.want, got)
+		}
+	}
+}
+
+// fakeCAD serves one active and one cleared call.
+func fakeCAD(t *testing.T) *agent.Agent {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var out any
+		switch {
+		case strings.HasSuffix(r.URL.Path, "/api/Call/GetActiveCalls"):
+			out = []agent.CallObj{{CallID: 2, IncidentNumber: "2022-00000346"}}
+		case strings.HasSuffix(r.URL.Path, "/api/Call/SearchClearedCalls"):
+			out = []agent.CallObj{{CallID: 1, IncidentNumber: "2022-00000345 ori=" + r.URL.Query().Get("ori")}}
+		case strings.HasSuffix(r.URL.Path, "/api/CadView/GetOrisForClearedCallSearch"):
+			out = []agent.ORIObj{{ORI: "26", FDID: "04040", AgencyName: "Pomfret"}}
+		default:
+			out = []any{}
+		}
+		json.NewEncoder(w).Encode(out)
+	}))
+	t.Cleanup(srv.Close)
+	a := &agent.Agent{BaseUrl: srv.URL + "/", FDID: "04040"}
+	a.SetAuth(agent.OidcObj{TokenType: "Bearer", AccessToken: "test"})
+	return a
+}
+
+func Test_FindCall(t *testing.T) {
+	a := fakeCAD(t)
+
+	if c, err := findCall(a, "591039", 30); err != nil || c.CallID != 591039 {
+		t.Fatalf("expected call ID 591039, got %#v, %v", c, err)
+	}
+	if c, err := findCall(a, "2022-00000346", 30); err != nil || c.CallID != 2 {
+		t.Fatalf("expected the active call, got %#v, %v", c, err)
+	}
+	// Cleared calls are searched using the ORI of the FDID
+	if c, err := findCall(a, "2022-00000345 ori=26", 30); err != nil || c.CallID != 1 {
+		t.Fatalf("expected the cleared call, got %#v, %v", c, err)
+	}
+	if _, err := findCall(a, "2022-99999999", 30); err == nil {
+		t.Fatalf("expected an error for an unknown incident")
+	}
+	if _, err := findCall(a, "call", 30); err == nil {
+		t.Fatalf("expected an error for an invalid call ID")
+	}
+}
+
+func Test_Render(t *testing.T) {
+	defer func(f string) { *format = f }(*format)
+	calls := []agent.CallObj{{CallID: 1, IncidentNumber: "2022-00000345", CallType: "Structure Fire", Location: "120 FREEDLEY RD, Pomfret", AllowedORI: []string{"04040", "04090"}}}
+
+	*format = "csv"
+	var buf bytes.Buffer
+	if err := render(&buf, calls, callTable(calls)); err != nil {
+		t.Fatalf("ERR: render: %s", err.Error())
+	}
+	want := "CALL ID,INCIDENT,TYPE,PRIORITY,STATUS,LOCATION,CREATED,PRIMARY UNIT,ORI\n" +
+		"1,2022-00000345,Structure Fire,,,\"120 FREEDLEY RD, Pomfret\",,,04040 04090\n"
+	if buf.String() != want {
+		t.Fatalf("unexpected CSV:\n%s", buf.String())
+	}
+
+	*format = "table"
+	buf.Reset()
+	if err := render(&buf, calls, table{header: []string{"ORI", "AGENCY"}, rows: [][]string{{"26", "Pomfret"}, {"9", "Woodstock"}}}); err != nil {
+		t.Fatalf("ERR: render: %s", err.Error())
+	}
+	want = "ORI  AGENCY\n26   Pomfret\n9    Woodstock\n"
+	if buf.String() != want {
+		t.Fatalf("unexpected table:\n%q", buf.String())
+	}
+}
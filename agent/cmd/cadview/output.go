@@ -0,0 +1,85 @@
+package main
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/dayvillefire/newworld-cadview-agent/agent"
+)
+
+// table is the tabular form of a result, used for table and CSV output.
+type table struct {
+	header []string
+	rows   [][]string
+}
+
+// render writes v as JSON, or t as an aligned table or CSV, depending on
+// the output format.
+func render(w io.Writer, v any, t table) error {
+	switch *format {
+	case "json":
+		enc := json.NewEncoder(w)
+		enc.SetIndent("", "  ")
+		return enc.Encode(v)
+	case "csv":
+		cw := csv.NewWriter(w)
+		cw.Write(t.header)
+		cw.WriteAll(t.rows)
+		return cw.Error()
+	default:
+		tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+		fmt.Fprintln(tw, strings.Join(t.header, "\t"))
+		for _, row := range t.rows {
+			fmt.Fprintln(tw, strings.Join(row, "\t"))
+		}
+		return tw.Flush()
+	}
+}
+
+var callHeader = []string{"CALL ID", "INCIDENT", "TYPE", "PRIORITY", "STATUS", "LOCATION", "CREATED", "PRIMARY UNIT", "ORI"}
+
+func callRow(c agent.CallObj) []string {
+	return []string{
+		fmt.Sprintf("%d", c.CallID),
+		c.IncidentNumber,
+		c.CallType,
+		c.CallPriority,
+		c.CallStatus,
+		c.Location,
+		c.CreatedDateTime,
+		c.PrimaryUnit,
+		strings.Join(c.AllowedORI, " "),
+	}
+}
+
+func callTable(calls []agent.CallObj) table {
+	t := table{header: callHeader}
+	for _, c := range calls {
+		t.rows = append(t.rows, callRow(c))
+	}
+	return t
+}
+
+// cadCallTable lists a single call, followed by its units and
+// narratives.
+func cadCallTable(cad agent.CADCall) table {
+	c := cad.Call
+	t := table{header: []string{"TIME", "KIND", "DETAIL"}}
+	t.rows = append(t.rows,
+		[]string{c.CreatedDateTime, "call", fmt.Sprintf("%s %s (%s) at %s", c.IncidentNumber, c.CallType, c.CallPriority, c.Location)},
+	)
+	if c.NatureOfCall != "" {
+		t.rows = append(t.rows, []string{"", "nature", c.NatureOfCall})
+	}
+	for _, u := range cad.Units {
+		t.rows = append(t.rows, []string{u.DispatchDateTime, "unit", u.UnitNumber + " " + agent.UnitStatus(u)})
+	}
+	for _, n := range cad.Narratives {
+		t.rows = append(t.rows, []string{n.EnteredDate, "narrative", n.Narrative})
+	}
+	return t
+}
@@ -0,0 +1,105 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"log"
+	"os"
+	"path/filepath"
+
+	"github.com/dayvillefire/newworld-cadview-agent/agent"
+)
+
+// cachedSession is the session saved by login.
+type cachedSession struct {
+	BaseUrl string        `json:"baseUrl"`
+	FDID    string        `json:"fdid"`
+	Auth    agent.OidcObj `json:"auth"`
+}
+
+func defaultSessionPath() string {
+	dir, err := os.UserCacheDir()
+	if err != nil {
+		return ".cadview-session.json"
+	}
+	return filepath.Join(dir, "cadview", "session.json")
+}
+
+// login logs in with a browser and caches the session.
+func login(a *agent.Agent) error {
+	if a.BaseUrl == "" || !a.HasCredentials() {
+		return fmt.Errorf("-url, CADVIEW_USERNAME and CADVIEW_PASSWORD are required to log in")
+	}
+	err := a.Init()
+	if *harFile != "" {
+		// Also wanted when the login fails
+		if herr := writeHAR(a, *harFile); herr != nil {
+			log.Printf("ERR: %s", herr.Error())
+		}
+	}
+	if err != nil {
+		return err
+	}
+	body, err := json.Marshal(cachedSession{BaseUrl: a.BaseUrl, FDID: a.FDID, Auth: a.GetAuth()})
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(*session), 0o700); err != nil {
+		return err
+	}
+	// The token grants access to the CAD system, so keep it private
+	return os.WriteFile(*session, body, 0o600)
+}
+
+// resume restores the cached session, logging in again if it has expired
+// and credentials are available.
+func resume(a *agent.Agent) error {
+	body, err := os.ReadFile(*session)
+	if errors.Is(err, fs.ErrNotExist) {
+		if a.HasCredentials() {
+			return login(a)
+		}
+		return fmt.Errorf("no cached session, run cadview login first")
+	}
+	if err != nil {
+		return err
+	}
+	var s cachedSession
+	if err := json.Unmarshal(body, &s); err != nil {
+		return fmt.Errorf("%s: %w", *session, err)
+	}
+	if a.BaseUrl != "" && a.BaseUrl != s.BaseUrl {
+		if a.HasCredentials() {
+			return login(a)
+		}
+		return fmt.Errorf("cached session is for %s, run cadview login first", s.BaseUrl)
+	}
+	a.BaseUrl = s.BaseUrl
+	if a.FDID == "" {
+		a.FDID = s.FDID
+	}
+	a.SetAuth(s.Auth)
+	if a.TokenExpired() {
+		if a.HasCredentials() {
+			log.Printf("INFO: Cached session has expired, logging in again")
+			return login(a)
+		}
+		return fmt.Errorf("cached session has expired, run cadview login")
+	}
+	return nil
+}
+
+// writeHAR writes the browser traffic of the last login to path.
+func writeHAR(a *agent.Agent, path string) error {
+	f, err := os.OpenFile(path, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0o600)
+	if err != nil {
+		return err
+	}
+	if err := a.WriteHAR(f); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
@@ -0,0 +1,251 @@
+package config
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/dayvillefire/newworld-cadview-agent/agent"
+	"github.com/dayvillefire/newworld-cadview-agent/agent/alert"
+	"github.com/dayvillefire/newworld-cadview-agent/agent/export"
+	"github.com/dayvillefire/newworld-cadview-agent/agent/geo"
+	"github.com/dayvillefire/newworld-cadview-agent/agent/notify"
+)
+
+// Job is a scheduled export.
+type Job interface {
+	Start() error
+	Stop()
+}
+
+// Agent returns an Agent for the instance. It still needs to be
+// initialized.
+func (in Instance) Agent() *agent.Agent {
+	a := &agent.Agent{
+		Debug:          in.Debug,
+		BaseUrl:        in.URL,
+		Credentials:    in.Credentials.Provider(),
+		FDID:           in.FDID,
+		CDP:            in.CDP,
+		DiagnosticsDir: in.DiagnosticsDir,
+		TokenAudience:  in.TokenAudience,
+		VerifyToken:    in.VerifyToken,
+	}
+	if l := in.Login; l != nil {
+		a.Profile = &agent.LoginProfile{
+			LoginPath:        l.Path,
+			UsernameSelector: l.UsernameSelector,
+			PasswordSelector: l.PasswordSelector,
+			SubmitSelector:   l.SubmitSelector,
+			MFASelector:      l.MFASelector,
+			SuccessSelector:  l.SuccessSelector,
+			SuccessURL:       l.SuccessURL,
+			StorageKeyPrefix: l.StorageKeyPrefix,
+		}
+	}
+	b := in.Browser
+	a.Launch = agent.LaunchOptions{
+		Headless:    b.Headless,
+		ExecPath:    b.ExecPath,
+		Proxy:       b.Proxy,
+		Flags:       b.Flags,
+		UserDataDir: b.UserDataDir,
+		KeepAlive:   b.KeepAlive,
+	}
+	// Checked by Validate
+	a.Launch.WindowWidth, a.Launch.WindowHeight, _ = b.windowSize()
+	if c := in.Capture; c != nil {
+		a.Capture = &agent.Capture{
+			Include:     c.Include,
+			Exclude:     c.Exclude,
+			IncludeMIME: c.IncludeMIME,
+			ExcludeMIME: c.ExcludeMIME,
+			MaxBytes:    c.MaxBytes,
+			MaxEntries:  c.MaxEntries,
+			Debug:       in.Debug,
+		}
+	}
+	if in.MFA.TOTPSecret != "" {
+		a.MFA = agent.TOTP{Secret: in.MFA.TOTPSecret, Digits: in.MFA.Digits, Period: time.Duration(in.MFA.Period)}
+	}
+	if !strings.HasSuffix(a.BaseUrl, "/") {
+		a.BaseUrl += "/"
+	}
+	return a
+}
+
+// Provider returns the credential provider for the configured source.
+func (c Credentials) Provider() agent.CredentialProvider {
+	switch c.Source {
+	case CredentialsEnv:
+		return agent.EnvCredentials{UsernameVar: c.UsernameVar, PasswordVar: c.PasswordVar}
+	case CredentialsFile:
+		return agent.FileCredentials{Path: c.Path}
+	case CredentialsCommand:
+		return agent.CommandCredentials{Command: c.Command, Username: c.Username}
+	case CredentialsSystemd:
+		return agent.SystemdCredentials{UsernameName: c.UsernameCredential, PasswordName: c.PasswordCredential}
+	}
+	return agent.StaticCredentials{Username: c.Username, Password: c.Password}
+}
+
+// Watcher returns a Watcher polling a, or nil if polling is disabled.
+func (in Instance) Watcher(a *agent.Agent) *agent.Watcher {
+	if in.Poll < 0 {
+		return nil
+	}
+	interval := time.Duration(in.Poll)
+	if interval == 0 {
+		interval = 30 * time.Second
+	}
+	return &agent.Watcher{
+		Agent:    a,
+		Interval: interval,
+		Details:  in.Details == nil || *in.Details,
+	}
+}
+
+// Notifier returns the notifier for the sink.
+func (s Sink) Notifier() (notify.Notifier, error) {
+	filter := notify.Filter{Statuses: s.Statuses, SkipClosed: s.SkipClosed}
+	switch s.Type {
+	case SinkSlack:
+		return &notify.Slack{
+			WebhookURL: s.WebhookURL,
+			Token:      s.Token,
+			Channel:    s.Channel,
+			MapURL:     s.MapURL,
+			Filter:     filter,
+		}, nil
+	case SinkTeams:
+		return &notify.Teams{
+			WebhookURL: s.WebhookURL,
+			MapURL:     s.MapURL,
+			ThemeColor: s.ThemeColor,
+			Filter:     filter,
+		}, nil
+	case SinkEmail:
+		return &notify.Email{
+			SMTP:          s.SMTP.notify(),
+			CallTypes:     s.CallTypes,
+			Priorities:    s.Priorities,
+			SubjectPrefix: s.SubjectPrefix,
+			MapURL:        s.MapURL,
+			AllEvents:     s.AllEvents,
+		}, nil
+	}
+	return nil, fmt.Errorf("unknown sink type %q", s.Type)
+}
+
+// Job returns the scheduled job for the export, using a.
+func (e Export) Job(a *agent.Agent) (Job, error) {
+	switch e.Type {
+	case ExportDigest:
+		return &notify.DigestJob{
+			Agent:         a,
+			SMTP:          e.SMTP.notify(),
+			FDIDs:         e.FDIDs,
+			At:            e.At,
+			Period:        time.Duration(e.Period),
+			Top:           e.Top,
+			SubjectPrefix: e.SubjectPrefix,
+		}, nil
+	case ExportCalls:
+		return &export.Job{
+			Agent:  a,
+			FDIDs:  e.FDIDs,
+			At:     e.At,
+			Period: time.Duration(e.Period),
+			Format: e.Format,
+			Path:   e.Path,
+		}, nil
+	}
+	return nil, fmt.Errorf("unknown export type %q", e.Type)
+}
+
+// Load reads the zone files, returning nil if there are none.
+func (z Zones) Load() (*geo.Set, error) {
+	if len(z.Paths) == 0 {
+		return nil, nil
+	}
+	return geo.LoadFiles(z.NameProperty, z.Paths...)
+}
+
+// AlertEngines are the engines evaluating the configured alert rules. Each
+// is nil if it has no rules.
+type AlertEngines struct {
+	Keywords   *alert.KeywordEngine
+	Geofences  *alert.GeofenceEngine
+	Watchlists *alert.WatchlistEngine
+}
+
+// Handlers returns the watcher handlers of the engines.
+func (e AlertEngines) Handlers() []func(agent.Event) {
+	out := []func(agent.Event){}
+	if e.Keywords != nil {
+		out = append(out, e.Keywords.Handle)
+	}
+	if e.Geofences != nil {
+		out = append(out, e.Geofences.Handle)
+	}
+	if e.Watchlists != nil {
+		out = append(out, e.Watchlists.Handle)
+	}
+	return out
+}
+
+// Engines returns the engines for the alert rules, which send alerts
+// through router. zones are the zones watched by geofences. Watchlists are
+// loaded, but not reloaded until their engine is started.
+func (a Alerts) Engines(zones *geo.Set, router *alert.Router) (AlertEngines, error) {
+	out := AlertEngines{}
+	if len(a.Keywords) > 0 {
+		out.Keywords = &alert.KeywordEngine{Router: router}
+		for _, k := range a.Keywords {
+			out.Keywords.Rules = append(out.Keywords.Rules, k.rule())
+		}
+		if err := out.Keywords.Compile(); err != nil {
+			return out, err
+		}
+	}
+	if len(a.Geofences) > 0 {
+		out.Geofences = &alert.GeofenceEngine{Set: zones, Router: router}
+		for _, g := range a.Geofences {
+			out.Geofences.Rules = append(out.Geofences.Rules, &alert.GeofenceRule{Name: g.Name, Zones: g.Zones, Notifiers: g.Sinks})
+		}
+	}
+	if len(a.Watchlists) > 0 {
+		out.Watchlists = &alert.WatchlistEngine{Router: router}
+		for _, w := range a.Watchlists {
+			out.Watchlists.Lists = append(out.Watchlists.Lists, &alert.Watchlist{Name: w.Name, Kind: w.Kind, Path: w.Path, Notifiers: w.Sinks})
+		}
+		if err := out.Watchlists.Load(); err != nil {
+			return out, err
+		}
+	}
+	return out, nil
+}
+
+func (k Keyword) rule() *alert.KeywordRule {
+	return &alert.KeywordRule{
+		Name:          k.Name,
+		Keywords:      k.Keywords,
+		Patterns:      k.Patterns,
+		CaseSensitive: k.CaseSensitive,
+		Fields:        k.Fields,
+		Notifiers:     k.Sinks,
+	}
+}
+
+func (s SMTP) notify() notify.SMTP {
+	return notify.SMTP{
+		Host:               s.Host,
+		Port:               s.Port,
+		Username:           s.Username,
+		Password:           s.Password,
+		From:               s.From,
+		To:                 s.To,
+		StartTLS:           s.StartTLS,
+		InsecureSkipVerify: s.InsecureSkipVerify,
+	}
+}
@@ -0,0 +1,660 @@
+// Package config loads YAML or TOML configuration declaring CadView
+// instances, notification sinks and scheduled exports, shared by all of the
+// commands in this module.
+//
+// String values may refer to environment variables as ${NAME}, or
+// ${NAME:-default} to use a default when NAME is not set. "$$" is a
+// literal dollar sign.
+package config
+
+import (
+	"errors"
+	"fmt"
+	"net/url"
+	"os"
+	"path/filepath"
+	"reflect"
+	"regexp"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/BurntSushi/toml"
+	"github.com/dayvillefire/newworld-cadview-agent/agent"
+	"github.com/dayvillefire/newworld-cadview-agent/agent/alert"
+	"gopkg.in/yaml.v3"
+)
+
+// Config is the root of a configuration file.
+type Config struct {
+	// Instances are the CadView systems to log in to. The first is the
+	// default for commands which use a single instance.
+	Instances []Instance `yaml:"instances" toml:"instances"`
+	// Server configures cadview-server.
+	Server Server `yaml:"server" toml:"server"`
+	// Sinks are the destinations for active call notifications.
+	Sinks []Sink `yaml:"sinks" toml:"sinks"`
+	// Exports are the scheduled reports and exports.
+	Exports []Export `yaml:"exports" toml:"exports"`
+	// Zones are GeoJSON areas, such as first-due areas and target hazard
+	// sites, which calls are tagged with and geofence alerts watch.
+	Zones Zones `yaml:"zones" toml:"zones"`
+	// Alerts are the rules which raise alerts on active calls.
+	Alerts Alerts `yaml:"alerts" toml:"alerts"`
+}
+
+// Instance is a single CadView login.
+type Instance struct {
+	// Name identifies the instance to sinks, exports and the -instance
+	// flag of commands.
+	Name string `yaml:"name" toml:"name"`
+	// URL is the base URL of the CadView instance.
+	URL string `yaml:"url" toml:"url"`
+	// FDID is the ORI/FDID associated with the login.
+	FDID string `yaml:"fdid" toml:"fdid"`
+	// CDP is the URL of a remote devtools instance, if not using a local
+	// browser.
+	CDP string `yaml:"cdp" toml:"cdp"`
+	// CDPSession keeps the login tab open in the remote browser, picking
+	// up renewed tokens and logging in again after the browser restarts.
+	// Instances with the same cdp share one connection.
+	CDPSession  bool        `yaml:"cdp_session" toml:"cdp_session"`
+	Credentials Credentials `yaml:"credentials" toml:"credentials"`
+	MFA         MFA         `yaml:"mfa" toml:"mfa"`
+	// Login overrides the login page details for CadView releases which
+	// differ from the default. See agent.LoginProfile.
+	Login *Login `yaml:"login" toml:"login"`
+	// Browser controls the local Chrome used to log in.
+	Browser Browser `yaml:"browser" toml:"browser"`
+	// TokenAudience, if set, is the audience required of access tokens.
+	// VerifyToken checks their signatures against the signing keys of the
+	// instance.
+	TokenAudience string `yaml:"token_audience" toml:"token_audience"`
+	VerifyToken   bool   `yaml:"verify_token" toml:"verify_token"`
+	// Capture limits the browser traffic which is recorded.
+	Capture *Capture `yaml:"capture" toml:"capture"`
+	// Poll is the active call polling interval. Defaults to 30 seconds; a
+	// negative value disables polling.
+	Poll Duration `yaml:"poll" toml:"poll"`
+	// Details retrieves units and narratives on every poll, which is
+	// required for unit status and narrative notifications. Defaults to
+	// true.
+	Details *bool `yaml:"details" toml:"details"`
+	// DiagnosticsDir is where a screenshot, the page HTML and the browser
+	// logs are saved when a login fails.
+	DiagnosticsDir string `yaml:"diagnostics_dir" toml:"diagnostics_dir"`
+	Debug          bool   `yaml:"debug" toml:"debug"`
+}
+
+// Credential sources
+const (
+	// CredentialsInline uses Username and Password from the file, usually
+	// as environment variable references.
+	CredentialsInline = ""
+	// CredentialsEnv reads UsernameVar and PasswordVar at login time.
+	CredentialsEnv = "env"
+	// CredentialsFile reads the username and password lines of Path, which
+	// must not be accessible to other users.
+	CredentialsFile = "file"
+	// CredentialsCommand runs Command, like a secrets manager CLI.
+	CredentialsCommand = "command"
+	// CredentialsSystemd reads systemd service credentials.
+	CredentialsSystemd = "systemd"
+)
+
+// Credentials configures where the login credentials of an instance come
+// from. Except for inline credentials, they are fetched at each login
+// rather than when the file is loaded.
+type Credentials struct {
+	Source   string `yaml:"source" toml:"source"`
+	Username string `yaml:"username" toml:"username"`
+	Password string `yaml:"password" toml:"password"`
+	// UsernameVar and PasswordVar default to CADVIEW_USERNAME and
+	// CADVIEW_PASSWORD.
+	UsernameVar string   `yaml:"username_var" toml:"username_var"`
+	PasswordVar string   `yaml:"password_var" toml:"password_var"`
+	Path        string   `yaml:"path" toml:"path"`
+	Command     []string `yaml:"command" toml:"command"`
+	// UsernameCredential and PasswordCredential are the systemd credential
+	// names, defaulting to cadview-username and cadview-password.
+	UsernameCredential string `yaml:"username_credential" toml:"username_credential"`
+	PasswordCredential string `yaml:"password_credential" toml:"password_credential"`
+}
+
+// MFA configures the answer to a second factor challenge during login.
+type MFA struct {
+	// TOTPSecret is the base32 authenticator secret, usually as an
+	// environment variable reference.
+	TOTPSecret string `yaml:"totp_secret" toml:"totp_secret"`
+	// Digits and Period default to 6 and 30 seconds.
+	Digits int      `yaml:"digits" toml:"digits"`
+	Period Duration `yaml:"period" toml:"period"`
+}
+
+// Login describes the login page of an instance. Empty values use the
+// defaults in agent.DefaultLoginProfile.
+type Login struct {
+	Path             string `yaml:"path" toml:"path"`
+	UsernameSelector string `yaml:"username_selector" toml:"username_selector"`
+	PasswordSelector string `yaml:"password_selector" toml:"password_selector"`
+	SubmitSelector   string `yaml:"submit_selector" toml:"submit_selector"`
+	MFASelector      string `yaml:"mfa_selector" toml:"mfa_selector"`
+	SuccessSelector  string `yaml:"success_selector" toml:"success_selector"`
+	SuccessURL       string `yaml:"success_url" toml:"success_url"`
+	StorageKeyPrefix string `yaml:"storage_key_prefix" toml:"storage_key_prefix"`
+}
+
+// Browser configures the local Chrome used to log in. See
+// agent.LaunchOptions.
+type Browser struct {
+	// Headless defaults to true.
+	Headless *bool  `yaml:"headless" toml:"headless"`
+	ExecPath string `yaml:"exec_path" toml:"exec_path"`
+	Proxy    string `yaml:"proxy" toml:"proxy"`
+	// WindowSize is the window width and height, like "1280x1024".
+	WindowSize  string   `yaml:"window_size" toml:"window_size"`
+	Flags       []string `yaml:"flags" toml:"flags"`
+	UserDataDir string   `yaml:"user_data_dir" toml:"user_data_dir"`
+	KeepAlive   bool     `yaml:"keep_alive" toml:"keep_alive"`
+}
+
+// windowSize parses WindowSize.
+func (b Browser) windowSize() (int, int, error) {
+	if b.WindowSize == "" {
+		return 0, 0, nil
+	}
+	var w, h int
+	if n, err := fmt.Sscanf(b.WindowSize, "%dx%d", &w, &h); err != nil || n != 2 || w <= 0 || h <= 0 {
+		return 0, 0, fmt.Errorf("window_size must be like 1280x1024, got %q", b.WindowSize)
+	}
+	return w, h, nil
+}
+
+// Capture filters and bounds the recorded browser traffic. See
+// agent.Capture.
+type Capture struct {
+	Include     []string `yaml:"include" toml:"include"`
+	Exclude     []string `yaml:"exclude" toml:"exclude"`
+	IncludeMIME []string `yaml:"include_mime" toml:"include_mime"`
+	ExcludeMIME []string `yaml:"exclude_mime" toml:"exclude_mime"`
+	MaxBytes    int64    `yaml:"max_bytes" toml:"max_bytes"`
+	MaxEntries  int      `yaml:"max_entries" toml:"max_entries"`
+}
+
+// Server configures cadview-server.
+type Server struct {
+	// Instance is the name of the instance to serve. Defaults to the first.
+	Instance    string   `yaml:"instance" toml:"instance"`
+	Listen      string   `yaml:"listen" toml:"listen"`
+	APIKeys     []string `yaml:"api_keys" toml:"api_keys"`
+	CacheTTL    Duration `yaml:"cache_ttl" toml:"cache_ttl"`
+	ProxyListen string   `yaml:"proxy_listen" toml:"proxy_listen"`
+	GRPCListen  string   `yaml:"grpc_listen" toml:"grpc_listen"`
+}
+
+// Sink types
+const (
+	SinkSlack = "slack"
+	SinkTeams = "teams"
+	SinkEmail = "email"
+)
+
+// Sink is a destination for active call notifications. See the notify
+// package for the meaning of each field.
+type Sink struct {
+	Name string `yaml:"name" toml:"name"`
+	// Type is SinkSlack, SinkTeams or SinkEmail.
+	Type string `yaml:"type" toml:"type"`
+	// Instance is the name of the instance to watch. Defaults to the first.
+	Instance string `yaml:"instance" toml:"instance"`
+
+	WebhookURL string `yaml:"webhook_url" toml:"webhook_url"`
+	Token      string `yaml:"token" toml:"token"`
+	Channel    string `yaml:"channel" toml:"channel"`
+	MapURL     string `yaml:"map_url" toml:"map_url"`
+	ThemeColor string `yaml:"theme_color" toml:"theme_color"`
+	// Statuses and SkipClosed filter Slack and Teams messages.
+	Statuses   []string `yaml:"statuses" toml:"statuses"`
+	SkipClosed bool     `yaml:"skip_closed" toml:"skip_closed"`
+
+	SMTP          SMTP     `yaml:"smtp" toml:"smtp"`
+	CallTypes     []string `yaml:"call_types" toml:"call_types"`
+	Priorities    []string `yaml:"priorities" toml:"priorities"`
+	SubjectPrefix string   `yaml:"subject_prefix" toml:"subject_prefix"`
+	AllEvents     bool     `yaml:"all_events" toml:"all_events"`
+}
+
+// SMTP is a mail server and the recipients of messages sent through it.
+type SMTP struct {
+	Host               string   `yaml:"host" toml:"host"`
+	Port               int      `yaml:"port" toml:"port"`
+	Username           string   `yaml:"username" toml:"username"`
+	Password           string   `yaml:"password" toml:"password"`
+	From               string   `yaml:"from" toml:"from"`
+	To                 []string `yaml:"to" toml:"to"`
+	StartTLS           bool     `yaml:"starttls" toml:"starttls"`
+	InsecureSkipVerify bool     `yaml:"insecure_skip_verify" toml:"insecure_skip_verify"`
+}
+
+// Export types
+const (
+	// ExportDigest mails a summary of the period's calls.
+	ExportDigest = "digest"
+	// ExportCalls writes the full records of the period's calls to a file.
+	ExportCalls = "calls"
+)
+
+// Export is a daily scheduled report or export.
+type Export struct {
+	Name string `yaml:"name" toml:"name"`
+	// Type is ExportDigest or ExportCalls.
+	Type string `yaml:"type" toml:"type"`
+	// Instance is the name of the instance to use. Defaults to the first.
+	Instance string `yaml:"instance" toml:"instance"`
+	// FDIDs are the departments to include. Defaults to the instance FDID.
+	FDIDs []string `yaml:"fdids" toml:"fdids"`
+	// At is the local time of day to run, like "06:00".
+	At string `yaml:"at" toml:"at"`
+	// Period is the length of time covered. Defaults to 24 hours.
+	Period Duration `yaml:"period" toml:"period"`
+
+	// SMTP, Top and SubjectPrefix apply to digests.
+	SMTP          SMTP   `yaml:"smtp" toml:"smtp"`
+	Top           int    `yaml:"top" toml:"top"`
+	SubjectPrefix string `yaml:"subject_prefix" toml:"subject_prefix"`
+
+	// Path and Format apply to call exports. See export.Job.
+	Path   string `yaml:"path" toml:"path"`
+	Format string `yaml:"format" toml:"format"`
+}
+
+// Zones configures the GeoJSON zones which calls are tagged with. See the
+// geo package.
+type Zones struct {
+	// Paths are the GeoJSON files to load.
+	Paths []string `yaml:"paths" toml:"paths"`
+	// NameProperty is the feature property naming each zone. Defaults to
+	// "name".
+	NameProperty string `yaml:"name_property" toml:"name_property"`
+}
+
+// Alerts configures the alert rules evaluated against the active calls of
+// an instance. Alerts are sent to the named sinks, or to every sink.
+type Alerts struct {
+	// Instance is the name of the instance to watch. Defaults to the first.
+	Instance   string      `yaml:"instance" toml:"instance"`
+	Keywords   []Keyword   `yaml:"keywords" toml:"keywords"`
+	Geofences  []Geofence  `yaml:"geofences" toml:"geofences"`
+	Watchlists []Watchlist `yaml:"watchlists" toml:"watchlists"`
+}
+
+// Keyword raises an alert when a call or narrative mentions one of its
+// keywords or patterns. See alert.KeywordRule.
+type Keyword struct {
+	Name          string   `yaml:"name" toml:"name"`
+	Keywords      []string `yaml:"keywords" toml:"keywords"`
+	Patterns      []string `yaml:"patterns" toml:"patterns"`
+	CaseSensitive bool     `yaml:"case_sensitive" toml:"case_sensitive"`
+	// Fields are the call fields checked, defaulting to narrative and
+	// natureOfCall.
+	Fields []string `yaml:"fields" toml:"fields"`
+	// Sinks are the names of the sinks which receive alerts. Defaults to
+	// every sink.
+	Sinks []string `yaml:"sinks" toml:"sinks"`
+}
+
+// Geofence raises an alert when a call lands in one of its zones. See
+// alert.GeofenceRule.
+type Geofence struct {
+	Name string `yaml:"name" toml:"name"`
+	// Zones are the names of the watched zones. Defaults to every zone.
+	Zones []string `yaml:"zones" toml:"zones"`
+	// Sinks are the names of the sinks which receive alerts. Defaults to
+	// every sink.
+	Sinks []string `yaml:"sinks" toml:"sinks"`
+}
+
+// Watchlist raises an alert when a listed unit is assigned to a call, or a
+// call is at a listed address. The file is reloaded when it changes. See
+// alert.Watchlist.
+type Watchlist struct {
+	Name string `yaml:"name" toml:"name"`
+	// Kind is "unit" or "address".
+	Kind string `yaml:"kind" toml:"kind"`
+	Path string `yaml:"path" toml:"path"`
+	// Sinks are the names of the sinks which receive alerts. Defaults to
+	// every sink.
+	Sinks []string `yaml:"sinks" toml:"sinks"`
+}
+
+// Duration is a time.Duration written like "30s" or "5m".
+type Duration time.Duration
+
+// UnmarshalText implements encoding.TextUnmarshaler, which is used for
+// TOML.
+func (d *Duration) UnmarshalText(b []byte) error {
+	v, err := time.ParseDuration(string(b))
+	if err != nil {
+		return err
+	}
+	*d = Duration(v)
+	return nil
+}
+
+// UnmarshalYAML implements yaml.Unmarshaler.
+func (d *Duration) UnmarshalYAML(n *yaml.Node) error {
+	return d.UnmarshalText([]byte(n.Value))
+}
+
+// Load reads a configuration file, choosing the format by its extension,
+// then expands environment variables and validates it.
+func Load(path string) (*Config, error) {
+	body, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	c := &Config{}
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".yaml", ".yml":
+		dec := yaml.NewDecoder(strings.NewReader(string(body)))
+		dec.KnownFields(true)
+		if err := dec.Decode(c); err != nil {
+			return nil, fmt.Errorf("%s: %w", path, err)
+		}
+	case ".toml":
+		md, err := toml.Decode(string(body), c)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", path, err)
+		}
+		if undecoded := md.Undecoded(); len(undecoded) > 0 {
+			return nil, fmt.Errorf("%s: unknown keys %v", path, undecoded)
+		}
+	default:
+		return nil, fmt.Errorf("%s: unknown configuration format, expected .yaml, .yml or .toml", path)
+	}
+
+	if err := c.Expand(os.LookupEnv); err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	if err := c.Validate(); err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	return c, nil
+}
+
+var envRef = regexp.MustCompile(`\$\$|\$\{([A-Za-z_][A-Za-z0-9_]*)(:-([^}]*))?\}`)
+
+// Expand replaces environment variable references in every string value,
+// returning an error naming any variables which are not set and have no
+// default.
+func (c *Config) Expand(lookup func(string) (string, bool)) error {
+	missing := map[string]bool{}
+	expand := func(s string) string {
+		return envRef.ReplaceAllStringFunc(s, func(ref string) string {
+			if ref == "$$" {
+				return "$"
+			}
+			m := envRef.FindStringSubmatch(ref)
+			if v, ok := lookup(m[1]); ok {
+				return v
+			}
+			if m[2] == "" {
+				missing[m[1]] = true
+			}
+			return m[3]
+		})
+	}
+	expandValue(reflect.ValueOf(c).Elem(), expand)
+
+	if len(missing) > 0 {
+		names := []string{}
+		for name := range missing {
+			names = append(names, name)
+		}
+		slices.Sort(names)
+		return fmt.Errorf("environment variables not set: %s", strings.Join(names, ", "))
+	}
+	return nil
+}
+
+func expandValue(v reflect.Value, expand func(string) string) {
+	switch v.Kind() {
+	case reflect.String:
+		v.SetString(expand(v.String()))
+	case reflect.Struct:
+		for i := 0; i < v.NumField(); i++ {
+			if v.Type().Field(i).IsExported() {
+				expandValue(v.Field(i), expand)
+			}
+		}
+	case reflect.Slice:
+		for i := 0; i < v.Len(); i++ {
+			expandValue(v.Index(i), expand)
+		}
+	case reflect.Pointer:
+		if !v.IsNil() {
+			expandValue(v.Elem(), expand)
+		}
+	case reflect.Map:
+		// Map values are not addressable, so expand a copy of each
+		iter := v.MapRange()
+		for iter.Next() {
+			e := reflect.New(iter.Value().Type()).Elem()
+			e.Set(iter.Value())
+			expandValue(e, expand)
+			v.SetMapIndex(iter.Key(), e)
+		}
+	}
+}
+
+// Validate checks the configuration for missing and inconsistent values,
+// returning all of the problems found.
+func (c *Config) Validate() error {
+	errs := []error{}
+	fail := func(format string, args ...any) {
+		errs = append(errs, fmt.Errorf(format, args...))
+	}
+
+	if len(c.Instances) == 0 {
+		fail("no instances defined")
+	}
+	names := map[string]bool{}
+	for i, in := range c.Instances {
+		where := fmt.Sprintf("instances[%d]", i)
+		if in.Name != "" {
+			where = fmt.Sprintf("instance %q", in.Name)
+			if names[in.Name] {
+				fail("%s: duplicate name", where)
+			}
+			names[in.Name] = true
+		} else if len(c.Instances) > 1 {
+			fail("%s: name is required when there is more than one instance", where)
+		}
+		if u, err := url.Parse(in.URL); err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			fail("%s: url must be an http or https URL, got %q", where, in.URL)
+		}
+		if in.MFA.TOTPSecret != "" {
+			if _, err := (agent.TOTP{Secret: in.MFA.TOTPSecret}).CodeAt(time.Now()); err != nil {
+				fail("%s: mfa.totp_secret: %s", where, err.Error())
+			}
+		}
+		if _, _, err := in.Browser.windowSize(); err != nil {
+			fail("%s: browser.%s", where, err.Error())
+		}
+		if in.CDPSession && in.CDP == "" {
+			fail("%s: cdp_session requires cdp", where)
+		}
+		if in.CDP != "" && (in.Browser.Headless != nil || in.Browser.ExecPath != "" || in.Browser.Proxy != "" || len(in.Browser.Flags) > 0) {
+			fail("%s: browser launch options do not apply with cdp", where)
+		}
+		switch cr := in.Credentials; cr.Source {
+		case CredentialsInline:
+			if cr.Username == "" || cr.Password == "" {
+				fail("%s: credentials.username and credentials.password are required", where)
+			}
+		case CredentialsEnv, CredentialsSystemd:
+		case CredentialsFile:
+			if cr.Path == "" {
+				fail("%s: credentials.path is required", where)
+			}
+		case CredentialsCommand:
+			if len(cr.Command) == 0 {
+				fail("%s: credentials.command is required", where)
+			}
+		default:
+			fail("%s: credentials.source must be env, file, command or systemd, got %q", where, cr.Source)
+		}
+	}
+	instance := func(where, name string) {
+		if name != "" && !names[name] {
+			fail("%s: unknown instance %q", where, name)
+		}
+	}
+	instance("server", c.Server.Instance)
+
+	sinks := map[string]bool{}
+	for i, s := range c.Sinks {
+		where := fmt.Sprintf("sinks[%d]", i)
+		if s.Name != "" {
+			where = fmt.Sprintf("sink %q", s.Name)
+			if sinks[s.Name] {
+				fail("%s: duplicate name", where)
+			}
+			sinks[s.Name] = true
+		}
+		instance(where, s.Instance)
+		switch s.Type {
+		case SinkSlack:
+			if s.WebhookURL == "" && (s.Token == "" || s.Channel == "") {
+				fail("%s: webhook_url, or token and channel, are required", where)
+			}
+		case SinkTeams:
+			if s.WebhookURL == "" {
+				fail("%s: webhook_url is required", where)
+			}
+		case SinkEmail:
+			validateSMTP(fail, where, s.SMTP)
+		default:
+			fail("%s: type must be %s, %s or %s, got %q", where, SinkSlack, SinkTeams, SinkEmail, s.Type)
+		}
+	}
+
+	for i, e := range c.Exports {
+		where := fmt.Sprintf("exports[%d]", i)
+		if e.Name != "" {
+			where = fmt.Sprintf("export %q", e.Name)
+		}
+		instance(where, e.Instance)
+		if e.At != "" {
+			if _, err := time.Parse("15:04", strings.TrimSpace(e.At)); err != nil {
+				fail("%s: at must be a time like 06:00, got %q", where, e.At)
+			}
+		}
+		switch e.Type {
+		case ExportDigest:
+			validateSMTP(fail, where, e.SMTP)
+		case ExportCalls:
+			if e.Path == "" {
+				fail("%s: path is required", where)
+			}
+			if e.Format != "" && e.Format != "json" && e.Format != "csv" {
+				fail("%s: format must be json or csv, got %q", where, e.Format)
+			}
+		default:
+			fail("%s: type must be %s or %s, got %q", where, ExportDigest, ExportCalls, e.Type)
+		}
+	}
+
+	instance("alerts", c.Alerts.Instance)
+	rules := map[string]bool{}
+	rule := func(where, name string, sinkNames []string) {
+		if name == "" {
+			fail("%s: name is required", where)
+		} else if rules[name] {
+			fail("%s: duplicate name %q", where, name)
+		}
+		rules[name] = true
+		for _, s := range sinkNames {
+			if !sinks[s] {
+				fail("%s: unknown sink %q", where, s)
+			}
+		}
+	}
+	for i, k := range c.Alerts.Keywords {
+		where := fmt.Sprintf("alerts.keywords[%d]", i)
+		rule(where, k.Name, k.Sinks)
+		if len(k.Keywords) == 0 && len(k.Patterns) == 0 {
+			fail("%s: keywords or patterns are required", where)
+		}
+		if k.Name != "" {
+			if err := k.rule().Compile(); err != nil {
+				fail("%s: %s", where, err.Error())
+			}
+		}
+	}
+	for i, g := range c.Alerts.Geofences {
+		rule(fmt.Sprintf("alerts.geofences[%d]", i), g.Name, g.Sinks)
+	}
+	for i, w := range c.Alerts.Watchlists {
+		where := fmt.Sprintf("alerts.watchlists[%d]", i)
+		rule(where, w.Name, w.Sinks)
+		if w.Kind != alert.WatchUnits && w.Kind != alert.WatchAddresses {
+			fail("%s: kind must be %s or %s, got %q", where, alert.WatchUnits, alert.WatchAddresses, w.Kind)
+		}
+		if w.Path == "" {
+			fail("%s: path is required", where)
+		}
+	}
+	if len(c.Alerts.Geofences) > 0 {
+		c.validateZones(fail)
+	}
+
+	return errors.Join(errs...)
+}
+
+// validateZones loads the zones, checking that the geofences name zones
+// which exist.
+func (c *Config) validateZones(fail func(string, ...any)) {
+	if len(c.Zones.Paths) == 0 {
+		fail("alerts.geofences: zones.paths is required")
+		return
+	}
+	zones, err := c.Zones.Load()
+	if err != nil {
+		fail("zones: %s", err.Error())
+		return
+	}
+	names := map[string]bool{}
+	for _, z := range zones.Zones {
+		names[z.Name] = true
+	}
+	for i, g := range c.Alerts.Geofences {
+		for _, name := range g.Zones {
+			if !names[name] {
+				fail("alerts.geofences[%d]: unknown zone %q", i, name)
+			}
+		}
+	}
+}
+
+func validateSMTP(fail func(string, ...any), where string, s SMTP) {
+	if s.Host == "" || s.From == "" || len(s.To) == 0 {
+		fail("%s: smtp.host, smtp.from and smtp.to are required", where)
+	}
+}
+
+// Instance returns the named instance, or the first if name is empty.
+func (c *Config) Instance(name string) (Instance, error) {
+	if len(c.Instances) == 0 {
+		return Instance{}, fmt.Errorf("no instances defined")
+	}
+	if name == "" {
+		return c.Instances[0], nil
+	}
+	for _, in := range c.Instances {
+		if in.Name == name {
+			return in, nil
+		}
+	}
+	return Instance{}, fmt.Errorf("unknown instance %q", name)
+}
@@ -0,0 +1,201 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+const testYAML = `
+instances:
+  - name: qvec
+    url: https://cadview.example.org
+    fdid: "04040"
+    credentials:
+      username: ${TEST_CADVIEW_USERNAME}
+      password: ${TEST_CADVIEW_PASSWORD}
+    poll: 15s
+sinks:
+  - name: station
+    type: slack
+    webhook_url: ${TEST_SLACK_WEBHOOK:-https://hooks.slack.example/x}
+exports:
+  - name: nightly
+    type: calls
+    at: "06:00"
+    path: /var/lib/cadview/calls-{date}.json
+`
+
+const testTOML = `
+[[instances]]
+name = "qvec"
+url = "https://cadview.example.org"
+fdid = "04040"
+poll = "15s"
+
+[instances.credentials]
+username = "${TEST_CADVIEW_USERNAME}"
+password = "${TEST_CADVIEW_PASSWORD}"
+
+[[sinks]]
+name = "station"
+type = "slack"
+webhook_url = "${TEST_SLACK_WEBHOOK:-https://hooks.slack.example/x}"
+
+[[exports]]
+name = "nightly"
+type = "calls"
+at = "06:00"
+path = "/var/lib/cadview/calls-{date}.json"
+`
+
+func Test_Config_Load(t *testing.T) {
+	t.Setenv("TEST_CADVIEW_USERNAME", "user")
+	t.Setenv("TEST_CADVIEW_PASSWORD", "pa$$word")
+	dir := t.TempDir()
+
+	for name, body := range map[string]string{"cadview.yaml": testYAML, "cadview.toml": testTOML} {
+		path := filepath.Join(dir, name)
+		os.WriteFile(path, []byte(body), 0o600)
+		c, err := Load(path)
+		if err != nil {
+			t.Fatalf("%s: %s", name, err.Error())
+		}
+		in, _ := c.Instance("")
+		a := in.Agent()
+		creds, err := a.Credentials.Credentials()
+		if err != nil || creds.Username != "user" || creds.Password != "pa$$word" || a.BaseUrl != "https://cadview.example.org/" {
+			t.Fatalf("%s: unexpected agent %#v", name, a)
+		}
+		if w := in.Watcher(a); w.Interval != 15*time.Second || !w.Details {
+			t.Fatalf("%s: unexpected watcher %#v", name, w)
+		}
+		if c.Sinks[0].WebhookURL != "https://hooks.slack.example/x" {
+			t.Fatalf("%s: default not applied: %q", name, c.Sinks[0].WebhookURL)
+		}
+		if _, err := c.Exports[0].Job(a); err != nil {
+			t.Fatalf("%s: %s", name, err.Error())
+		}
+	}
+}
+
+func Test_Config_Validate(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "cadview.yaml")
+	os.WriteFile(path, []byte(`
+instances:
+  - url: cadview.example.org
+    credentials:
+      username: ${TEST_UNSET_USERNAME}
+      password: secret
+    browser:
+      window_size: large
+sinks:
+  - type: pager
+  - name: dispatch
+    type: teams
+    webhook_url: https://example.org/teams
+  - name: dispatch
+    type: teams
+    webhook_url: https://example.org/teams2
+exports:
+  - type: digest
+    at: "6am"
+    instance: other
+alerts:
+  keywords:
+    - name: hazmat
+      patterns: ["UN ?(\\d{4}"]
+  watchlists:
+    - name: chief
+      kind: units
+  geofences:
+    - name: hazards
+      sinks: [pager]
+    - name: hazards
+`), 0o600)
+
+	_, err := Load(path)
+	if err == nil || !strings.Contains(err.Error(), "TEST_UNSET_USERNAME") {
+		t.Fatalf("expected unset variable error, got %v", err)
+	}
+
+	t.Setenv("TEST_UNSET_USERNAME", "user")
+	_, err = Load(path)
+	for _, want := range []string{
+		"url must be an http or https URL",
+		"browser.window_size must be like 1280x1024",
+		`sinks[0]: type must be`,
+		`sink "dispatch": duplicate name`,
+		`unknown instance "other"`,
+		"at must be a time like 06:00",
+		"smtp.host, smtp.from and smtp.to are required",
+		`alerts.geofences[0]: unknown sink "pager"`,
+		`alerts.geofences[1]: duplicate name "hazards"`,
+		"alerts.geofences: zones.paths is required",
+		"alerts.keywords[0]: rule hazmat: error parsing regexp",
+		`alerts.watchlists[0]: kind must be unit or address, got "units"`,
+		"alerts.watchlists[0]: path is required",
+	} {
+		if err == nil || !strings.Contains(err.Error(), want) {
+			t.Errorf("expected %q in %v", want, err)
+		}
+	}
+}
+
+func Test_Config_ValidateZones(t *testing.T) {
+	dir := t.TempDir()
+	os.WriteFile(filepath.Join(dir, "zones.geojson"), []byte(`{"type": "FeatureCollection", "features": [
+		{"type": "Feature", "properties": {"name": "Pomfret School"},
+		 "geometry": {"type": "Polygon", "coordinates": [[[-71.97, 41.86], [-71.96, 41.86], [-71.96, 41.87], [-71.97, 41.86]]]}}
+	]}`), 0o600)
+	path := filepath.Join(dir, "cadview.yaml")
+	os.WriteFile(path, []byte(`
+instances:
+  - url: https://cadview.example.org
+    credentials: {username: user, password: secret}
+zones:
+  paths: [`+filepath.Join(dir, "zones.geojson")+`]
+alerts:
+  geofences:
+    - name: target hazards
+      zones: [Pomfret School, Pomfret Schol]
+`), 0o600)
+
+	_, err := Load(path)
+	if err == nil || !strings.Contains(err.Error(), `alerts.geofences[0]: unknown zone "Pomfret Schol"`) || strings.Contains(err.Error(), `"Pomfret School"`) {
+		t.Fatalf("expected only the misspelled zone to be rejected, got %v", err)
+	}
+
+	os.Remove(filepath.Join(dir, "zones.geojson"))
+	if _, err := Load(path); err == nil || !strings.Contains(err.Error(), "zones: ") {
+		t.Fatalf("expected a zone loading error, got %v", err)
+	}
+}
+
+func Test_Config_ExpandNested(t *testing.T) {
+	c := &Config{Instances: []Instance{{
+		Login:   &Login{UsernameSelector: "${SEL}"},
+		Capture: &Capture{Include: []string{"${CAPTURE:-*/api/*}"}},
+	}}}
+	env := map[string]string{"SEL": "#user"}
+	err := c.Expand(func(name string) (string, bool) {
+		v, ok := env[name]
+		return v, ok
+	})
+	if err != nil {
+		t.Fatalf("ERR: Expand: %s", err.Error())
+	}
+	in := c.Instances[0]
+	if in.Login.UsernameSelector != "#user" || in.Capture.Include[0] != "*/api/*" {
+		t.Fatalf("pointers not expanded: %#v %#v", in.Login, in.Capture)
+	}
+
+	m := map[string]string{"a": "${SEL}"}
+	expandValue(reflect.ValueOf(m), func(s string) string { return strings.ReplaceAll(s, "${SEL}", "#user") })
+	if m["a"] != "#user" {
+		t.Fatalf("map not expanded: %v", m)
+	}
+}
@@ -0,0 +1,210 @@
+package agent
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"time"
+)
+
+// Credentials are the username and password used to log in.
+type Credentials struct {
+	Username string
+	Password string
+}
+
+// CredentialProvider supplies login credentials. It is called at the
+// start of every login, and the credentials are discarded once the login
+// form has been filled, so that providers may rotate them.
+type CredentialProvider interface {
+	Credentials() (Credentials, error)
+}
+
+// StaticCredentials provides fixed credentials.
+type StaticCredentials Credentials
+
+// Credentials implements CredentialProvider.
+func (c StaticCredentials) Credentials() (Credentials, error) {
+	return Credentials(c), nil
+}
+
+// EnvCredentials reads credentials from environment variables.
+type EnvCredentials struct {
+	// UsernameVar and PasswordVar are the variable names. They default to
+	// CADVIEW_USERNAME and CADVIEW_PASSWORD.
+	UsernameVar string
+	PasswordVar string
+}
+
+// Credentials implements CredentialProvider.
+func (e EnvCredentials) Credentials() (Credentials, error) {
+	uv, pv := e.UsernameVar, e.PasswordVar
+	if uv == "" {
+		uv = "CADVIEW_USERNAME"
+	}
+	if pv == "" {
+		pv = "CADVIEW_PASSWORD"
+	}
+	c := Credentials{Username: os.Getenv(uv), Password: os.Getenv(pv)}
+	if c.Username == "" || c.Password == "" {
+		return c, fmt.Errorf("credentials: %s and %s must be set", uv, pv)
+	}
+	return c, nil
+}
+
+// FileCredentials reads credentials from a file containing the username on
+// the first line and the password on the second. The file must not be
+// accessible to other users.
+type FileCredentials struct {
+	Path string
+}
+
+// Credentials implements CredentialProvider.
+func (f FileCredentials) Credentials() (Credentials, error) {
+	if err := checkPrivate(f.Path); err != nil {
+		return Credentials{}, err
+	}
+	body, err := os.ReadFile(f.Path)
+	if err != nil {
+		return Credentials{}, err
+	}
+	return parseCredentials(f.Path, body, "")
+}
+
+// CommandCredentials runs a command, such as a secrets manager CLI, to
+// obtain credentials. The command prints the username on the first line and
+// the password on the second, or only the password if Username is set.
+type CommandCredentials struct {
+	// Command is the program and its arguments, like
+	// []string{"pass", "show", "cadview"}. It is not run through a shell.
+	Command []string
+	// Username is the username, if the command only prints a password.
+	Username string
+	// Timeout limits how long the command may run. Defaults to 30 seconds.
+	Timeout time.Duration
+}
+
+// Credentials implements CredentialProvider.
+func (c CommandCredentials) Credentials() (Credentials, error) {
+	if len(c.Command) == 0 {
+		return Credentials{}, fmt.Errorf("credentials: no command given")
+	}
+	timeout := c.Timeout
+	if timeout <= 0 {
+		timeout = 30 * time.Second
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+
+	var stderr bytes.Buffer
+	cmd := exec.CommandContext(ctx, c.Command[0], c.Command[1:]...)
+	cmd.Stderr = &stderr
+	out, err := cmd.Output()
+	if err != nil {
+		return Credentials{}, fmt.Errorf("credentials: %s: %w: %s", c.Command[0], err, strings.TrimSpace(stderr.String()))
+	}
+	return parseCredentials(c.Command[0], out, c.Username)
+}
+
+// SystemdCredentials reads credentials passed to a systemd service with
+// LoadCredential= or LoadCredentialEncrypted=, from the directory in
+// $CREDENTIALS_DIRECTORY.
+type SystemdCredentials struct {
+	// UsernameName and PasswordName are the credential names. They default
+	// to "cadview-username" and "cadview-password".
+	UsernameName string
+	PasswordName string
+}
+
+// Credentials implements CredentialProvider.
+func (s SystemdCredentials) Credentials() (Credentials, error) {
+	dir := os.Getenv("CREDENTIALS_DIRECTORY")
+	if dir == "" {
+		return Credentials{}, fmt.Errorf("credentials: CREDENTIALS_DIRECTORY is not set, is this running as a systemd service with LoadCredential=?")
+	}
+	un, pn := s.UsernameName, s.PasswordName
+	if un == "" {
+		un = "cadview-username"
+	}
+	if pn == "" {
+		pn = "cadview-password"
+	}
+	username, err := os.ReadFile(filepath.Join(dir, un))
+	if err != nil {
+		return Credentials{}, err
+	}
+	password, err := os.ReadFile(filepath.Join(dir, pn))
+	if err != nil {
+		return Credentials{}, err
+	}
+	return Credentials{
+		Username: strings.TrimRight(string(username), "\r\n"),
+		Password: strings.TrimRight(string(password), "\r\n"),
+	}, nil
+}
+
+// parseCredentials splits a username and password from the lines of body.
+func parseCredentials(source string, body []byte, username string) (Credentials, error) {
+	lines := strings.Split(strings.ReplaceAll(string(body), "\r\n", "\n"), "\n")
+	c := Credentials{Username: username}
+	if username == "" {
+		if len(lines) < 2 {
+			return c, fmt.Errorf("credentials: %s: expected the username and password on separate lines", source)
+		}
+		c.Username, lines = strings.TrimSpace(lines[0]), lines[1:]
+	}
+	c.Password = lines[0]
+	if c.Username == "" || c.Password == "" {
+		return c, fmt.Errorf("credentials: %s: empty username or password", source)
+	}
+	return c, nil
+}
+
+// checkPrivate refuses files which other users can access.
+func checkPrivate(path string) error {
+	fi, err := os.Stat(path)
+	if err != nil {
+		return err
+	}
+	if fi.IsDir() {
+		return fmt.Errorf("credentials: %s is a directory", path)
+	}
+	if runtime.GOOS != "windows" && fi.Mode().Perm()&0o077 != 0 {
+		return fmt.Errorf("credentials: %s is accessible by other users (mode %04o), chmod 600 it", path, fi.Mode().Perm())
+	}
+	return nil
+}
+
+// credentials returns the login credentials from the provider, or the
+// Username and Password fields if there is none.
+func (a *Agent) credentials() (Credentials, error) {
+	if a.Credentials != nil {
+		return a.Credentials.Credentials()
+	}
+	if a.Username == "" || a.Password == "" {
+		return Credentials{}, errors.New("credentials: no credential provider or username and password")
+	}
+	return Credentials{Username: a.Username, Password: a.Password}, nil
+}
+
+// agentCredentials provides the Username and Password of another agent, for
+// copies made by MakeCopy.
+type agentCredentials struct {
+	a *Agent
+}
+
+// Credentials implements CredentialProvider.
+func (c agentCredentials) Credentials() (Credentials, error) {
+	return c.a.credentials()
+}
+
+// HasCredentials determines whether the agent is able to log in by itself.
+func (a *Agent) HasCredentials() bool {
+	return a.Credentials != nil || (a.Username != "" && a.Password != "")
+}
@@ -0,0 +1,58 @@
+package agent
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func Test_Credentials_Providers(t *testing.T) {
+	dir := t.TempDir()
+
+	path := filepath.Join(dir, "credentials")
+	os.WriteFile(path, []byte("user\npass word\n"), 0o644)
+	if _, err := (FileCredentials{Path: path}).Credentials(); err == nil || !strings.Contains(err.Error(), "other users") {
+		t.Fatalf("expected permission error, got %v", err)
+	}
+	os.Chmod(path, 0o600)
+	if c, err := (FileCredentials{Path: path}).Credentials(); err != nil || c.Username != "user" || c.Password != "pass word" {
+		t.Fatalf("unexpected file credentials %#v, %v", c, err)
+	}
+
+	cmd := CommandCredentials{Command: []string{"echo", "secret"}, Username: "user"}
+	if c, err := cmd.Credentials(); err != nil || c.Username != "user" || c.Password != "secret" {
+		t.Fatalf("unexpected command credentials %#v, %v", c, err)
+	}
+	if _, err := (CommandCredentials{Command: []string{"false"}}).Credentials(); err == nil {
+		t.Fatalf("expected failing command to fail")
+	}
+
+	os.WriteFile(filepath.Join(dir, "cadview-username"), []byte("user\n"), 0o600)
+	os.WriteFile(filepath.Join(dir, "cadview-password"), []byte("secret"), 0o600)
+	t.Setenv("CREDENTIALS_DIRECTORY", dir)
+	if c, err := (SystemdCredentials{}).Credentials(); err != nil || c.Username != "user" || c.Password != "secret" {
+		t.Fatalf("unexpected systemd credentials %#v, %v", c, err)
+	}
+
+	t.Setenv("CADVIEW_USERNAME", "")
+	a := &Agent{Credentials: EnvCredentials{}}
+	if _, err := a.credentials(); err == nil {
+		t.Fatalf("expected missing environment to fail")
+	}
+}
+
+func Test_Credentials_MakeCopy(t *testing.T) {
+	a := &Agent{Username: "user", Password: "secret"}
+	a2 := a.MakeCopy()
+	if a2.Password != "" {
+		t.Fatalf("expected the password not to be copied")
+	}
+	if c, err := a2.credentials(); err != nil || c.Username != "user" || c.Password != "secret" {
+		t.Fatalf("unexpected copied credentials %#v, %v", c, err)
+	}
+
+	if a2 := (&Agent{}).MakeCopy(); a2.HasCredentials() {
+		t.Fatalf("expected a copy without credentials")
+	}
+}
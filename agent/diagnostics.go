@@ -0,0 +1,271 @@
+package agent
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/chromedp/cdproto/network"
+	"github.com/chromedp/cdproto/runtime"
+	"github.com/chromedp/chromedp"
+)
+
+// Login failure causes reported by LoginError
+const (
+	LoginCauseBadPassword      = "bad_password"
+	LoginCauseAccountLocked    = "account_locked"
+	LoginCauseSelectorNotFound = "selector_not_found"
+	LoginCauseCertificate      = "certificate_error"
+	LoginCauseMFA              = "mfa"
+	LoginCauseCredentials      = "credentials"
+	LoginCauseTimeout          = "timeout"
+	LoginCauseUnknown          = "unknown"
+)
+
+// Login steps, recorded so that failures can be attributed to a step
+const (
+	loginStepLoad     = "load"
+	loginStepUsername = "username"
+	loginStepPassword = "password"
+	loginStepSubmit   = "submit"
+	loginStepAwait    = "await"
+	loginStepToken    = "token"
+)
+
+// LoginError is returned by Init when the login fails, with the likely
+// cause and, if Agent.DiagnosticsDir is set, where the diagnostics were
+// saved.
+type LoginError struct {
+	// Cause is one of the LoginCause constants.
+	Cause string
+	// Step is the login step which failed, like "username" or "submit".
+	Step string
+	// Dir is the directory holding the screenshot, page HTML, console and
+	// network logs, if they were saved.
+	Dir string
+	Err error
+}
+
+func (e *LoginError) Error() string {
+	msg := fmt.Sprintf("login failed (%s during %s): %s", e.Cause, e.Step, e.Err.Error())
+	if e.Dir != "" {
+		msg += ", diagnostics saved to " + e.Dir
+	}
+	return msg
+}
+
+func (e *LoginError) Unwrap() error {
+	return e.Err
+}
+
+// consoleEntry is a browser console message or uncaught exception.
+type consoleEntry struct {
+	Time  time.Time `json:"time"`
+	Level string    `json:"level"`
+	Text  string    `json:"text"`
+}
+
+// netEntry is a request made by the browser during login.
+type netEntry struct {
+	Time      time.Time `json:"time"`
+	RequestID string    `json:"requestId"`
+	Method    string    `json:"method"`
+	URL       string    `json:"url"`
+	Status    int64     `json:"status,omitempty"`
+	MimeType  string    `json:"mimeType,omitempty"`
+	Error     string    `json:"error,omitempty"`
+}
+
+// loginTrace records what happened in the browser during a login.
+type loginTrace struct {
+	step    string
+	console []consoleEntry
+	net     []*netEntry
+	netByID map[network.RequestID]*netEntry
+}
+
+// traceEvent records console and network events for diagnostics. It is
+// called with the agent locked.
+func (a *Agent) traceEvent(v interface{}) {
+	t := &a.trace
+	switch ev := v.(type) {
+	case *runtime.EventConsoleAPICalled:
+		args := []string{}
+		for _, arg := range ev.Args {
+			if arg.Value != nil {
+				args = append(args, string(arg.Value))
+			} else {
+				args = append(args, arg.Description)
+			}
+		}
+		t.console = append(t.console, consoleEntry{Time: time.Now(), Level: string(ev.Type), Text: strings.Join(args, " ")})
+	case *runtime.EventExceptionThrown:
+		text := ev.ExceptionDetails.Text
+		if ev.ExceptionDetails.Exception != nil {
+			text += " " + ev.ExceptionDetails.Exception.Description
+		}
+		t.console = append(t.console, consoleEntry{Time: time.Now(), Level: "exception", Text: text})
+	case *network.EventRequestWillBeSent:
+		if strings.HasPrefix(ev.Request.URL, "data:") {
+			return
+		}
+		e := &netEntry{Time: time.Now(), RequestID: ev.RequestID.String(), Method: ev.Request.Method, URL: ev.Request.URL}
+		t.net = append(t.net, e)
+		t.netByID[ev.RequestID] = e
+	case *network.EventResponseReceived:
+		if e, ok := t.netByID[ev.RequestID]; ok {
+			e.Status = ev.Response.Status
+			e.MimeType = ev.Response.MimeType
+		}
+	case *network.EventLoadingFailed:
+		if e, ok := t.netByID[ev.RequestID]; ok {
+			e.Error = ev.ErrorText
+		}
+	}
+}
+
+// loginStep returns an action recording the current login step, with a
+// log message.
+func (a *Agent) loginStep(step, msg string) chromedp.Action {
+	return chromedp.ActionFunc(func(ctx context.Context) error {
+		if msg != "" {
+			log.Printf("INFO: %s", msg)
+		}
+		a.l.Lock()
+		a.trace.step = step
+		a.l.Unlock()
+		return nil
+	})
+}
+
+// diagnoseLogin classifies a failed login, saving diagnostics if
+// DiagnosticsDir is set. ctx is the browser context, which must still be
+// usable.
+func (a *Agent) diagnoseLogin(ctx context.Context, err error) *LoginError {
+	a.l.Lock()
+	le := &LoginError{Step: a.trace.step, Err: err}
+	console := append([]consoleEntry{}, a.trace.console...)
+	netlog := make([]netEntry, 0, len(a.trace.net))
+	for _, e := range a.trace.net {
+		netlog = append(netlog, *e)
+	}
+	a.l.Unlock()
+
+	var text, html, location string
+	var screenshot []byte
+	if ctx != nil {
+		dctx, cancel := context.WithTimeout(ctx, 15*time.Second)
+		defer cancel()
+		actions := []chromedp.Action{
+			chromedp.Location(&location),
+			chromedp.Evaluate(`document.body ? document.body.innerText : ""`, &text),
+			chromedp.OuterHTML("html", &html, chromedp.ByQuery),
+		}
+		if a.DiagnosticsDir != "" {
+			// Quality 100 is needed for a PNG, as chromedp otherwise
+			// captures a JPEG
+			actions = append(actions, chromedp.FullScreenshot(&screenshot, 100))
+		}
+		if derr := chromedp.Run(dctx, actions...); derr != nil {
+			log.Printf("WARN: Login diagnostics: %s", derr.Error())
+		}
+	}
+	le.Cause = classifyLogin(err, le.Step, text, netlog)
+
+	if a.DiagnosticsDir != "" {
+		dir, serr := a.saveDiagnostics(le, location, screenshot, html, console, netlog)
+		if serr != nil {
+			log.Printf("ERR: Saving login diagnostics: %s", serr.Error())
+		} else {
+			le.Dir = dir
+		}
+	}
+	return le
+}
+
+// classifyLogin determines the likely cause of a failed login from the
+// error, the step which failed, the page text and the network log.
+func classifyLogin(err error, step, text string, netlog []netEntry) string {
+	text = strings.ToLower(text)
+	switch {
+	case errors.Is(err, ErrMFARequired), errors.Is(err, ErrMFARejected):
+		return LoginCauseMFA
+	case strings.Contains(err.Error(), "ERR_CERT_"):
+		return LoginCauseCertificate
+	}
+	for _, e := range netlog {
+		if strings.Contains(e.Error, "ERR_CERT_") {
+			return LoginCauseCertificate
+		}
+	}
+	for _, s := range []string{"account is locked", "account has been locked", "locked out", "account is disabled", "account has been disabled"} {
+		if strings.Contains(text, s) {
+			return LoginCauseAccountLocked
+		}
+	}
+	for _, s := range []string{"invalid username or password", "invalid login", "invalid credentials", "incorrect password", "incorrect username", "login failed"} {
+		if strings.Contains(text, s) {
+			return LoginCauseBadPassword
+		}
+	}
+	if errors.Is(err, context.DeadlineExceeded) {
+		switch step {
+		case loginStepLoad, loginStepUsername, loginStepPassword, loginStepSubmit:
+			return LoginCauseSelectorNotFound
+		}
+		return LoginCauseTimeout
+	}
+	return LoginCauseUnknown
+}
+
+// saveDiagnostics writes the diagnostics of a failed login to a new
+// directory under DiagnosticsDir.
+func (a *Agent) saveDiagnostics(le *LoginError, location string, screenshot []byte, html string, console []consoleEntry, netlog []netEntry) (string, error) {
+	host := "cadview"
+	if u, err := url.Parse(a.BaseUrl); err == nil && u.Host != "" {
+		host = u.Host
+	}
+	dir := filepath.Join(a.DiagnosticsDir, fmt.Sprintf("login-%s-%s", host, time.Now().Format("20060102-150405")))
+	if err := os.MkdirAll(dir, 0o700); err != nil {
+		return "", err
+	}
+
+	summary, _ := json.MarshalIndent(map[string]any{
+		"time":  time.Now(),
+		"url":   location,
+		"cause": le.Cause,
+		"step":  le.Step,
+		"error": le.Err.Error(),
+	}, "", "  ")
+	consoleLog := strings.Builder{}
+	for _, e := range console {
+		fmt.Fprintf(&consoleLog, "%s [%s] %s\n", e.Time.Format(time.RFC3339Nano), e.Level, e.Text)
+	}
+	netJSON, _ := json.MarshalIndent(netlog, "", "  ")
+	harJSON, _ := json.MarshalIndent(a.HAR(), "", "  ")
+
+	files := map[string][]byte{
+		"summary.json": summary,
+		"page.html":    []byte(html),
+		"console.log":  []byte(consoleLog.String()),
+		"network.json": netJSON,
+		"login.har":    harJSON,
+	}
+	if len(screenshot) > 0 {
+		files["screenshot.png"] = screenshot
+	}
+	for name, body := range files {
+		// The page may include the username, so keep it private
+		if err := os.WriteFile(filepath.Join(dir, name), body, 0o600); err != nil {
+			return dir, err
+		}
+	}
+	return dir, nil
+}
@@ -0,0 +1,185 @@
+// Package export writes full call records, for archiving and for tools
+// which cannot use the API.
+package export
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/dayvillefire/newworld-cadview-agent/agent"
+	"github.com/dayvillefire/newworld-cadview-agent/agent/internal/schedule"
+)
+
+// Formats supported by Write
+const (
+	FormatJSON = "json"
+	FormatCSV  = "csv"
+)
+
+// Calls retrieves the full record of every call cleared between from and
+// to for each ORI or FDID. Calls which cannot be retrieved are logged and
+// skipped, unless the session has expired.
+func Calls(a *agent.Agent, from, to time.Time, fdids []string) ([]agent.CADCall, error) {
+	oris, err := a.GetORIs()
+	if err != nil {
+		return []agent.CADCall{}, err
+	}
+	out := []agent.CADCall{}
+	for _, fdid := range fdids {
+		ori := agent.FDIDToORI(oris, fdid)
+		if ori == "" {
+			ori = fdid
+		}
+		calls, err := a.GetClearedCalls(from, to, ori)
+		if err != nil {
+			return out, err
+		}
+		for _, call := range calls {
+			cad, err := a.RetrieveCADCall(call)
+			if errors.Is(err, agent.ErrNotAuthorized) {
+				return out, err
+			}
+			if err != nil {
+				log.Printf("ERR: Export: %s: %s", call.IncidentNumber, err.Error())
+				continue
+			}
+			out = append(out, cad)
+		}
+	}
+	return out, nil
+}
+
+// Write writes call records as a JSON array or as CSV with one row per
+// call.
+func Write(w io.Writer, format string, cads []agent.CADCall) error {
+	switch format {
+	case FormatJSON:
+		enc := json.NewEncoder(w)
+		enc.SetIndent("", "  ")
+		return enc.Encode(cads)
+	case FormatCSV:
+		header, rows := Rows(cads)
+		cw := csv.NewWriter(w)
+		cw.Write(header)
+		cw.WriteAll(rows)
+		return cw.Error()
+	default:
+		return fmt.Errorf("unknown export format %q", format)
+	}
+}
+
+// Rows flattens call records to one row per call, with the units and
+// narratives of each call joined.
+func Rows(cads []agent.CADCall) ([]string, [][]string) {
+	header := []string{"CALL ID", "INCIDENT", "TYPE", "PRIORITY", "STATUS", "LOCATION", "CREATED", "PRIMARY UNIT", "ORI", "NATURE", "UNITS", "NARRATIVE"}
+	rows := [][]string{}
+	for _, cad := range cads {
+		c := cad.Call
+		units := []string{}
+		for _, u := range cad.Units {
+			units = append(units, u.UnitNumber)
+		}
+		narratives := []string{}
+		for _, n := range cad.Narratives {
+			narratives = append(narratives, n.Narrative)
+		}
+		rows = append(rows, []string{
+			fmt.Sprintf("%d", c.CallID),
+			c.IncidentNumber,
+			c.CallType,
+			c.CallPriority,
+			c.CallStatus,
+			c.Location,
+			c.CreatedDateTime,
+			c.PrimaryUnit,
+			strings.Join(c.AllowedORI, " "),
+			c.NatureOfCall,
+			strings.Join(units, " "),
+			strings.Join(narratives, " | "),
+		})
+	}
+	return header, rows
+}
+
+// Job writes the calls cleared in each period to a file on a daily
+// schedule.
+type Job struct {
+	Agent *agent.Agent
+	// FDIDs is the list of departments to export. Defaults to the FDID of
+	// the agent.
+	FDIDs []string
+	// At is the local time of day to export, like "06:00". Defaults to
+	// midnight.
+	At string
+	// Period is the length of time covered by each export, ending when it
+	// runs. Defaults to 24 hours.
+	Period time.Duration
+	// Format is FormatJSON or FormatCSV. Defaults to FormatJSON.
+	Format string
+	// Path is the file to write. "{date}" is replaced with the date of the
+	// end of the period, like "exports/calls-{date}.json".
+	Path string
+
+	schedule schedule.Daily
+}
+
+// Run exports the calls cleared in a period, logging in again and retrying
+// once if the session has expired.
+func (j *Job) Run(from, to time.Time) error {
+	fdids := j.FDIDs
+	if len(fdids) == 0 {
+		fdids = []string{j.Agent.FDID}
+	}
+	format := j.Format
+	if format == "" {
+		format = FormatJSON
+	}
+	start := time.Now()
+	cads, err := Calls(j.Agent, from, to, fdids)
+	if errors.Is(err, agent.ErrNotAuthorized) {
+		if err = j.Agent.ReauthorizeSince(start); err == nil {
+			cads, err = Calls(j.Agent, from, to, fdids)
+		}
+	}
+	if err != nil {
+		return err
+	}
+
+	path := strings.ReplaceAll(j.Path, "{date}", to.Format("2006-01-02"))
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return err
+	}
+	f, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	if err := Write(f, format, cads); err != nil {
+		f.Close()
+		return err
+	}
+	log.Printf("INFO: Export: wrote %d calls to %s", len(cads), path)
+	return f.Close()
+}
+
+// NextRun returns the next scheduled export time after t.
+func (j *Job) NextRun(t time.Time) (time.Time, error) {
+	return schedule.Next(j.At, t)
+}
+
+// Start exports in the background until Stop is called.
+func (j *Job) Start() error {
+	return j.schedule.Start("Export", j.At, j.Period, j.Run)
+}
+
+// Stop ends the schedule started with Start.
+func (j *Job) Stop() {
+	j.schedule.Stop()
+}
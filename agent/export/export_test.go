@@ -0,0 +1,94 @@
+package export
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/dayvillefire/newworld-cadview-agent/agent"
+)
+
+var testCalls = []agent.CADCall{{
+	Call: agent.CallObj{
+		CallID:          1,
+		IncidentNumber:  "2022-00000345",
+		CallType:        "Structure Fire",
+		Location:        "120 FREEDLEY RD, Pomfret",
+		CreatedDateTime: "11/13/2022 10:00:00",
+		AllowedORI:      []string{"04040", "04090"},
+		NatureOfCall:    "SMOKE \"SHOWING\"",
+	},
+	Units:      []agent.UnitObj{{UnitNumber: "ENG70"}, {UnitNumber: "TANKER70"}},
+	Narratives: []agent.NarrativeObj{{Narrative: "CALLER ADVISES"}, {Narrative: "2ND ALARM"}},
+}}
+
+func Test_Write_CSV(t *testing.T) {
+	var buf bytes.Buffer
+	if err := Write(&buf, FormatCSV, testCalls); err != nil {
+		t.Fatalf("ERR: Write: %s", err.Error())
+	}
+	want := "CALL ID,INCIDENT,TYPE,PRIORITY,STATUS,LOCATION,CREATED,PRIMARY UNIT,ORI,NATURE,UNITS,NARRATIVE\n" +
+		"1,2022-00000345,Structure Fire,,,\"120 FREEDLEY RD, Pomfret\",11/13/2022 10:00:00,,04040 04090,\"SMOKE \"\"SHOWING\"\"\",ENG70 TANKER70,CALLER ADVISES | 2ND ALARM\n"
+	if buf.String() != want {
+		t.Fatalf("unexpected CSV:\n%s", buf.String())
+	}
+}
+
+func Test_Write_JSON(t *testing.T) {
+	var buf bytes.Buffer
+	if err := Write(&buf, FormatJSON, testCalls); err != nil {
+		t.Fatalf("ERR: Write: %s", err.Error())
+	}
+	var got []agent.CADCall
+	if err := json.Unmarshal(buf.Bytes(), &got); err != nil {
+		t.Fatalf("ERR: invalid JSON: %s\n%s", err.Error(), buf.String())
+	}
+	if !reflect.DeepEqual(got, testCalls) {
+		t.Fatalf("expected %#v, got %#v", testCalls, got)
+	}
+
+	if err := Write(&buf, "xml", testCalls); err == nil {
+		t.Fatalf("expected an error for an unknown format")
+	}
+}
+
+func Test_Calls(t *testing.T) {
+	cad := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var out any
+		switch {
+		case strings.HasSuffix(r.URL.Path, "/api/CadView/GetOrisForClearedCallSearch"):
+			out = []agent.ORIObj{{ORI: "26", FDID: "04040", AgencyName: "Pomfret"}}
+		case strings.HasSuffix(r.URL.Path, "/api/Call/SearchClearedCalls"):
+			out = []agent.CallObj{{CallID: 1, IncidentNumber: "2022-00000345"}, {CallID: 2, IncidentNumber: "2022-00000346"}}
+		case strings.HasSuffix(r.URL.Path, "/api/Call/GetCall"):
+			// The second call cannot be retrieved
+			if r.URL.Query().Get("id") == "2" {
+				w.Write([]byte("{"))
+				return
+			}
+			id, _ := strconv.ParseInt(r.URL.Query().Get("id"), 10, 64)
+			out = agent.CallObj{CallID: id, IncidentNumber: "2022-00000345"}
+		default:
+			out = []any{}
+		}
+		json.NewEncoder(w).Encode(out)
+	}))
+	defer cad.Close()
+	a := &agent.Agent{BaseUrl: cad.URL + "/", FDID: "04040"}
+	a.SetAuth(agent.OidcObj{TokenType: "Bearer", AccessToken: "test"})
+
+	from := time.Date(2022, 11, 13, 0, 0, 0, 0, time.Local)
+	cads, err := Calls(a, from, from.Add(24*time.Hour), []string{"04040"})
+	if err != nil {
+		t.Fatalf("ERR: Calls: %s", err.Error())
+	}
+	if len(cads) != 1 || cads[0].Call.CallID != 1 {
+		t.Fatalf("expected only the first call, got %#v", cads)
+	}
+}
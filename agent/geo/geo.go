@@ -0,0 +1,232 @@
+// Package geo loads GeoJSON polygons, such as first-due areas and target
+// hazard sites, and matches call coordinates against them.
+package geo
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+
+	"github.com/dayvillefire/newworld-cadview-agent/agent"
+)
+
+// point is a longitude / latitude pair, in GeoJSON order.
+type point [2]float64
+
+// ring is a closed linear ring.
+type ring []point
+
+// polygon is an outer ring followed by any holes.
+type polygon []ring
+
+// Zone is a named area made up of one or more polygons.
+type Zone struct {
+	// Name is taken from the feature properties.
+	Name string
+	// Properties are the GeoJSON feature properties.
+	Properties map[string]any
+
+	polygons []polygon
+	minX     float64
+	minY     float64
+	maxX     float64
+	maxY     float64
+}
+
+// Contains determines whether a latitude and longitude lie within the zone.
+func (z *Zone) Contains(lat, lon float64) bool {
+	if lon < z.minX || lon > z.maxX || lat < z.minY || lat > z.maxY {
+		return false
+	}
+	p := point{lon, lat}
+	for _, poly := range z.polygons {
+		if len(poly) == 0 || !poly[0].contains(p) {
+			continue
+		}
+		inHole := false
+		for _, hole := range poly[1:] {
+			if hole.contains(p) {
+				inHole = true
+				break
+			}
+		}
+		if !inHole {
+			return true
+		}
+	}
+	return false
+}
+
+// contains implements the even-odd ray casting test.
+func (r ring) contains(p point) bool {
+	in := false
+	for i, j := 0, len(r)-1; i < len(r); j, i = i, i+1 {
+		xi, yi := r[i][0], r[i][1]
+		xj, yj := r[j][0], r[j][1]
+		if (yi > p[1]) != (yj > p[1]) && p[0] < (xj-xi)*(p[1]-yi)/(yj-yi)+xi {
+			in = !in
+		}
+	}
+	return in
+}
+
+// Set is a collection of zones.
+type Set struct {
+	Zones []*Zone
+}
+
+// Match returns the zones containing a latitude and longitude.
+func (s *Set) Match(lat, lon float64) []*Zone {
+	out := []*Zone{}
+	if s == nil || (lat == 0 && lon == 0) {
+		return out
+	}
+	for _, z := range s.Zones {
+		if z.Contains(lat, lon) {
+			out = append(out, z)
+		}
+	}
+	return out
+}
+
+// Names returns the sorted names of the zones containing a call.
+func (s *Set) Names(c agent.CallObj) []string {
+	out := []string{}
+	for _, z := range s.Match(c.LatitudeY, c.LongitudeX) {
+		out = append(out, z.Name)
+	}
+	sort.Strings(out)
+	return out
+}
+
+// Tag sets the Zones field of each call to the names of the zones which
+// contain it.
+func (s *Set) Tag(calls []agent.CallObj) {
+	for k := range calls {
+		calls[k].Zones = s.Names(calls[k])
+	}
+}
+
+// LoadFiles reads GeoJSON files into a single Set. nameProperty is the
+// feature property used to name zones, defaulting to "name".
+func LoadFiles(nameProperty string, paths ...string) (*Set, error) {
+	s := &Set{}
+	for _, path := range paths {
+		f, err := os.Open(path)
+		if err != nil {
+			return s, err
+		}
+		zones, err := Load(f, nameProperty)
+		f.Close()
+		if err != nil {
+			return s, fmt.Errorf("%s: %w", path, err)
+		}
+		s.Zones = append(s.Zones, zones...)
+	}
+	return s, nil
+}
+
+type geoJSON struct {
+	Type        string          `json:"type"`
+	Features    []geoJSON       `json:"features"`
+	Geometry    *geoJSON        `json:"geometry"`
+	Geometries  []geoJSON       `json:"geometries"`
+	Properties  map[string]any  `json:"properties"`
+	Coordinates json.RawMessage `json:"coordinates"`
+}
+
+// Load reads a GeoJSON FeatureCollection, Feature or geometry and returns a
+// zone for each feature with polygon geometry.
+func Load(r io.Reader, nameProperty string) ([]*Zone, error) {
+	if nameProperty == "" {
+		nameProperty = "name"
+	}
+	var doc geoJSON
+	if err := json.NewDecoder(r).Decode(&doc); err != nil {
+		return []*Zone{}, err
+	}
+
+	features := []geoJSON{}
+	switch doc.Type {
+	case "FeatureCollection":
+		features = doc.Features
+	case "Feature":
+		features = []geoJSON{doc}
+	default:
+		features = []geoJSON{{Type: "Feature", Geometry: &doc}}
+	}
+
+	out := []*Zone{}
+	for i, f := range features {
+		if f.Geometry == nil {
+			continue
+		}
+		z := &Zone{Properties: f.Properties}
+		if name, ok := f.Properties[nameProperty]; ok {
+			z.Name = fmt.Sprintf("%v", name)
+		} else {
+			z.Name = fmt.Sprintf("zone-%d", i+1)
+		}
+		polys, err := polygons(*f.Geometry)
+		if err != nil {
+			return out, fmt.Errorf("feature %s: %w", z.Name, err)
+		}
+		if len(polys) == 0 {
+			continue
+		}
+		z.polygons = polys
+		z.bounds()
+		out = append(out, z)
+	}
+	return out, nil
+}
+
+func polygons(g geoJSON) ([]polygon, error) {
+	switch g.Type {
+	case "Polygon":
+		var p polygon
+		err := json.Unmarshal(g.Coordinates, &p)
+		return []polygon{p}, err
+	case "MultiPolygon":
+		var p []polygon
+		err := json.Unmarshal(g.Coordinates, &p)
+		return p, err
+	case "GeometryCollection":
+		out := []polygon{}
+		for _, sub := range g.Geometries {
+			p, err := polygons(sub)
+			if err != nil {
+				return out, err
+			}
+			out = append(out, p...)
+		}
+		return out, nil
+	}
+	// Points and lines cannot contain a call
+	return []polygon{}, nil
+}
+
+func (z *Zone) bounds() {
+	first := true
+	for _, poly := range z.polygons {
+		for _, r := range poly {
+			for _, p := range r {
+				if first || p[0] < z.minX {
+					z.minX = p[0]
+				}
+				if first || p[0] > z.maxX {
+					z.maxX = p[0]
+				}
+				if first || p[1] < z.minY {
+					z.minY = p[1]
+				}
+				if first || p[1] > z.maxY {
+					z.maxY = p[1]
+				}
+				first = false
+			}
+		}
+	}
+}
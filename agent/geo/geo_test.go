@@ -0,0 +1,58 @@
+package geo
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/dayvillefire/newworld-cadview-agent/agent"
+)
+
+const testZones = `{
+  "type": "FeatureCollection",
+  "features": [
+    {
+      "type": "Feature",
+      "properties": {"name": "FIRST DUE 70"},
+      "geometry": {
+        "type": "Polygon",
+        "coordinates": [
+          [[-72.0, 41.8], [-71.9, 41.8], [-71.9, 42.0], [-72.0, 42.0], [-72.0, 41.8]],
+          [[-71.96, 41.85], [-71.94, 41.85], [-71.94, 41.86], [-71.96, 41.86], [-71.96, 41.85]]
+        ]
+      }
+    },
+    {
+      "type": "Feature",
+      "properties": {"name": "MILL"},
+      "geometry": {
+        "type": "MultiPolygon",
+        "coordinates": [[[[-71.95, 41.90], [-71.94, 41.90], [-71.94, 41.91], [-71.95, 41.91], [-71.95, 41.90]]]]
+      }
+    }
+  ]
+}`
+
+func Test_Set_Tag(t *testing.T) {
+	zones, err := Load(strings.NewReader(testZones), "")
+	if err != nil {
+		t.Fatalf("ERR: Load: %s", err.Error())
+	}
+	s := &Set{Zones: zones}
+
+	calls := []agent.CallObj{
+		{CallID: 1, LatitudeY: 41.9026307589760000, LongitudeX: -71.9467412712122000},
+		{CallID: 2, LatitudeY: 41.855, LongitudeX: -71.95}, // in the hole
+		{CallID: 3, LatitudeY: 41.7, LongitudeX: -71.95},
+		{CallID: 4},
+	}
+	s.Tag(calls)
+
+	if strings.Join(calls[0].Zones, ",") != "FIRST DUE 70,MILL" {
+		t.Fatalf("unexpected zones for call 1: %v", calls[0].Zones)
+	}
+	for _, c := range calls[1:] {
+		if len(c.Zones) != 0 {
+			t.Fatalf("unexpected zones for call %d: %v", c.CallID, c.Zones)
+		}
+	}
+}
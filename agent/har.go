@@ -0,0 +1,380 @@
+package agent
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io"
+	"mime"
+	"net/url"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/chromedp/cdproto/network"
+)
+
+// HAR is an HTTP Archive, version 1.2, of the requests made by the browser.
+// See http://www.softwareishard.com/blog/har-12-spec/
+type HAR struct {
+	Log HARLog `json:"log"`
+}
+
+type HARLog struct {
+	Version string     `json:"version"`
+	Creator HARCreator `json:"creator"`
+	Entries []HAREntry `json:"entries"`
+}
+
+type HARCreator struct {
+	Name    string `json:"name"`
+	Version string `json:"version"`
+}
+
+type HAREntry struct {
+	StartedDateTime time.Time   `json:"startedDateTime"`
+	Time            float64     `json:"time"`
+	Request         HARRequest  `json:"request"`
+	Response        HARResponse `json:"response"`
+	Cache           struct{}    `json:"cache"`
+	Timings         HARTimings  `json:"timings"`
+	ServerIPAddress string      `json:"serverIPAddress,omitempty"`
+	Connection      string      `json:"connection,omitempty"`
+	Comment         string      `json:"comment,omitempty"`
+}
+
+type HARRequest struct {
+	Method      string         `json:"method"`
+	URL         string         `json:"url"`
+	HTTPVersion string         `json:"httpVersion"`
+	Cookies     []HARCookie    `json:"cookies"`
+	Headers     []HARNameValue `json:"headers"`
+	QueryString []HARNameValue `json:"queryString"`
+	PostData    *HARPostData   `json:"postData,omitempty"`
+	HeadersSize int64          `json:"headersSize"`
+	BodySize    int64          `json:"bodySize"`
+}
+
+type HARResponse struct {
+	Status      int64          `json:"status"`
+	StatusText  string         `json:"statusText"`
+	HTTPVersion string         `json:"httpVersion"`
+	Cookies     []HARCookie    `json:"cookies"`
+	Headers     []HARNameValue `json:"headers"`
+	Content     HARContent     `json:"content"`
+	RedirectURL string         `json:"redirectURL"`
+	HeadersSize int64          `json:"headersSize"`
+	BodySize    int64          `json:"bodySize"`
+}
+
+type HARCookie struct {
+	Name  string `json:"name"`
+	Value string `json:"value"`
+}
+
+type HARNameValue struct {
+	Name  string `json:"name"`
+	Value string `json:"value"`
+}
+
+type HARPostData struct {
+	MimeType string `json:"mimeType"`
+	Text     string `json:"text"`
+}
+
+type HARContent struct {
+	Size     int64  `json:"size"`
+	MimeType string `json:"mimeType"`
+	Text     string `json:"text,omitempty"`
+	Encoding string `json:"encoding,omitempty"`
+}
+
+// HARTimings are in milliseconds, with -1 for phases which do not apply.
+type HARTimings struct {
+	Blocked float64 `json:"blocked"`
+	DNS     float64 `json:"dns"`
+	Connect float64 `json:"connect"`
+	Send    float64 `json:"send"`
+	Wait    float64 `json:"wait"`
+	Receive float64 `json:"receive"`
+	SSL     float64 `json:"ssl"`
+}
+
+// redacted replaces credentials and tokens in HAR exports.
+const redacted = "REDACTED"
+
+// HAR returns the requests recorded by Capture, including those of the
+// last login. Passwords, tokens and cookies are redacted, so that the
+// archive can be shared.
+func (a *Agent) HAR() *HAR {
+	h := &HAR{Log: HARLog{
+		Version: "1.2",
+		Creator: HARCreator{Name: "newworld-cadview-agent", Version: "1"},
+		Entries: []HAREntry{},
+	}}
+	if a.Capture == nil {
+		return h
+	}
+	for _, x := range a.Capture.Exchanges() {
+		h.Log.Entries = append(h.Log.Entries, x.harEntry())
+	}
+	return h
+}
+
+// WriteHAR writes the HAR as JSON.
+func (a *Agent) WriteHAR(w io.Writer) error {
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(a.HAR())
+}
+
+func (x Exchange) harEntry() HAREntry {
+	e := HAREntry{
+		StartedDateTime: x.Started,
+		Request: HARRequest{
+			Method:      x.Request.Method,
+			URL:         redactURL(x.Request.URL),
+			HTTPVersion: "",
+			Cookies:     []HARCookie{},
+			Headers:     harHeaders(x.Request.Headers),
+			QueryString: []HARNameValue{},
+			HeadersSize: -1,
+			BodySize:    0,
+		},
+		Response: HARResponse{
+			Cookies:     []HARCookie{},
+			Headers:     []HARNameValue{},
+			HeadersSize: -1,
+			BodySize:    -1,
+		},
+		Timings: HARTimings{Blocked: -1, DNS: -1, Connect: -1, SSL: -1},
+		Comment: x.Error,
+	}
+
+	if u, err := url.Parse(x.Request.URL); err == nil {
+		for name, values := range u.Query() {
+			for _, v := range values {
+				if sensitiveParam(name) {
+					v = redacted
+				}
+				e.Request.QueryString = append(e.Request.QueryString, HARNameValue{Name: name, Value: v})
+			}
+		}
+		sort.Slice(e.Request.QueryString, func(i, j int) bool { return e.Request.QueryString[i].Name < e.Request.QueryString[j].Name })
+	}
+	if x.Request.HasPostData {
+		body := []byte{}
+		for _, p := range x.Request.PostDataEntries {
+			b, _ := base64.StdEncoding.DecodeString(p.Bytes)
+			body = append(body, b...)
+		}
+		mimeType := headerValue(x.Request.Headers, "Content-Type")
+		e.Request.PostData = &HARPostData{MimeType: mimeType, Text: redactBody(mimeType, body)}
+		e.Request.BodySize = int64(len(body))
+	}
+
+	r := x.Response
+	if r == nil {
+		return e
+	}
+	e.Request.HTTPVersion = harProtocol(r.Protocol)
+	if len(r.RequestHeaders) > 0 {
+		// The headers actually sent, including cookies
+		e.Request.Headers = harHeaders(r.RequestHeaders)
+	}
+	e.Response.Status = r.Status
+	e.Response.StatusText = r.StatusText
+	e.Response.HTTPVersion = harProtocol(r.Protocol)
+	e.Response.Headers = harHeaders(r.Headers)
+	e.Response.RedirectURL = redactURL(headerValue(r.Headers, "Location"))
+	e.Response.Content = HARContent{Size: int64(len(x.Body)), MimeType: r.MimeType}
+	if x.Body != nil {
+		if isText(r.MimeType) {
+			e.Response.Content.Text = redactBody(r.MimeType, x.Body)
+		} else {
+			e.Response.Content.Text = base64.StdEncoding.EncodeToString(x.Body)
+			e.Response.Content.Encoding = "base64"
+		}
+	}
+	if x.Size > 0 {
+		e.Response.BodySize = x.Size
+	}
+	e.ServerIPAddress = r.RemoteIPAddress
+	if r.ConnectionID != 0 {
+		e.Connection = fmt.Sprintf("%.0f", r.ConnectionID)
+	}
+
+	if t := r.Timing; t != nil {
+		phase := func(start, end float64) float64 {
+			if start < 0 || end < 0 {
+				return -1
+			}
+			return end - start
+		}
+		e.Timings.DNS = phase(t.DNSStart, t.DNSEnd)
+		e.Timings.Connect = phase(t.ConnectStart, t.ConnectEnd)
+		e.Timings.SSL = phase(t.SslStart, t.SslEnd)
+		e.Timings.Send = t.SendEnd - t.SendStart
+		e.Timings.Wait = t.ReceiveHeadersEnd - t.SendEnd
+		// Time from the request being issued until it was sent, less DNS
+		// and connection setup
+		blocked := t.SendStart
+		if e.Timings.DNS > 0 {
+			blocked -= e.Timings.DNS
+		}
+		if e.Timings.Connect > 0 {
+			// Includes SSL, as in the HAR spec
+			blocked -= e.Timings.Connect
+		}
+		e.Timings.Blocked = max(0, blocked)
+		if x.finished > 0 {
+			e.Timings.Receive = max(0, (x.finished-t.RequestTime)*1000-t.ReceiveHeadersEnd)
+		}
+	} else if x.finished > 0 && x.sent > 0 {
+		e.Timings.Wait = (x.finished - x.sent) * 1000
+	}
+	e.Time = max(0, e.Timings.Blocked) + max(0, e.Timings.DNS) + max(0, e.Timings.Connect) + e.Timings.Send + e.Timings.Wait + e.Timings.Receive
+	return e
+}
+
+func harProtocol(p string) string {
+	switch p {
+	case "":
+		return ""
+	case "h2":
+		return "HTTP/2.0"
+	case "h3":
+		return "HTTP/3"
+	}
+	return strings.ToUpper(p)
+}
+
+// harHeaders returns headers sorted by name, with credentials redacted.
+func harHeaders(h network.Headers) []HARNameValue {
+	out := []HARNameValue{}
+	for name, v := range h {
+		// Repeated headers are joined with newlines
+		for _, value := range strings.Split(fmt.Sprint(v), "\n") {
+			switch strings.ToLower(name) {
+			case "authorization", "cookie", "set-cookie", "proxy-authorization":
+				value = redacted
+			case "location", "referer", "content-location":
+				value = redactURL(value)
+			}
+			out = append(out, HARNameValue{Name: name, Value: value})
+		}
+	}
+	sort.SliceStable(out, func(i, j int) bool { return out[i].Name < out[j].Name })
+	return out
+}
+
+func headerValue(h network.Headers, name string) string {
+	for k, v := range h {
+		if strings.EqualFold(k, name) {
+			return fmt.Sprint(v)
+		}
+	}
+	return ""
+}
+
+func isText(mimeType string) bool {
+	mt, _, _ := mime.ParseMediaType(mimeType)
+	return strings.HasPrefix(mt, "text/") || strings.HasSuffix(mt, "json") || strings.HasSuffix(mt, "xml") ||
+		mt == "application/javascript" || mt == "application/x-www-form-urlencoded"
+}
+
+// sensitive reports whether a form or JSON field holds a credential.
+func sensitive(name string) bool {
+	name = strings.ToLower(name)
+	return strings.Contains(name, "password") || strings.Contains(name, "secret") || strings.Contains(name, "token")
+}
+
+// sensitiveParam reports whether a URL parameter holds a credential, which
+// includes the OIDC authorization code.
+func sensitiveParam(name string) bool {
+	return sensitive(name) || strings.EqualFold(name, "code")
+}
+
+// redactURL redacts credentials in the query and fragment of a URL, where
+// OIDC redirects carry codes and tokens, leaving the rest as it was.
+func redactURL(raw string) string {
+	rest, fragment, hasFragment := strings.Cut(raw, "#")
+	base, query, hasQuery := strings.Cut(rest, "?")
+	out := base
+	if hasQuery {
+		out += "?" + redactQuery(query)
+	}
+	if hasFragment {
+		out += "#" + redactQuery(fragment)
+	}
+	return out
+}
+
+// redactQuery redacts the values of sensitive parameters in a query string,
+// keeping their order.
+func redactQuery(query string) string {
+	params := strings.Split(query, "&")
+	for i, p := range params {
+		name, _, ok := strings.Cut(p, "=")
+		if !ok {
+			continue
+		}
+		if n, err := url.QueryUnescape(name); err == nil && sensitiveParam(n) {
+			params[i] = name + "=" + redacted
+		}
+	}
+	return strings.Join(params, "&")
+}
+
+// redactBody redacts credentials in form and JSON bodies.
+func redactBody(mimeType string, body []byte) string {
+	mt, _, _ := mime.ParseMediaType(mimeType)
+	switch {
+	case mt == "application/x-www-form-urlencoded":
+		values, err := url.ParseQuery(string(body))
+		if err != nil {
+			return string(body)
+		}
+		for name := range values {
+			if sensitive(name) {
+				values[name] = []string{redacted}
+			}
+		}
+		return values.Encode()
+	case strings.HasSuffix(mt, "json"):
+		var v any
+		if err := json.Unmarshal(body, &v); err != nil {
+			return string(body)
+		}
+		if !redactJSON(v) {
+			return string(body)
+		}
+		b, _ := json.Marshal(v)
+		return string(b)
+	}
+	return string(body)
+}
+
+// redactJSON redacts sensitive fields in place, reporting whether any were
+// found.
+func redactJSON(v any) bool {
+	found := false
+	switch t := v.(type) {
+	case map[string]any:
+		for k, child := range t {
+			if _, ok := child.(string); ok && sensitive(k) {
+				t[k] = redacted
+				found = true
+			} else if redactJSON(child) {
+				found = true
+			}
+		}
+	case []any:
+		for _, child := range t {
+			if redactJSON(child) {
+				found = true
+			}
+		}
+	}
+	return found
+}
@@ -0,0 +1,130 @@
+package agent
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/json"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/chromedp/cdproto/cdp"
+	"github.com/chromedp/cdproto/network"
+)
+
+func Test_HAR_Export(t *testing.T) {
+	mono := func(s float64) *cdp.MonotonicTime {
+		m := cdp.MonotonicTime(time.Unix(0, int64(s*float64(time.Second))))
+		return &m
+	}
+	wall := cdp.TimeSinceEpoch(time.Date(2022, 11, 13, 10, 25, 54, 0, time.UTC))
+
+	a := &Agent{Capture: &Capture{}}
+	form := "Username=user&Password=hunter2&__RequestVerificationToken=abc"
+	for _, ev := range []interface{}{
+		&network.EventRequestWillBeSent{
+			RequestID: "1",
+			Request: &network.Request{
+				Method:          "POST",
+				URL:             "https://cadview.example.org/identity/login?ReturnUrl=%2F",
+				Headers:         network.Headers{"Content-Type": "application/x-www-form-urlencoded", "Cookie": "session=1"},
+				HasPostData:     true,
+				PostDataEntries: []*network.PostDataEntry{{Bytes: base64.StdEncoding.EncodeToString([]byte(form))}},
+			},
+			Timestamp: mono(100),
+			WallTime:  &wall,
+		},
+		// Redirected, reusing the request ID
+		&network.EventRequestWillBeSent{
+			RequestID: "1",
+			Request:   &network.Request{Method: "GET", URL: "https://cadview.example.org/NewWorld.CadView/"},
+			RedirectResponse: &network.Response{
+				Status:  302,
+				Headers: network.Headers{"Location": "/NewWorld.CadView/", "Set-Cookie": "session=2"},
+			},
+			Timestamp: mono(100.2),
+		},
+		&network.EventRequestWillBeSent{
+			RequestID: "2",
+			Request:   &network.Request{Method: "GET", URL: "https://cadview.example.org/app.js"},
+		},
+		&network.EventResponseReceived{
+			RequestID: "1",
+			Response: &network.Response{
+				Status:   200,
+				MimeType: "application/json",
+				Protocol: "h2",
+				Headers:  network.Headers{"Content-Type": "application/json"},
+				Timing: &network.ResourceTiming{
+					RequestTime: 100.2, DNSStart: -1, DNSEnd: -1, ConnectStart: -1, ConnectEnd: -1, SslStart: -1, SslEnd: -1,
+					SendStart: 1, SendEnd: 2, ReceiveHeadersEnd: 52,
+				},
+			},
+		},
+		&network.EventLoadingFinished{RequestID: "1", Timestamp: mono(100.3), EncodedDataLength: 120},
+	} {
+		a.Capture.event(ev)
+	}
+	a.Capture.setBody("1", []byte(`{"user":"user","access_token":"secret"}`))
+
+	var buf bytes.Buffer
+	if err := a.WriteHAR(&buf); err != nil {
+		t.Fatal(err)
+	}
+	if strings.Contains(buf.String(), "hunter2") || strings.Contains(buf.String(), `"secret"`) || strings.Contains(buf.String(), "session=") {
+		t.Fatalf("credentials not redacted: %s", buf.String())
+	}
+	var h HAR
+	if err := json.Unmarshal(buf.Bytes(), &h); err != nil {
+		t.Fatal(err)
+	}
+	if h.Log.Version != "1.2" || len(h.Log.Entries) != 2 {
+		t.Fatalf("expected 2 entries, got %#v", h.Log)
+	}
+
+	login, app := h.Log.Entries[0], h.Log.Entries[1]
+	if login.Response.Status != 302 || login.Response.RedirectURL != "/NewWorld.CadView/" || !login.StartedDateTime.Equal(time.Time(wall)) {
+		t.Errorf("unexpected redirect entry %#v", login)
+	}
+	if login.Request.PostData == nil || !strings.Contains(login.Request.PostData.Text, "Username=user") || login.Request.QueryString[0].Value != "/" {
+		t.Errorf("unexpected request %#v", login.Request)
+	}
+	if app.Response.Status != 200 || app.Response.HTTPVersion != "HTTP/2.0" || app.Response.BodySize != 120 || !strings.Contains(app.Response.Content.Text, `"user":"user"`) {
+		t.Errorf("unexpected response %#v", app.Response)
+	}
+	if tm := app.Timings; tm.DNS != -1 || tm.Send != 1 || tm.Wait != 50 || tm.Receive < 47 || tm.Receive > 49 {
+		t.Errorf("unexpected timings %#v", tm)
+	}
+}
+
+func Test_HAR_RedactURL(t *testing.T) {
+	for raw, want := range map[string]string{
+		"https://cad/api/Call/GetCall?callId=1":                              "https://cad/api/Call/GetCall?callId=1",
+		"https://cad/signin-oidc?code=abc&state=xyz":                         "https://cad/signin-oidc?code=REDACTED&state=xyz",
+		"https://cad/api?access_token=abc&x=1#frag":                          "https://cad/api?access_token=REDACTED&x=1#frag",
+		"https://cad/callback#id_token=abc&access_token=def&expires_in=3600": "https://cad/callback#id_token=REDACTED&access_token=REDACTED&expires_in=3600",
+		"/NewWorld.CadView/":                                                 "/NewWorld.CadView/",
+	} {
+		if got := redactURL(raw); got != want {
+			t.Errorf("%s: expected %s, got %s", raw, want, got)
+		}
+	}
+
+	x := Exchange{
+		Request: &network.Request{Method: "GET", URL: "https://cad/connect/authorize?client_secret=s3cr3t&client_id=cadview"},
+		Response: &network.Response{
+			Status:  302,
+			Headers: network.Headers{"Location": "https://cad/signin-oidc?code=c0de&state=1"},
+		},
+	}
+	e := x.harEntry()
+	b, _ := json.Marshal(e)
+	for _, secret := range []string{"s3cr3t", "c0de"} {
+		if strings.Contains(string(b), secret) {
+			t.Errorf("%s not redacted: %s", secret, b)
+		}
+	}
+	if e.Response.RedirectURL != "https://cad/signin-oidc?code=REDACTED&state=1" {
+		t.Errorf("unexpected redirect %s", e.Response.RedirectURL)
+	}
+}
@@ -0,0 +1,166 @@
+// Command openapigen builds OpenAPI schemas from the struct types in the
+// agent package source, using the JSON tags for property names and the
+// inline comments, like // "2022-00000345", for examples.
+//
+//	go run ./internal/openapigen -out server/openapi_schemas.json obj.go watcher.go status.go
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"log"
+	"os"
+	"reflect"
+	"strings"
+)
+
+var out = flag.String("out", "", "Output file, defaults to standard output")
+
+func main() {
+	flag.Parse()
+	if flag.NArg() == 0 {
+		log.Fatalf("ERR: no input files")
+	}
+
+	schemas := map[string]any{}
+	fset := token.NewFileSet()
+	for _, path := range flag.Args() {
+		f, err := parser.ParseFile(fset, path, nil, parser.ParseComments)
+		if err != nil {
+			log.Fatalf("ERR: %s", err.Error())
+		}
+		for _, decl := range f.Decls {
+			gd, ok := decl.(*ast.GenDecl)
+			if !ok || gd.Tok != token.TYPE {
+				continue
+			}
+			for _, spec := range gd.Specs {
+				ts := spec.(*ast.TypeSpec)
+				st, ok := ts.Type.(*ast.StructType)
+				if !ok || !ts.Name.IsExported() {
+					continue
+				}
+				schema := structSchema(st)
+				if len(schema["properties"].(map[string]any)) == 0 {
+					// Not serialized, like Watcher
+					continue
+				}
+				doc := ts.Doc
+				if doc == nil && len(gd.Specs) == 1 {
+					doc = gd.Doc
+				}
+				if d := docText(doc); d != "" {
+					schema["description"] = d
+				}
+				schemas[ts.Name.Name] = schema
+			}
+		}
+	}
+
+	w := os.Stdout
+	if *out != "" {
+		f, err := os.Create(*out)
+		if err != nil {
+			log.Fatalf("ERR: %s", err.Error())
+		}
+		defer f.Close()
+		w = f
+	}
+	enc := json.NewEncoder(w)
+	enc.SetEscapeHTML(false)
+	enc.SetIndent("", "  ")
+	if err := enc.Encode(schemas); err != nil {
+		log.Fatalf("ERR: %s", err.Error())
+	}
+}
+
+func structSchema(st *ast.StructType) map[string]any {
+	props := map[string]any{}
+	for _, field := range st.Fields.List {
+		if len(field.Names) == 0 || field.Tag == nil {
+			// Embedded types, such as gorm.Model, are not serialized
+			continue
+		}
+		tag := reflect.StructTag(strings.Trim(field.Tag.Value, "`"))
+		name, _, _ := strings.Cut(tag.Get("json"), ",")
+		if name == "-" || name == "" || !field.Names[0].IsExported() {
+			continue
+		}
+		schema := typeSchema(field.Type)
+		if d := docText(field.Doc); d != "" {
+			schema["description"] = d
+		}
+		if ex, ok := example(field.Comment); ok {
+			schema["example"] = ex
+		}
+		props[name] = schema
+	}
+	return map[string]any{
+		"type":       "object",
+		"properties": props,
+	}
+}
+
+func typeSchema(expr ast.Expr) map[string]any {
+	switch t := expr.(type) {
+	case *ast.Ident:
+		switch t.Name {
+		case "string":
+			return map[string]any{"type": "string"}
+		case "bool":
+			return map[string]any{"type": "boolean"}
+		case "int", "int32":
+			return map[string]any{"type": "integer", "format": "int32"}
+		case "int64", "uint64":
+			return map[string]any{"type": "integer", "format": "int64"}
+		case "float32":
+			return map[string]any{"type": "number", "format": "float"}
+		case "float64":
+			return map[string]any{"type": "number", "format": "double"}
+		case "EventType":
+			return map[string]any{"type": "string"}
+		}
+		return map[string]any{"$ref": "#/components/schemas/" + t.Name}
+	case *ast.StarExpr:
+		return typeSchema(t.X)
+	case *ast.ArrayType:
+		return map[string]any{"type": "array", "items": typeSchema(t.Elt)}
+	case *ast.MapType:
+		return map[string]any{"type": "object", "additionalProperties": typeSchema(t.Value)}
+	case *ast.StructType:
+		return structSchema(t)
+	case *ast.SelectorExpr:
+		if fmt.Sprintf("%s.%s", t.X, t.Sel) == "time.Time" {
+			return map[string]any{"type": "string", "format": "date-time"}
+		}
+	}
+	return map[string]any{}
+}
+
+// example decodes the first JSON value in a trailing field comment, like
+// // "11/13/2022 10:25:54" or // ["04040-561","04090"].
+func example(cg *ast.CommentGroup) (any, bool) {
+	if cg == nil {
+		return nil, false
+	}
+	text := strings.TrimSpace(strings.TrimPrefix(cg.List[0].Text, "//"))
+	if text == "" {
+		return nil, false
+	}
+	var v any
+	if err := json.NewDecoder(strings.NewReader(text)).Decode(&v); err != nil {
+		return nil, false
+	}
+	return v, true
+}
+
+func docText(cg *ast.CommentGroup) string {
+	if cg == nil {
+		return ""
+	}
+	return strings.Join(strings.Fields(cg.Text()), " ")
+}
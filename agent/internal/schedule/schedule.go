@@ -0,0 +1,65 @@
+// Package schedule runs jobs once a day at a local time of day.
+package schedule
+
+import (
+	"fmt"
+	"log"
+	"strings"
+	"sync/atomic"
+	"time"
+)
+
+// Next returns the first time after t at the local time of day at, like
+// "06:00". An empty at is midnight.
+func Next(at string, t time.Time) (time.Time, error) {
+	hour, minute := 0, 0
+	if at != "" {
+		parsed, err := time.Parse("15:04", strings.TrimSpace(at))
+		if err != nil {
+			return t, fmt.Errorf("invalid time of day %q: %w", at, err)
+		}
+		hour, minute = parsed.Hour(), parsed.Minute()
+	}
+	next := time.Date(t.Year(), t.Month(), t.Day(), hour, minute, 0, 0, t.Location())
+	if !next.After(t) {
+		next = next.AddDate(0, 0, 1)
+	}
+	return next, nil
+}
+
+// Daily calls a function for each period ending at a time of day.
+type Daily struct {
+	cancelled atomic.Bool
+}
+
+// Start calls run in the background each day at the time of day at, with
+// the period ending then, until Stop is called. The period defaults to 24
+// hours. Errors are logged with the name of the job.
+func (d *Daily) Start(name, at string, period time.Duration, run func(from, to time.Time) error) error {
+	if _, err := Next(at, time.Now()); err != nil {
+		return err
+	}
+	if period <= 0 {
+		period = 24 * time.Hour
+	}
+	go func() {
+		for {
+			next, _ := Next(at, time.Now())
+			for time.Now().Before(next) {
+				time.Sleep(time.Second)
+				if d.cancelled.Load() {
+					return
+				}
+			}
+			if err := run(next.Add(-period), next); err != nil {
+				log.Printf("ERR: %s: %s", name, err.Error())
+			}
+		}
+	}()
+	return nil
+}
+
+// Stop ends the schedule started with Start.
+func (d *Daily) Stop() {
+	d.cancelled.Store(true)
+}
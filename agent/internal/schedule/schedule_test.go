@@ -0,0 +1,40 @@
+package schedule
+
+import (
+	"testing"
+	"time"
+)
+
+func Test_Next(t *testing.T) {
+	ny, err := time.LoadLocation("America/New_York")
+	if err != nil {
+		t.Skipf("no time zone database: %s", err.Error())
+	}
+	for _, tc := range []struct {
+		name string
+		at   string
+		now  time.Time
+		want time.Time
+	}{
+		{"later today", "06:00", time.Date(2022, 11, 13, 5, 0, 0, 0, ny), time.Date(2022, 11, 13, 6, 0, 0, 0, ny)},
+		{"already passed", "06:00", time.Date(2022, 11, 13, 6, 0, 0, 0, ny), time.Date(2022, 11, 14, 6, 0, 0, 0, ny)},
+		{"default midnight", "", time.Date(2022, 11, 13, 23, 0, 0, 0, ny), time.Date(2022, 11, 14, 0, 0, 0, 0, ny)},
+		{"padded", " 18:30 ", time.Date(2022, 11, 13, 12, 0, 0, 0, ny), time.Date(2022, 11, 13, 18, 30, 0, 0, ny)},
+		{"spring forward", "06:00", time.Date(2022, 3, 13, 0, 0, 0, 0, ny), time.Date(2022, 3, 13, 6, 0, 0, 0, ny)},
+	} {
+		got, err := Next(tc.at, tc.now)
+		if err != nil {
+			t.Fatalf("%s: ERR: %s", tc.name, err.Error())
+		}
+		if !got.Equal(tc.want) {
+			t.Errorf("%s: expected %s, got %s", tc.name, tc.want, got)
+		}
+	}
+
+	if _, err := Next("6am", time.Now()); err == nil {
+		t.Errorf("expected an error for an invalid time")
+	}
+	if err := (&Daily{}).Start("test", "6am", 0, nil); err == nil {
+		t.Errorf("expected Start to reject an invalid time")
+	}
+}
@@ -0,0 +1,349 @@
+package agent
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"math/big"
+	"net/http"
+	"slices"
+	"strings"
+	"time"
+)
+
+// RequiredScope is the scope CadView requires of API tokens.
+const RequiredScope = "cadviewapi.consumer"
+
+var (
+	// ErrTokenExpired wraps ErrNotAuthorized, so that callers which log in
+	// again when not authorized also do so for an expired token.
+	ErrTokenExpired = fmt.Errorf("%w: token expired", ErrNotAuthorized)
+	// The other token errors do not, as logging in again would not help.
+	ErrTokenAudience  = errors.New("token audience")
+	ErrTokenScope     = fmt.Errorf("token missing scope %s", RequiredScope)
+	ErrTokenSignature = errors.New("token signature")
+)
+
+// Token is a parsed JWT. Parsing does not verify the signature; use Verify
+// for that.
+type Token struct {
+	Raw    string
+	Header map[string]any
+	Claims map[string]any
+
+	signed    string
+	signature []byte
+}
+
+// ParseToken parses a JWT without verifying its signature.
+func ParseToken(raw string) (*Token, error) {
+	parts := strings.Split(raw, ".")
+	if len(parts) != 3 {
+		return nil, fmt.Errorf("not a JWT")
+	}
+	t := &Token{Raw: raw, signed: parts[0] + "." + parts[1]}
+	for i, dst := range []*map[string]any{&t.Header, &t.Claims} {
+		b, err := base64.RawURLEncoding.DecodeString(parts[i])
+		if err != nil {
+			return nil, fmt.Errorf("JWT part %d: %w", i+1, err)
+		}
+		d := json.NewDecoder(strings.NewReader(string(b)))
+		d.UseNumber()
+		if err := d.Decode(dst); err != nil {
+			return nil, fmt.Errorf("JWT part %d: %w", i+1, err)
+		}
+	}
+	sig, err := base64.RawURLEncoding.DecodeString(parts[2])
+	if err != nil {
+		return nil, fmt.Errorf("JWT signature: %w", err)
+	}
+	t.signature = sig
+	return t, nil
+}
+
+// Issuer returns the iss claim.
+func (t *Token) Issuer() string {
+	return t.claimString("iss")
+}
+
+// Subject returns the sub claim.
+func (t *Token) Subject() string {
+	return t.claimString("sub")
+}
+
+// ClientID returns the client_id claim.
+func (t *Token) ClientID() string {
+	return t.claimString("client_id")
+}
+
+// Audience returns the aud claim, which may be a string or a list.
+func (t *Token) Audience() []string {
+	return t.claimList("aud")
+}
+
+// Scopes returns the scope claim, which may be a list or a space
+// separated string.
+func (t *Token) Scopes() []string {
+	return t.claimList("scope")
+}
+
+// HasScope reports whether the token grants a scope.
+func (t *Token) HasScope(scope string) bool {
+	return slices.Contains(t.Scopes(), scope)
+}
+
+// Expiry returns the exp claim, or the zero time if there is none.
+func (t *Token) Expiry() time.Time {
+	return t.claimTime("exp")
+}
+
+// IssuedAt returns the iat claim.
+func (t *Token) IssuedAt() time.Time {
+	return t.claimTime("iat")
+}
+
+// NotBefore returns the nbf claim.
+func (t *Token) NotBefore() time.Time {
+	return t.claimTime("nbf")
+}
+
+// Expired reports whether the token has expired.
+func (t *Token) Expired() bool {
+	exp := t.Expiry()
+	return !exp.IsZero() && !time.Now().Before(exp)
+}
+
+func (t *Token) claimString(name string) string {
+	s, _ := t.Claims[name].(string)
+	return s
+}
+
+func (t *Token) claimList(name string) []string {
+	switch v := t.Claims[name].(type) {
+	case string:
+		return strings.Fields(v)
+	case []any:
+		out := []string{}
+		for _, item := range v {
+			if s, ok := item.(string); ok {
+				out = append(out, s)
+			}
+		}
+		return out
+	}
+	return nil
+}
+
+func (t *Token) claimTime(name string) time.Time {
+	n, ok := t.Claims[name].(json.Number)
+	if !ok {
+		return time.Time{}
+	}
+	f, err := n.Float64()
+	if err != nil {
+		return time.Time{}
+	}
+	return time.Unix(int64(f), 0)
+}
+
+// JWK is a JSON web key, as published in a JWKS document.
+type JWK struct {
+	Kid string `json:"kid"`
+	Kty string `json:"kty"`
+	Alg string `json:"alg"`
+	Use string `json:"use"`
+	N   string `json:"n"`
+	E   string `json:"e"`
+	Crv string `json:"crv"`
+	X   string `json:"x"`
+	Y   string `json:"y"`
+}
+
+// Verify checks the token signature against a set of keys.
+func (t *Token) Verify(keys []JWK) error {
+	alg, _ := t.Header["alg"].(string)
+	kid, _ := t.Header["kid"].(string)
+	var hash crypto.Hash
+	switch alg {
+	case "RS256", "PS256", "ES256":
+		hash = crypto.SHA256
+	case "RS384", "PS384", "ES384":
+		hash = crypto.SHA384
+	case "RS512", "PS512", "ES512":
+		hash = crypto.SHA512
+	default:
+		return fmt.Errorf("%w: unsupported algorithm %q", ErrTokenSignature, alg)
+	}
+	h := hash.New()
+	h.Write([]byte(t.signed))
+	digest := h.Sum(nil)
+
+	for _, k := range keys {
+		if kid != "" && k.Kid != kid {
+			continue
+		}
+		pub, err := k.publicKey()
+		if err != nil {
+			continue
+		}
+		switch pub := pub.(type) {
+		case *rsa.PublicKey:
+			if strings.HasPrefix(alg, "RS") && rsa.VerifyPKCS1v15(pub, hash, digest, t.signature) == nil {
+				return nil
+			}
+			if strings.HasPrefix(alg, "PS") && rsa.VerifyPSS(pub, hash, digest, t.signature, nil) == nil {
+				return nil
+			}
+		case *ecdsa.PublicKey:
+			size := (pub.Curve.Params().BitSize + 7) / 8
+			if strings.HasPrefix(alg, "ES") && len(t.signature) == 2*size {
+				r := new(big.Int).SetBytes(t.signature[:size])
+				s := new(big.Int).SetBytes(t.signature[size:])
+				if ecdsa.Verify(pub, digest, r, s) {
+					return nil
+				}
+			}
+		}
+	}
+	return ErrTokenSignature
+}
+
+func (k JWK) publicKey() (crypto.PublicKey, error) {
+	switch k.Kty {
+	case "RSA":
+		n, err := base64.RawURLEncoding.DecodeString(k.N)
+		if err != nil {
+			return nil, err
+		}
+		e, err := base64.RawURLEncoding.DecodeString(k.E)
+		if err != nil {
+			return nil, err
+		}
+		return &rsa.PublicKey{N: new(big.Int).SetBytes(n), E: int(new(big.Int).SetBytes(e).Int64())}, nil
+	case "EC":
+		var curve elliptic.Curve
+		switch k.Crv {
+		case "P-256":
+			curve = elliptic.P256()
+		case "P-384":
+			curve = elliptic.P384()
+		case "P-521":
+			curve = elliptic.P521()
+		default:
+			return nil, fmt.Errorf("unsupported curve %q", k.Crv)
+		}
+		x, err := base64.RawURLEncoding.DecodeString(k.X)
+		if err != nil {
+			return nil, err
+		}
+		y, err := base64.RawURLEncoding.DecodeString(k.Y)
+		if err != nil {
+			return nil, err
+		}
+		return &ecdsa.PublicKey{Curve: curve, X: new(big.Int).SetBytes(x), Y: new(big.Int).SetBytes(y)}, nil
+	}
+	return nil, fmt.Errorf("unsupported key type %q", k.Kty)
+}
+
+// fetchJWKS retrieves the signing keys of the instance from its OpenID
+// discovery document, returning the issuer and keys.
+func (a *Agent) fetchJWKS() (string, []JWK, error) {
+	client := &http.Client{Timeout: 15 * time.Second}
+	get := func(url string, v any) error {
+		res, err := client.Get(url)
+		if err != nil {
+			return err
+		}
+		defer res.Body.Close()
+		if res.StatusCode != http.StatusOK {
+			return fmt.Errorf("%s: %s", url, res.Status)
+		}
+		return json.NewDecoder(res.Body).Decode(v)
+	}
+
+	var discovery struct {
+		Issuer  string `json:"issuer"`
+		JWKSURI string `json:"jwks_uri"`
+	}
+	if err := get(a.BaseUrl+"NewWorld.CadView/.well-known/openid-configuration", &discovery); err != nil {
+		return "", nil, err
+	}
+	if discovery.JWKSURI == "" {
+		return "", nil, fmt.Errorf("no jwks_uri in discovery document")
+	}
+	var jwks struct {
+		Keys []JWK `json:"keys"`
+	}
+	if err := get(discovery.JWKSURI, &jwks); err != nil {
+		return "", nil, err
+	}
+	return discovery.Issuer, jwks.Keys, nil
+}
+
+// AccessToken returns the parsed access token of the session.
+func (a *Agent) AccessToken() (*Token, error) {
+	return ParseToken(a.GetAuth().AccessToken)
+}
+
+// IDToken returns the parsed ID token of the session.
+func (a *Agent) IDToken() (*Token, error) {
+	return ParseToken(a.GetAuth().IDToken)
+}
+
+// checkToken refuses a session token which is expired, without the CadView
+// API scope or, if TokenAudience is set, for another audience. Tokens which
+// are not JWTs are checked against the expiry and scope CadView returned
+// with them.
+func (a *Agent) checkToken() error {
+	auth := a.GetAuth()
+	t, err := ParseToken(auth.AccessToken)
+	if err != nil {
+		if a.TokenExpired() {
+			return ErrTokenExpired
+		}
+		if auth.Scope != "" && !slices.Contains(strings.Fields(auth.Scope), RequiredScope) {
+			return ErrTokenScope
+		}
+		return nil
+	}
+
+	if t.Expired() {
+		return ErrTokenExpired
+	}
+	if a.TokenAudience != "" && !slices.Contains(t.Audience(), a.TokenAudience) {
+		return fmt.Errorf("%w: %s, not %s", ErrTokenAudience, strings.Join(t.Audience(), ","), a.TokenAudience)
+	}
+	if !t.HasScope(RequiredScope) {
+		return ErrTokenScope
+	}
+	return nil
+}
+
+// verifyToken checks the signature and issuer of the access token against
+// the discovery document of the instance. It is skipped with a warning if
+// the document cannot be retrieved.
+func (a *Agent) verifyToken() error {
+	t, err := a.AccessToken()
+	if err != nil {
+		log.Printf("WARN: Access token is not a JWT, not verifying: %s", err.Error())
+		return nil
+	}
+	issuer, keys, err := a.fetchJWKS()
+	if err != nil {
+		log.Printf("WARN: Not verifying token, signing keys unavailable: %s", err.Error())
+		return nil
+	}
+	if err := t.Verify(keys); err != nil {
+		return err
+	}
+	if issuer != "" && t.Issuer() != issuer {
+		return fmt.Errorf("%w: issuer %s, not %s", ErrTokenSignature, t.Issuer(), issuer)
+	}
+	return nil
+}
@@ -0,0 +1,130 @@
+package agent
+
+import (
+	"crypto"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func signToken(t *testing.T, key *rsa.PrivateKey, claims map[string]any) string {
+	enc := func(v any) string {
+		b, _ := json.Marshal(v)
+		return base64.RawURLEncoding.EncodeToString(b)
+	}
+	signed := enc(map[string]any{"alg": "RS256", "kid": "k1", "typ": "JWT"}) + "." + enc(claims)
+	digest := sha256.Sum256([]byte(signed))
+	sig, err := rsa.SignPKCS1v15(rand.Reader, key, crypto.SHA256, digest[:])
+	if err != nil {
+		t.Fatal(err)
+	}
+	return signed + "." + base64.RawURLEncoding.EncodeToString(sig)
+}
+
+func Test_Token_Check(t *testing.T) {
+	key, _ := rsa.GenerateKey(rand.Reader, 2048)
+	exp := time.Now().Add(time.Hour).Unix()
+	good := map[string]any{"iss": "https://cad.example.org/NewWorld.CadView", "aud": []string{"cadviewapi"}, "scope": []string{"openid", RequiredScope}, "exp": exp, "sub": "42"}
+
+	tok, err := ParseToken(signToken(t, key, good))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if tok.Subject() != "42" || !tok.HasScope(RequiredScope) || tok.Expiry().Unix() != exp || tok.Expired() {
+		t.Fatalf("unexpected token %#v", tok.Claims)
+	}
+
+	a := &Agent{}
+	for _, tc := range []struct {
+		audience string
+		change   map[string]any
+		want     error
+	}{
+		{"", nil, nil},
+		{"", map[string]any{"exp": time.Now().Add(-time.Minute).Unix()}, ErrTokenExpired},
+		// The audience is only checked when one is configured
+		{"", map[string]any{"aud": "otherapi"}, nil},
+		{"cadviewapi", map[string]any{"aud": "otherapi"}, ErrTokenAudience},
+		{"cadviewapi", nil, nil},
+		{"", map[string]any{"scope": "openid profile"}, ErrTokenScope},
+	} {
+		a.TokenAudience = tc.audience
+		claims := map[string]any{}
+		for k, v := range good {
+			claims[k] = v
+		}
+		for k, v := range tc.change {
+			claims[k] = v
+		}
+		a.SetAuth(OidcObj{TokenType: "Bearer", AccessToken: signToken(t, key, claims)})
+		// Only an expired token is worth logging in again for
+		err := a.checkToken()
+		if !errors.Is(err, tc.want) || errors.Is(err, ErrNotAuthorized) != (tc.want == ErrTokenExpired) {
+			t.Errorf("%v: expected %v, got %v", tc.change, tc.want, err)
+		}
+	}
+	if _, err := a.authorizedGet("http://127.0.0.1:1/"); !errors.Is(err, ErrTokenScope) {
+		t.Errorf("expected requests to be refused, got %v", err)
+	}
+
+	// Reference tokens fall back to the expiry and scope CadView returned
+	a.SetAuth(OidcObj{TokenType: "Bearer", AccessToken: "opaque", Scope: "openid " + RequiredScope, ExpiresAt: exp})
+	if err := a.checkToken(); err != nil {
+		t.Errorf("unexpected %v", err)
+	}
+	a.SetAuth(OidcObj{TokenType: "Bearer", AccessToken: "opaque", ExpiresAt: time.Now().Unix() - 1})
+	if err := a.checkToken(); !errors.Is(err, ErrTokenExpired) {
+		t.Errorf("expected expired, got %v", err)
+	}
+}
+
+func Test_Token_Verify(t *testing.T) {
+	key, _ := rsa.GenerateKey(rand.Reader, 2048)
+	other, _ := rsa.GenerateKey(rand.Reader, 2048)
+	var srv *httptest.Server
+	srv = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/NewWorld.CadView/.well-known/openid-configuration":
+			json.NewEncoder(w).Encode(map[string]string{"issuer": srv.URL + "/NewWorld.CadView", "jwks_uri": srv.URL + "/jwks"})
+		case "/jwks":
+			json.NewEncoder(w).Encode(map[string]any{"keys": []JWK{{
+				Kid: "k1", Kty: "RSA", Alg: "RS256",
+				N: base64.RawURLEncoding.EncodeToString(key.N.Bytes()),
+				E: base64.RawURLEncoding.EncodeToString(big.NewInt(int64(key.E)).Bytes()),
+			}}})
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer srv.Close()
+
+	claims := map[string]any{"iss": srv.URL + "/NewWorld.CadView", "scope": RequiredScope, "exp": time.Now().Add(time.Hour).Unix()}
+	a := &Agent{BaseUrl: srv.URL + "/"}
+	a.SetAuth(OidcObj{AccessToken: signToken(t, key, claims)})
+	if err := a.verifyToken(); err != nil {
+		t.Fatalf("expected valid signature, got %v", err)
+	}
+	a.SetAuth(OidcObj{AccessToken: signToken(t, other, claims)})
+	if err := a.verifyToken(); !errors.Is(err, ErrTokenSignature) {
+		t.Errorf("expected signature error, got %v", err)
+	}
+	claims["iss"] = "https://elsewhere.example.org"
+	a.SetAuth(OidcObj{AccessToken: signToken(t, key, claims)})
+	if err := a.verifyToken(); !errors.Is(err, ErrTokenSignature) {
+		t.Errorf("expected issuer error, got %v", err)
+	}
+
+	// Verification is skipped when the keys are unavailable
+	a.BaseUrl = "http://127.0.0.1:1/"
+	if err := a.verifyToken(); err != nil {
+		t.Errorf("expected verification to be skipped, got %v", err)
+	}
+}
@@ -0,0 +1,146 @@
+package agent
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/chromedp/cdproto/runtime"
+	"github.com/chromedp/chromedp"
+)
+
+func Test_LoginProfile_Overrides(t *testing.T) {
+	RegisterLoginProfile("https://cadview.other.org/", LoginProfile{
+		LoginPath:        "/Enterprise.CadView/account/login",
+		SubmitSelector:   "button[type=submit]",
+		StorageKeyPrefix: "oidc.user:https://sso.other.org",
+	})
+
+	p := (&Agent{BaseUrl: "https://CADVIEW.other.org/"}).LoginProfile()
+	if p.LoginPath != "Enterprise.CadView/account/login" || p.SubmitSelector != "button[type=submit]" {
+		t.Fatalf("override not applied: %#v", p)
+	}
+	if p.UsernameSelector != DefaultLoginProfile.UsernameSelector || p.SuccessSelector != DefaultLoginProfile.SuccessSelector {
+		t.Fatalf("defaults not applied: %#v", p)
+	}
+
+	// An agent's own profile takes precedence
+	own := &Agent{BaseUrl: "https://cadview.other.org/", Profile: &LoginProfile{SuccessURL: "/NewWorld.CadView/#/dashboard"}}
+	if p := own.LoginProfile(); p.SubmitSelector != DefaultLoginProfile.SubmitSelector || p.SuccessURL == "" {
+		t.Fatalf("agent profile not used: %#v", p)
+	}
+	if p := (&Agent{BaseUrl: "https://cadview.qvec.org/"}).LoginProfile(); p != DefaultLoginProfile {
+		t.Fatalf("expected default profile, got %#v", p)
+	}
+}
+
+func Test_LoginError_Classify(t *testing.T) {
+	timeout := fmt.Errorf("waiting: %w", context.DeadlineExceeded)
+	for _, tc := range []struct {
+		err    error
+		step   string
+		text   string
+		netlog []netEntry
+		want   string
+	}{
+		{ErrMFARejected, loginStepAwait, "", nil, LoginCauseMFA},
+		{errors.New("page load error net::ERR_CERT_AUTHORITY_INVALID"), loginStepLoad, "", nil, LoginCauseCertificate},
+		{timeout, loginStepAwait, "", []netEntry{{URL: "https://cad/", Error: "net::ERR_CERT_DATE_INVALID"}}, LoginCauseCertificate},
+		{timeout, loginStepAwait, "Sign in\nInvalid username or password.", nil, LoginCauseBadPassword},
+		{timeout, loginStepAwait, "Your account is locked. Contact your administrator.", nil, LoginCauseAccountLocked},
+		{timeout, loginStepAwait, "Invalid login. You have been locked out after 5 attempts.", nil, LoginCauseAccountLocked},
+		{timeout, loginStepAwait, "This account has been disabled", nil, LoginCauseAccountLocked},
+		// A certificate error explains whatever page was shown
+		{timeout, loginStepLoad, "Your connection is not private", []netEntry{{URL: "https://cad/", Error: "net::ERR_CERT_COMMON_NAME_INVALID"}}, LoginCauseCertificate},
+		{timeout, loginStepUsername, "", nil, LoginCauseSelectorNotFound},
+		{timeout, loginStepAwait, "", nil, LoginCauseTimeout},
+		{errors.New("domstorage"), loginStepToken, "", nil, LoginCauseUnknown},
+	} {
+		if got := classifyLogin(tc.err, tc.step, tc.text, tc.netlog); got != tc.want {
+			t.Errorf("%v during %s: expected %s, got %s", tc.err, tc.step, tc.want, got)
+		}
+	}
+}
+
+func Test_LoginError_Diagnostics(t *testing.T) {
+	a := &Agent{BaseUrl: "https://cadview.example.org/", DiagnosticsDir: t.TempDir()}
+	a.trace = loginTrace{step: loginStepSubmit}
+	a.traceEvent(&runtime.EventConsoleAPICalled{Type: runtime.APITypeError, Args: []*runtime.RemoteObject{{Description: "boom"}}})
+
+	le := a.diagnoseLogin(nil, context.DeadlineExceeded)
+	if le.Cause != LoginCauseSelectorNotFound || le.Step != loginStepSubmit {
+		t.Fatalf("unexpected %#v", le)
+	}
+	if !errors.Is(le, context.DeadlineExceeded) {
+		t.Errorf("expected wrapped error")
+	}
+	for _, name := range []string{"summary.json", "page.html", "console.log", "network.json", "login.har"} {
+		fi, err := os.Stat(filepath.Join(le.Dir, name))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if fi.Mode().Perm() != 0o600 {
+			t.Errorf("%s: expected mode 0600, got %v", name, fi.Mode().Perm())
+		}
+	}
+	b, _ := os.ReadFile(filepath.Join(le.Dir, "console.log"))
+	if !strings.Contains(string(b), "[error] boom") {
+		t.Errorf("console.log: %s", b)
+	}
+}
+
+func Test_LaunchOptions_Profile(t *testing.T) {
+	tmp := t.TempDir()
+	t.Setenv("TMPDIR", tmp)
+
+	// Each agent gets its own profile, removed when the browser closes
+	_, close1, err := (&Agent{}).newBrowser()
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, close2, _ := (&Agent{}).newBrowser()
+	if dirs, _ := os.ReadDir(tmp); len(dirs) != 2 {
+		t.Fatalf("expected 2 profiles, got %d", len(dirs))
+	}
+	close1()
+	close2()
+	if dirs, _ := os.ReadDir(tmp); len(dirs) != 0 {
+		t.Fatalf("expected profiles to be removed, got %d", len(dirs))
+	}
+
+	headless := false
+	opts := LaunchOptions{Headless: &headless, Flags: []string{"--lang=en-US", "disable-gpu"}}.execOptions(tmp)
+	if len(opts) != len(chromedp.DefaultExecAllocatorOptions)+6 {
+		t.Errorf("unexpected options: %d", len(opts))
+	}
+}
+
+func Test_RemoteSession_Agents(t *testing.T) {
+	r := &RemoteSession{}
+	if err := r.Start(); err == nil {
+		t.Fatal("expected error without a devtools URL")
+	}
+	r.CDP = "ws://127.0.0.1:1/devtools/browser/none"
+	if err := r.Start(); err == nil {
+		t.Fatal("expected connection error")
+	}
+	if _, _, err := (&Agent{Remote: r}).newBrowser(); err == nil {
+		t.Fatal("expected error from a session which is not started")
+	}
+
+	a1, a2 := &Agent{Remote: r}, &Agent{Remote: r}
+	r.attach(a1)
+	r.attach(a2)
+	r.attach(a1)
+	r.detach(a2)
+	if len(r.agents) != 1 || r.agents[0] != a1 {
+		t.Fatalf("unexpected agents %v", r.agents)
+	}
+	if !a1.keepBrowser() {
+		t.Error("expected the browser to be kept with a remote session")
+	}
+}
@@ -0,0 +1,186 @@
+// Package metrics exposes Prometheus metrics about the health of an Agent
+// and the activity of the CAD system it watches.
+package metrics
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/dayvillefire/newworld-cadview-agent/agent"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+// Metrics collects metrics for an Agent, and optionally the Watcher
+// polling it. It implements agent.Observer.
+type Metrics struct {
+	// Registry holds the metrics. It includes the Go runtime and process
+	// collectors.
+	Registry *prometheus.Registry
+
+	agent   *agent.Agent
+	watcher *agent.Watcher
+	// next is the Observer the agent had before, which is still notified
+	next agent.Observer
+
+	requests        *prometheus.CounterVec
+	requestDuration *prometheus.HistogramVec
+	logins          prometheus.Counter
+	loginFailures   prometheus.Counter
+	pings           *prometheus.CounterVec
+	lastPing        prometheus.Gauge
+}
+
+var (
+	tokenExpiryDesc = prometheus.NewDesc(
+		"cadview_token_expiry_seconds",
+		"Seconds until the session token expires, negative once it has expired.",
+		nil, nil)
+	activeCallsDesc = prometheus.NewDesc(
+		"cadview_active_calls",
+		"Active calls by ORI and priority, as of the last poll.",
+		[]string{"ori", "priority"}, nil)
+	syncLagDesc = prometheus.NewDesc(
+		"cadview_sync_lag_seconds",
+		"Seconds since the active call list was last polled successfully.",
+		nil, nil)
+)
+
+// New creates metrics for a and registers them as its Observer, passing
+// notifications on to any Observer it already had. w may be nil, in which
+// case active call and sync metrics are not reported.
+func New(a *agent.Agent, w *agent.Watcher) *Metrics {
+	m := &Metrics{
+		Registry: prometheus.NewRegistry(),
+		agent:    a,
+		watcher:  w,
+		requests: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "cadview_requests_total",
+			Help: "CadView API requests by endpoint and HTTP status, with status 0 for failed requests.",
+		}, []string{"endpoint", "status"}),
+		requestDuration: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Name:    "cadview_request_duration_seconds",
+			Help:    "CadView API request latency by endpoint.",
+			Buckets: prometheus.DefBuckets,
+		}, []string{"endpoint"}),
+		logins: prometheus.NewCounter(prometheus.CounterOpts{
+			Name: "cadview_login_attempts_total",
+			Help: "Browser login attempts.",
+		}),
+		loginFailures: prometheus.NewCounter(prometheus.CounterOpts{
+			Name: "cadview_login_failures_total",
+			Help: "Browser login attempts which failed.",
+		}),
+		pings: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "cadview_pings_total",
+			Help: "Session keepalive pings by result: ok, not_authorized or error.",
+		}, []string{"result"}),
+		lastPing: prometheus.NewGauge(prometheus.GaugeOpts{
+			Name: "cadview_last_successful_ping_timestamp_seconds",
+			Help: "Unix time of the last successful keepalive ping.",
+		}),
+	}
+	m.Registry.MustRegister(
+		collectors.NewGoCollector(),
+		collectors.NewProcessCollector(collectors.ProcessCollectorOpts{}),
+		m.requests, m.requestDuration,
+		m.logins, m.loginFailures,
+		m.pings, m.lastPing,
+		(*collector)(m),
+	)
+	for _, result := range []string{"ok", "not_authorized", "error"} {
+		m.pings.WithLabelValues(result)
+	}
+	m.next = a.Observer
+	a.Observer = m
+	return m
+}
+
+// Handler serves the metrics in the Prometheus exposition format.
+func (m *Metrics) Handler() http.Handler {
+	return promhttp.HandlerFor(m.Registry, promhttp.HandlerOpts{Registry: m.Registry})
+}
+
+// ObserveRequest implements agent.Observer.
+func (m *Metrics) ObserveRequest(endpoint string, status int, d time.Duration) {
+	m.requests.WithLabelValues(endpoint, strconv.Itoa(status)).Inc()
+	m.requestDuration.WithLabelValues(endpoint).Observe(d.Seconds())
+	if m.next != nil {
+		m.next.ObserveRequest(endpoint, status, d)
+	}
+}
+
+// ObserveLogin implements agent.Observer.
+func (m *Metrics) ObserveLogin(err error) {
+	m.logins.Inc()
+	if err != nil {
+		m.loginFailures.Inc()
+	}
+	if m.next != nil {
+		m.next.ObserveLogin(err)
+	}
+}
+
+// ObservePing implements agent.Observer.
+func (m *Metrics) ObservePing(err error) {
+	switch {
+	case err == nil:
+		m.pings.WithLabelValues("ok").Inc()
+		m.lastPing.SetToCurrentTime()
+	case errors.Is(err, agent.ErrNotAuthorized):
+		m.pings.WithLabelValues("not_authorized").Inc()
+	default:
+		m.pings.WithLabelValues("error").Inc()
+	}
+	if m.next != nil {
+		m.next.ObservePing(err)
+	}
+}
+
+// collector reports the metrics which are read from the agent and watcher
+// at scrape time.
+type collector Metrics
+
+func (c *collector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- tokenExpiryDesc
+	ch <- activeCallsDesc
+	ch <- syncLagDesc
+}
+
+func (c *collector) Collect(ch chan<- prometheus.Metric) {
+	if exp := c.agent.GetAuth().ExpiresAt; exp > 0 {
+		ch <- prometheus.MustNewConstMetric(tokenExpiryDesc, prometheus.GaugeValue,
+			time.Until(time.Unix(exp, 0)).Seconds())
+	}
+	if c.watcher == nil {
+		return
+	}
+	if last := c.watcher.LastPoll(); !last.IsZero() {
+		ch <- prometheus.MustNewConstMetric(syncLagDesc, prometheus.GaugeValue, time.Since(last).Seconds())
+	}
+	counts := countCalls(c.watcher.Calls())
+	for k, n := range counts {
+		ch <- prometheus.MustNewConstMetric(activeCallsDesc, prometheus.GaugeValue, float64(n), k.ori, k.priority)
+	}
+}
+
+type callKey struct{ ori, priority string }
+
+// countCalls counts calls by ORI and priority. Calls shared between ORIs
+// are counted for each.
+func countCalls(calls []agent.CallObj) map[callKey]int {
+	counts := map[callKey]int{}
+	for _, call := range calls {
+		oris := call.AllowedORI
+		if len(oris) == 0 {
+			oris = []string{""}
+		}
+		for _, ori := range oris {
+			counts[callKey{ori, call.CallPriority}]++
+		}
+	}
+	return counts
+}
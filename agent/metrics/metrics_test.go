@@ -0,0 +1,85 @@
+package metrics
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/dayvillefire/newworld-cadview-agent/agent"
+)
+
+func Test_Metrics_Scrape(t *testing.T) {
+	cad := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if strings.HasSuffix(r.URL.Path, "/api/Call/GetActiveCalls") {
+			json.NewEncoder(w).Encode([]agent.CallObj{
+				{CallID: 1, CallPriority: "1", AllowedORI: []string{"04040"}},
+				{CallID: 2, CallPriority: "1", AllowedORI: []string{"04040", "04090"}},
+			})
+			return
+		}
+		// An expired session is answered with the login page
+		w.Write([]byte("<html>"))
+	}))
+	defer cad.Close()
+
+	a := &agent.Agent{BaseUrl: cad.URL + "/"}
+	a.SetAuth(agent.OidcObj{TokenType: "Bearer", AccessToken: "test", ExpiresAt: time.Now().Add(time.Hour).Unix()})
+	w := &agent.Watcher{Agent: a}
+	m := New(a, w)
+
+	if _, err := a.GetActiveCalls(); err != nil {
+		t.Fatalf("ERR: %s", err.Error())
+	}
+	m.ObservePing(a.Ping())
+	m.ObserveLogin(nil)
+
+	rec := httptest.NewRecorder()
+	m.Handler().ServeHTTP(rec, httptest.NewRequest("GET", "/metrics", nil))
+	body := rec.Body.String()
+	for _, want := range []string{
+		`cadview_requests_total{endpoint="api/Call/GetActiveCalls",status="200"} 1`,
+		`cadview_request_duration_seconds_count{endpoint="api/CadView/Ping"} 1`,
+		`cadview_pings_total{result="not_authorized"} 1`,
+		`cadview_login_attempts_total 1`,
+		`cadview_login_failures_total 0`,
+		`cadview_token_expiry_seconds 3`,
+	} {
+		if !strings.Contains(body, want) {
+			t.Errorf("missing %q", want)
+		}
+	}
+}
+
+// pingCounter is an Observer which counts pings.
+type pingCounter struct{ pings int }
+
+func (p *pingCounter) ObserveRequest(string, int, time.Duration) {}
+func (p *pingCounter) ObserveLogin(error)                        {}
+func (p *pingCounter) ObservePing(error)                         { p.pings++ }
+
+func Test_Metrics_Chain(t *testing.T) {
+	prev := &pingCounter{}
+	a := &agent.Agent{Observer: prev}
+	m := New(a, nil)
+	if a.Observer != m {
+		t.Fatalf("expected the metrics to observe the agent")
+	}
+	a.Observer.ObservePing(nil)
+	if prev.pings != 1 {
+		t.Fatalf("expected the previous observer to be notified")
+	}
+}
+
+func Test_Metrics_CountCalls(t *testing.T) {
+	counts := countCalls([]agent.CallObj{
+		{CallID: 1, CallPriority: "1", AllowedORI: []string{"04040"}},
+		{CallID: 2, CallPriority: "1", AllowedORI: []string{"04040", "04090"}},
+		{CallID: 3, CallPriority: "3", AllowedORI: []string{"04090"}},
+	})
+	if counts[callKey{"04040", "1"}] != 2 || counts[callKey{"04090", "1"}] != 1 || counts[callKey{"04090", "3"}] != 1 {
+		t.Fatalf("unexpected counts %#v", counts)
+	}
+}
@@ -0,0 +1,273 @@
+package agent
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha1"
+	"encoding/base32"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"log"
+	"strings"
+	"time"
+
+	"github.com/chromedp/chromedp"
+)
+
+var (
+	// ErrMFARequired is returned by Init when the login asks for a second
+	// factor and Agent.MFA is not set.
+	ErrMFARequired = errors.New("login requires a second factor, set Agent.MFA")
+	// ErrMFARejected is returned by Init when the second factor code is not
+	// accepted.
+	ErrMFARejected = errors.New("second factor code was not accepted")
+)
+
+// mfaAttempts is the number of codes tried before giving up, allowing for
+// a TOTP code which expires as it is submitted.
+const mfaAttempts = 2
+
+// mfaSettle is how long to wait for the answer to a submitted code before
+// treating it as rejected.
+const mfaSettle = 30 * time.Second
+
+// mfaErrorSelector matches the validation messages commonly shown for a
+// rejected code.
+const mfaErrorSelector = "[role=alert], .validation-summary-errors, .field-validation-error, .text-danger, .alert-danger"
+
+// MFAChallenge describes a second factor prompt shown after the password
+// has been accepted.
+type MFAChallenge struct {
+	// URL is the address of the challenge page.
+	URL string
+	// Prompt is the visible text of the page, which usually says which
+	// factor is wanted.
+	Prompt string
+	// Attempt counts from 1, and is more than 1 if a previous code was
+	// rejected.
+	Attempt int
+	// Context is cancelled when the login is abandoned. Providers which
+	// wait, such as for a user to enter a code, should give up when it is
+	// done. It may be nil.
+	Context context.Context
+}
+
+// MFAProvider supplies the code for a second factor challenge.
+type MFAProvider interface {
+	Code(ch MFAChallenge) (string, error)
+}
+
+// MFAFunc adapts a function, like one prompting a user for a code sent by
+// SMS or email, to an MFAProvider.
+type MFAFunc func(ch MFAChallenge) (string, error)
+
+// Code implements MFAProvider.
+func (f MFAFunc) Code(ch MFAChallenge) (string, error) {
+	return f(ch)
+}
+
+// TOTP generates RFC 6238 time-based codes, as authenticator apps do.
+type TOTP struct {
+	// Secret is the base32 secret shown when the authenticator was enrolled,
+	// usually as the secret parameter of an otpauth:// URL.
+	Secret string
+	// Digits is the code length. Defaults to 6.
+	Digits int
+	// Period is how long each code is valid, in whole seconds. Defaults to
+	// 30 seconds, which is also used for periods under a second.
+	Period time.Duration
+}
+
+// Code implements MFAProvider. A retry waits for the next code, since the
+// rejected one may have been used or expired, unless the challenge context
+// is cancelled first.
+func (t TOTP) Code(ch MFAChallenge) (string, error) {
+	now := time.Now()
+	if ch.Attempt > 1 {
+		period := t.period()
+		wait := period - time.Duration(now.UnixNano())%period
+		log.Printf("INFO: Waiting %s for the next TOTP code", wait.Round(time.Second))
+		ctx := ch.Context
+		if ctx == nil {
+			ctx = context.Background()
+		}
+		select {
+		case <-ctx.Done():
+			return "", ctx.Err()
+		case <-time.After(wait):
+		}
+		now = now.Add(wait)
+	}
+	return t.CodeAt(now)
+}
+
+// CodeAt returns the code valid at a point in time.
+func (t TOTP) CodeAt(at time.Time) (string, error) {
+	secret := strings.ToUpper(strings.NewReplacer(" ", "", "-", "").Replace(t.Secret))
+	key, err := base32.StdEncoding.WithPadding(base32.NoPadding).DecodeString(strings.TrimRight(secret, "="))
+	if err != nil {
+		return "", fmt.Errorf("invalid TOTP secret: %w", err)
+	}
+	digits := t.Digits
+	if digits <= 0 {
+		digits = 6
+	}
+
+	var counter [8]byte
+	binary.BigEndian.PutUint64(counter[:], uint64(at.Unix()/int64(t.period()/time.Second)))
+	mac := hmac.New(sha1.New, key)
+	mac.Write(counter[:])
+	sum := mac.Sum(nil)
+	offset := sum[len(sum)-1] & 0x0f
+	code := binary.BigEndian.Uint32(sum[offset:offset+4]) & 0x7fffffff
+
+	mod := uint32(1)
+	for i := 0; i < digits; i++ {
+		mod *= 10
+	}
+	return fmt.Sprintf("%0*d", digits, code%mod), nil
+}
+
+func (t TOTP) period() time.Duration {
+	if t.Period < time.Second {
+		return 30 * time.Second
+	}
+	return t.Period.Truncate(time.Second)
+}
+
+// mfaFindJS defines find and visible for the login page scripts, which
+// accept XPath expressions or CSS selectors.
+const mfaFindJS = `
+	function find(sel) {
+		if (sel.charAt(0) == "/" || sel.charAt(0) == "(") {
+			return document.evaluate(sel, document, null, XPathResult.FIRST_ORDERED_NODE_TYPE, null).singleNodeValue;
+		}
+		return document.querySelector(sel);
+	}
+	function visible(el) {
+		return el && (el === document.documentElement || el.getClientRects().length > 0);
+	}`
+
+// awaitLogin waits for the login to succeed after the form is submitted,
+// answering any second factor challenge on the way.
+func (a *Agent) awaitLogin(ctx context.Context, p LoginProfile) error {
+	const js = `(function(mfa, success, successURL) {` + mfaFindJS + `
+		if (visible(find(mfa))) { return "mfa"; }
+		if (successURL && location.href.indexOf(successURL) >= 0) { return "success"; }
+		if (visible(find(success))) { return "success"; }
+		return "";
+	})(%q, %q, %q)`
+
+	attempt := 0
+	for {
+		var state string
+		if err := chromedp.Evaluate(fmt.Sprintf(js, p.MFASelector, p.SuccessSelector, p.SuccessURL), &state).Do(ctx); err != nil {
+			// The page may be navigating
+			if a.Debug {
+				log.Printf("DEBUG: awaitLogin: %s", err.Error())
+			}
+		}
+		switch state {
+		case "success":
+			return nil
+		case "mfa":
+			attempt++
+			if err := a.answerMFA(ctx, p, attempt); err != nil {
+				return err
+			}
+			// Only look for the input again once the code has been
+			// answered, so a slow reply is not taken as a rejection
+			if err := a.awaitMFAResult(ctx, p); err != nil {
+				return err
+			}
+			continue
+		}
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(500 * time.Millisecond):
+		}
+	}
+}
+
+// awaitMFAResult waits after a code is submitted until the login succeeds,
+// the code input goes away or an error message is shown.
+func (a *Agent) awaitMFAResult(ctx context.Context, p LoginProfile) error {
+	const js = `(function(success, successURL, errors) {` + mfaFindJS + `
+		if (successURL && location.href.indexOf(successURL) >= 0) { return "success"; }
+		if (visible(find(success))) { return "success"; }
+		if (!visible(document.querySelector("[data-cadview-mfa]"))) { return "gone"; }
+		var shown = document.querySelectorAll(errors);
+		for (var i = 0; i < shown.length; i++) {
+			if (visible(shown[i]) && shown[i].innerText.trim() != "") { return "error"; }
+		}
+		return "";
+	})(%q, %q, %q)`
+
+	settle := time.After(mfaSettle)
+	for {
+		var state string
+		if err := chromedp.Evaluate(fmt.Sprintf(js, p.SuccessSelector, p.SuccessURL, mfaErrorSelector), &state).Do(ctx); err != nil {
+			// Evaluating fails while the page navigates away
+			if a.Debug {
+				log.Printf("DEBUG: awaitMFAResult: %s", err.Error())
+			}
+		}
+		if state != "" {
+			if a.Debug {
+				log.Printf("DEBUG: awaitMFAResult: %s", state)
+			}
+			return nil
+		}
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-settle:
+			log.Printf("WARN: No answer to the second factor code after %s", mfaSettle)
+			return nil
+		case <-time.After(500 * time.Millisecond):
+		}
+	}
+}
+
+func (a *Agent) answerMFA(ctx context.Context, p LoginProfile, attempt int) error {
+	if a.MFA == nil {
+		return ErrMFARequired
+	}
+	if attempt > mfaAttempts {
+		return ErrMFARejected
+	}
+	log.Printf("INFO: Answering second factor challenge (attempt %d)", attempt)
+
+	ch := MFAChallenge{Attempt: attempt, Context: ctx}
+	if err := chromedp.Run(ctx,
+		chromedp.Location(&ch.URL),
+		chromedp.Evaluate(`document.body ? document.body.innerText : ""`, &ch.Prompt),
+	); err != nil {
+		return err
+	}
+	ch.Prompt = strings.TrimSpace(ch.Prompt)
+
+	code, err := a.MFA.Code(ch)
+	if err != nil {
+		return fmt.Errorf("second factor: %w", err)
+	}
+	by := chromedp.ByQuery
+	if isXPath(p.MFASelector) {
+		by = chromedp.BySearch
+	}
+	// Mark the input, so that awaitMFAResult can tell when it goes away
+	mark := fmt.Sprintf(`(function(mfa) {`+mfaFindJS+`
+		var el = find(mfa);
+		if (el) { el.setAttribute("data-cadview-mfa", "1"); }
+		return !!el;
+	})(%q)`, p.MFASelector)
+	var marked bool
+	return chromedp.Run(ctx,
+		chromedp.Evaluate(mark, &marked),
+		chromedp.SetValue(p.MFASelector, "", by),
+		chromedp.SendKeys(p.MFASelector, strings.TrimSpace(code), by),
+		chromedp.Submit(p.MFASelector, by),
+	)
+}
@@ -0,0 +1,56 @@
+package agent
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+func Test_TOTP_CodeAt(t *testing.T) {
+	// RFC 6238 appendix B, SHA1
+	totp := TOTP{Secret: "GEZDGNBVGY3TQOJQGEZDGNBVGY3TQOJQ", Digits: 8}
+	for at, want := range map[int64]string{
+		59:         "94287082",
+		1111111109: "07081804",
+		1234567890: "89005924",
+		2000000000: "69279037",
+	} {
+		code, err := totp.CodeAt(time.Unix(at, 0))
+		if err != nil || code != want {
+			t.Errorf("%d: expected %s, got %s (%v)", at, want, code, err)
+		}
+	}
+
+	// Secrets are often shown lower case and grouped
+	code, err := TOTP{Secret: "gezd gnbv gy3t qojq gezd gnbv gy3t qojq"}.CodeAt(time.Unix(59, 0))
+	if err != nil || code != "287082" {
+		t.Fatalf("expected 287082, got %s (%v)", code, err)
+	}
+}
+
+func Test_TOTP_Period(t *testing.T) {
+	// Periods under a second use the default rather than dividing by zero
+	want, _ := TOTP{Secret: "GEZDGNBVGY3TQOJQGEZDGNBVGY3TQOJQ"}.CodeAt(time.Unix(59, 0))
+	code, err := TOTP{Secret: "GEZDGNBVGY3TQOJQGEZDGNBVGY3TQOJQ", Period: 500 * time.Millisecond}.CodeAt(time.Unix(59, 0))
+	if err != nil || code != want {
+		t.Fatalf("expected %s, got %s (%v)", want, code, err)
+	}
+	if p := (TOTP{Period: 60 * time.Second}).period(); p != time.Minute {
+		t.Fatalf("unexpected period %s", p)
+	}
+}
+
+func Test_TOTP_Cancel(t *testing.T) {
+	// A retry waiting for the next code gives up when the login is abandoned
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	start := time.Now()
+	_, err := TOTP{Secret: "GEZDGNBVGY3TQOJQGEZDGNBVGY3TQOJQ", Period: time.Hour}.Code(MFAChallenge{Attempt: 2, Context: ctx})
+	if !errors.Is(err, context.Canceled) {
+		t.Fatalf("expected context.Canceled, got %v", err)
+	}
+	if time.Since(start) > time.Second {
+		t.Fatalf("Code waited %s after cancellation", time.Since(start))
+	}
+}
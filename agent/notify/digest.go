@@ -0,0 +1,276 @@
+package notify
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	htmltemplate "html/template"
+	"log"
+	"sort"
+	texttemplate "text/template"
+	"time"
+
+	"github.com/dayvillefire/newworld-cadview-agent/agent"
+	"github.com/dayvillefire/newworld-cadview-agent/agent/internal/schedule"
+)
+
+// Count is a labeled tally within a DigestReport.
+type Count struct {
+	Name  string
+	Count int
+}
+
+// CallDuration is a call and the time from creation until its last unit
+// cleared.
+type CallDuration struct {
+	Call     agent.CallObj
+	Duration time.Duration
+}
+
+// UnitCommitment is the number of calls a unit was assigned to and the
+// total time it was committed to them.
+type UnitCommitment struct {
+	Unit      string
+	Calls     int
+	Committed time.Duration
+}
+
+// DigestReport summarizes the cleared calls for a period.
+type DigestReport struct {
+	From       time.Time
+	To         time.Time
+	Total      int
+	ByORI      []Count
+	ByCallType []Count
+	Longest    []CallDuration
+	TopUnits   []UnitCommitment
+}
+
+// BuildDigest retrieves the cleared calls for each FDID between from and to
+// and summarizes them, keeping the top entries of the longest running call
+// and unit lists. The agent must be logged in. Calls which cannot be
+// retrieved are logged and left out of the longest call and unit lists.
+func BuildDigest(a *agent.Agent, from, to time.Time, fdids []string, top int) (DigestReport, error) {
+	out := DigestReport{From: from, To: to}
+	if top <= 0 {
+		top = 10
+	}
+	if len(fdids) == 0 {
+		fdids = []string{a.FDID}
+	}
+
+	oris, err := a.GetORIs()
+	if err != nil {
+		return out, err
+	}
+
+	calls := map[int64]agent.CallObj{}
+	for _, fdid := range fdids {
+		ori := agent.FDIDToORI(oris, fdid)
+		if ori == "" {
+			log.Printf("ERR: BuildDigest: no ORI found for FDID %s", fdid)
+			continue
+		}
+		cleared, err := a.GetClearedCalls(from, to, ori)
+		if err != nil {
+			return out, err
+		}
+		out.ByORI = append(out.ByORI, Count{Name: oriName(oris, fdid), Count: len(cleared)})
+		for _, c := range cleared {
+			calls[c.CallID] = c
+		}
+	}
+
+	types := map[string]int{}
+	units := map[string]*UnitCommitment{}
+	for _, c := range calls {
+		types[callType(c)]++
+
+		cad, err := a.RetrieveCADCall(c)
+		if errors.Is(err, agent.ErrNotAuthorized) {
+			return out, err
+		}
+		if err != nil {
+			log.Printf("ERR: BuildDigest: RetrieveCADCall(%d): %s", c.CallID, err.Error())
+			continue
+		}
+		created, err := agent.ParseDateTime(c.CreatedDateTime)
+		var last time.Time
+		for _, u := range cad.Units {
+			dispatched, derr := agent.ParseDateTime(u.DispatchDateTime)
+			cleared, cerr := agent.ParseDateTime(u.ClearDateTime)
+			uc, ok := units[u.UnitNumber]
+			if !ok {
+				uc = &UnitCommitment{Unit: u.UnitNumber}
+				units[u.UnitNumber] = uc
+			}
+			uc.Calls++
+			if derr == nil && cerr == nil && cleared.After(dispatched) {
+				uc.Committed += cleared.Sub(dispatched)
+			}
+			if cerr == nil && cleared.After(last) {
+				last = cleared
+			}
+		}
+		if err == nil && last.After(created) {
+			out.Longest = append(out.Longest, CallDuration{Call: c, Duration: last.Sub(created)})
+		}
+	}
+	out.Total = len(calls)
+
+	for name, count := range types {
+		out.ByCallType = append(out.ByCallType, Count{Name: name, Count: count})
+	}
+	sort.Slice(out.ByCallType, func(i, j int) bool {
+		if out.ByCallType[i].Count == out.ByCallType[j].Count {
+			return out.ByCallType[i].Name < out.ByCallType[j].Name
+		}
+		return out.ByCallType[i].Count > out.ByCallType[j].Count
+	})
+
+	sort.Slice(out.Longest, func(i, j int) bool {
+		return out.Longest[i].Duration > out.Longest[j].Duration
+	})
+	if len(out.Longest) > top {
+		out.Longest = out.Longest[:top]
+	}
+
+	for _, uc := range units {
+		out.TopUnits = append(out.TopUnits, *uc)
+	}
+	sort.Slice(out.TopUnits, func(i, j int) bool {
+		if out.TopUnits[i].Calls == out.TopUnits[j].Calls {
+			return out.TopUnits[i].Committed > out.TopUnits[j].Committed
+		}
+		return out.TopUnits[i].Calls > out.TopUnits[j].Calls
+	})
+	if len(out.TopUnits) > top {
+		out.TopUnits = out.TopUnits[:top]
+	}
+
+	return out, nil
+}
+
+func oriName(oris []agent.ORIObj, fdid string) string {
+	for _, o := range oris {
+		if o.FDID == fdid && o.AgencyName != "" {
+			return fmt.Sprintf("%s (%s)", o.AgencyName, fdid)
+		}
+	}
+	return fdid
+}
+
+func formatDuration(d time.Duration) string {
+	d = d.Round(time.Minute)
+	return fmt.Sprintf("%dh%02dm", int(d.Hours()), int(d.Minutes())%60)
+}
+
+var digestFuncs = map[string]any{
+	"duration": formatDuration,
+	"date":     func(t time.Time) string { return t.Format("01/02/2006 15:04") },
+}
+
+var digestText = texttemplate.Must(texttemplate.New("text").Funcs(digestFuncs).Parse(`CAD digest {{date .From}} - {{date .To}}
+
+Total calls: {{.Total}}
+
+Calls by ORI
+{{range .ByORI}}  {{.Name}}: {{.Count}}
+{{end}}
+Calls by type
+{{range .ByCallType}}  {{.Name}}: {{.Count}}
+{{end}}
+Longest running calls
+{{range .Longest}}  {{.Call.IncidentNumber}} {{.Call.CallType}}, {{.Call.Location}}: {{duration .Duration}}
+{{end}}
+Units with the most commitments
+{{range .TopUnits}}  {{.Unit}}: {{.Calls}} calls, {{duration .Committed}}
+{{end}}`))
+
+var digestHTML = htmltemplate.Must(htmltemplate.New("html").Funcs(digestFuncs).Parse(`<html><body style="font-family: sans-serif">
+<h2>CAD digest {{date .From}} - {{date .To}}</h2>
+<p>Total calls: <b>{{.Total}}</b></p>
+<h3>Calls by ORI</h3>
+<table cellpadding="4">{{range .ByORI}}<tr><td>{{.Name}}</td><td align="right">{{.Count}}</td></tr>{{end}}</table>
+<h3>Calls by type</h3>
+<table cellpadding="4">{{range .ByCallType}}<tr><td>{{.Name}}</td><td align="right">{{.Count}}</td></tr>{{end}}</table>
+<h3>Longest running calls</h3>
+<table cellpadding="4">
+<tr><th align="left">Incident</th><th align="left">Type</th><th align="left">Location</th><th align="right">Duration</th></tr>
+{{range .Longest}}<tr><td>{{.Call.IncidentNumber}}</td><td>{{.Call.CallType}}</td><td>{{.Call.Location}}</td><td align="right">{{duration .Duration}}</td></tr>
+{{end}}</table>
+<h3>Units with the most commitments</h3>
+<table cellpadding="4">
+<tr><th align="left">Unit</th><th align="right">Calls</th><th align="right">Committed</th></tr>
+{{range .TopUnits}}<tr><td>{{.Unit}}</td><td align="right">{{.Calls}}</td><td align="right">{{duration .Committed}}</td></tr>
+{{end}}</table>
+</body></html>`))
+
+// Render produces the plain text and HTML bodies of a report.
+func (r DigestReport) Render() (string, string, error) {
+	var text, html bytes.Buffer
+	if err := digestText.Execute(&text, r); err != nil {
+		return "", "", err
+	}
+	if err := digestHTML.Execute(&html, r); err != nil {
+		return "", "", err
+	}
+	return text.String(), html.String(), nil
+}
+
+// DigestJob mails a DigestReport on a daily schedule.
+type DigestJob struct {
+	Agent *agent.Agent
+	SMTP  SMTP
+	// FDIDs is the list of departments to report on. Defaults to the FDID of
+	// the agent.
+	FDIDs []string
+	// At is the local time of day to send the digest, like "06:00".
+	// Defaults to midnight.
+	At string
+	// Period is the length of time covered by each digest, ending when it
+	// is sent. Defaults to 24 hours.
+	Period time.Duration
+	// Top limits the longest call and unit lists. Defaults to 10.
+	Top int
+	// SubjectPrefix is prepended to the subject, like "[CAD] ".
+	SubjectPrefix string
+
+	schedule schedule.Daily
+}
+
+// Send builds and mails the digest for a period, logging in again and
+// retrying once if the session has expired.
+func (d *DigestJob) Send(from, to time.Time) error {
+	start := time.Now()
+	report, err := BuildDigest(d.Agent, from, to, d.FDIDs, d.Top)
+	if errors.Is(err, agent.ErrNotAuthorized) {
+		if err = d.Agent.ReauthorizeSince(start); err == nil {
+			report, err = BuildDigest(d.Agent, from, to, d.FDIDs, d.Top)
+		}
+	}
+	if err != nil {
+		return err
+	}
+	text, html, err := report.Render()
+	if err != nil {
+		return err
+	}
+	subject := fmt.Sprintf("%sCAD digest for %s", d.SubjectPrefix, to.Format("01/02/2006"))
+	return d.SMTP.Send(subject, text, html)
+}
+
+// NextRun returns the next scheduled send time after t.
+func (d *DigestJob) NextRun(t time.Time) (time.Time, error) {
+	return schedule.Next(d.At, t)
+}
+
+// Start sends digests in the background until Stop is called.
+func (d *DigestJob) Start() error {
+	return d.schedule.Start("DigestJob", d.At, d.Period, d.Send)
+}
+
+// Stop ends the schedule started with Start.
+func (d *DigestJob) Stop() {
+	d.schedule.Stop()
+}
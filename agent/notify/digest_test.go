@@ -0,0 +1,164 @@
+package notify
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/dayvillefire/newworld-cadview-agent/agent"
+)
+
+// digestCAD serves cleared calls, their details and their units, counting
+// the requests made for each endpoint.
+type digestCAD struct {
+	requests map[string]int
+	l        sync.Mutex
+}
+
+func (f *digestCAD) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	f.l.Lock()
+	f.requests[r.URL.Path[strings.LastIndex(r.URL.Path, "/")+1:]]++
+	f.l.Unlock()
+	var out any
+	switch {
+	case strings.HasSuffix(r.URL.Path, "/api/CadView/GetOrisForClearedCallSearch"):
+		out = []agent.ORIObj{{ORI: "26", FDID: "04040", AgencyName: "Pomfret"}}
+	case strings.HasSuffix(r.URL.Path, "/api/Call/SearchClearedCalls"):
+		out = []agent.CallObj{
+			{CallID: 1, CallType: "Structure Fire", IncidentNumber: "2022-00000345", Location: "120 FREEDLEY RD, Pomfret", CreatedDateTime: "11/13/2022 10:00:00"},
+			{CallID: 2, CallType: "Sick Person", IncidentNumber: "2022-00000346", CreatedDateTime: "11/13/2022 12:00:00"},
+			{CallID: 3, CallType: "Sick Person", IncidentNumber: "2022-00000347", CreatedDateTime: "11/13/2022 14:00:00"},
+		}
+	case strings.HasSuffix(r.URL.Path, "/api/Call/GetCall"):
+		id, _ := strconv.ParseInt(r.URL.Query().Get("id"), 10, 64)
+		out = agent.CallObj{CallID: id}
+	case strings.HasSuffix(r.URL.Path, "/api/Call/GetCallUnits"):
+		switch r.URL.Query().Get("id") {
+		case "1":
+			out = []agent.UnitObj{
+				{UnitNumber: "ENG70", DispatchDateTime: "11/13/2022 10:01:00", ClearDateTime: "11/13/2022 12:31:00"},
+				{UnitNumber: "TANKER70", DispatchDateTime: "11/13/2022 10:05:00", ClearDateTime: "11/13/2022 11:05:00"},
+			}
+		case "2":
+			out = []agent.UnitObj{{UnitNumber: "ENG70", DispatchDateTime: "11/13/2022 12:01:00", ClearDateTime: "11/13/2022 12:31:00"}}
+		default:
+			out = []agent.UnitObj{{UnitNumber: "AMB70", DispatchDateTime: "11/13/2022 14:01:00", ClearDateTime: "11/13/2022 14:31:00"}}
+		}
+	default:
+		out = []any{}
+	}
+	json.NewEncoder(w).Encode(out)
+}
+
+func Test_BuildDigest(t *testing.T) {
+	f := &digestCAD{requests: map[string]int{}}
+	cad := httptest.NewServer(f)
+	defer cad.Close()
+	a := &agent.Agent{BaseUrl: cad.URL + "/", FDID: "04040"}
+	a.SetAuth(agent.OidcObj{TokenType: "Bearer", AccessToken: "test"})
+
+	from := time.Date(2022, 11, 13, 0, 0, 0, 0, time.Local)
+	report, err := BuildDigest(a, from, from.Add(24*time.Hour), nil, 2)
+	if err != nil {
+		t.Fatalf("ERR: BuildDigest: %s", err.Error())
+	}
+
+	if report.Total != 3 || len(report.ByORI) != 1 || report.ByORI[0] != (Count{Name: "Pomfret (04040)", Count: 3}) {
+		t.Fatalf("unexpected totals %#v", report)
+	}
+	if len(report.ByCallType) != 2 || report.ByCallType[0] != (Count{Name: "Sick Person", Count: 2}) {
+		t.Fatalf("unexpected call types %#v", report.ByCallType)
+	}
+	if len(report.Longest) != 2 || report.Longest[0].Call.CallID != 1 || report.Longest[0].Duration != 151*time.Minute {
+		t.Fatalf("unexpected longest calls %#v", report.Longest)
+	}
+	if len(report.TopUnits) != 2 || report.TopUnits[0] != (UnitCommitment{Unit: "ENG70", Calls: 2, Committed: 3 * time.Hour}) || report.TopUnits[1].Unit != "TANKER70" {
+		t.Fatalf("unexpected units %#v", report.TopUnits)
+	}
+
+	// Each call is retrieved once
+	if f.requests["GetCall"] != 3 || f.requests["GetCallUnits"] != 3 || f.requests["SearchClearedCalls"] != 1 {
+		t.Fatalf("unexpected upstream requests %v", f.requests)
+	}
+}
+
+func Test_DigestJob_NextRun(t *testing.T) {
+	ny, err := time.LoadLocation("America/New_York")
+	if err != nil {
+		t.Skipf("no time zone database: %s", err.Error())
+	}
+	for _, tc := range []struct {
+		name string
+		at   string
+		now  time.Time
+		want time.Time
+	}{
+		{"later today", "06:00", time.Date(2022, 11, 13, 5, 0, 0, 0, ny), time.Date(2022, 11, 13, 6, 0, 0, 0, ny)},
+		{"already passed", "06:00", time.Date(2022, 11, 13, 6, 0, 0, 0, ny), time.Date(2022, 11, 14, 6, 0, 0, 0, ny)},
+		{"default midnight", "", time.Date(2022, 11, 13, 23, 0, 0, 0, ny), time.Date(2022, 11, 14, 0, 0, 0, 0, ny)},
+		{"spring forward", "06:00", time.Date(2022, 3, 13, 0, 0, 0, 0, ny), time.Date(2022, 3, 13, 6, 0, 0, 0, ny)},
+		{"fall back, passed", "06:00", time.Date(2022, 11, 5, 7, 0, 0, 0, ny), time.Date(2022, 11, 6, 6, 0, 0, 0, ny)},
+	} {
+		d := &DigestJob{At: tc.at}
+		got, err := d.NextRun(tc.now)
+		if err != nil {
+			t.Fatalf("%s: ERR: %s", tc.name, err.Error())
+		}
+		if !got.Equal(tc.want) || got.Hour() != tc.want.Hour() {
+			t.Errorf("%s: expected %s, got %s", tc.name, tc.want, got)
+		}
+	}
+
+	// 25 hours pass across the end of daylight saving time
+	d := &DigestJob{At: "06:00"}
+	got, _ := d.NextRun(time.Date(2022, 11, 5, 6, 0, 0, 0, ny))
+	if got.Sub(time.Date(2022, 11, 5, 6, 0, 0, 0, ny)) != 25*time.Hour {
+		t.Errorf("expected 25 hours until %s", got)
+	}
+
+	if _, err := (&DigestJob{At: "6am"}).NextRun(time.Now()); err == nil {
+		t.Errorf("expected an error for an invalid time")
+	}
+}
+
+func Test_DigestReport_Render(t *testing.T) {
+	from := time.Date(2022, 11, 13, 6, 0, 0, 0, time.UTC)
+	report := DigestReport{
+		From:       from,
+		To:         from.Add(24 * time.Hour),
+		Total:      1,
+		ByORI:      []Count{{Name: "Pomfret (04040)", Count: 1}},
+		ByCallType: []Count{{Name: "Structure Fire", Count: 1}},
+		Longest:    []CallDuration{{Call: agent.CallObj{IncidentNumber: "2022-00000345", CallType: "Structure Fire", Location: "<Rear> 120 FREEDLEY RD"}, Duration: 151 * time.Minute}},
+		TopUnits:   []UnitCommitment{{Unit: "ENG70", Calls: 1, Committed: 90 * time.Minute}},
+	}
+	text, html, err := report.Render()
+	if err != nil {
+		t.Fatalf("ERR: Render: %s", err.Error())
+	}
+	for _, want := range []string{
+		"CAD digest 11/13/2022 06:00 - 11/14/2022 06:00",
+		"Total calls: 1",
+		"Pomfret (04040): 1",
+		"2022-00000345 Structure Fire, <Rear> 120 FREEDLEY RD: 2h31m",
+		"ENG70: 1 calls, 1h30m",
+	} {
+		if !strings.Contains(text, want) {
+			t.Errorf("text missing %q:\n%s", want, text)
+		}
+	}
+	for _, want := range []string{
+		"<td>&lt;Rear&gt; 120 FREEDLEY RD</td>",
+		`<td align="right">2h31m</td>`,
+		"<td>ENG70</td>",
+	} {
+		if !strings.Contains(html, want) {
+			t.Errorf("html missing %q:\n%s", want, html)
+		}
+	}
+}
@@ -0,0 +1,88 @@
+package notify
+
+import (
+	"bytes"
+	htmltemplate "html/template"
+	"strings"
+	texttemplate "text/template"
+
+	"github.com/dayvillefire/newworld-cadview-agent/agent"
+)
+
+// Email sends an immediate alert for new calls matching the configured call
+// types or priorities, and for every alerting rule match routed to it.
+type Email struct {
+	SMTP SMTP
+	// CallTypes limits alerts to these call types, compared without regard
+	// to case against CallType and FireCallType. Empty matches all.
+	CallTypes []string
+	// Priorities limits alerts to these call priorities. Empty matches all.
+	Priorities []string
+	// SubjectPrefix is prepended to the subject, like "[CAD] ".
+	SubjectPrefix string
+	// MapURL is the map link format. See MapLink.
+	MapURL string
+	// AllEvents sends every event handed to Notify rather than only new
+	// calls, which is useful when Email is the target of other filters.
+	AllEvents bool
+}
+
+var emailText = texttemplate.Must(texttemplate.New("text").Parse(`{{.Title}}
+{{if .Text}}
+{{.Text}}
+{{end}}
+{{range .Fields}}{{.Name}}: {{.Value}}
+{{end}}{{if .MapLink}}
+Map: {{.MapLink}}
+{{end}}`))
+
+var emailHTML = htmltemplate.Must(htmltemplate.New("html").Parse(`<html><body style="font-family: sans-serif">
+<h2>{{.Title}}</h2>
+{{if .Text}}<p>{{.Text}}</p>{{end}}
+{{if .Fields}}<table cellpadding="4">
+{{range .Fields}}<tr><th align="left">{{.Name}}</th><td>{{.Value}}</td></tr>
+{{end}}</table>{{end}}
+{{if .MapLink}}<p><a href="{{.MapLink}}">Map</a></p>{{end}}
+</body></html>`))
+
+// Notify implements Notifier.
+func (e *Email) Notify(ev agent.Event) error {
+	if !e.AllEvents && ev.Type != agent.EventNewCall && ev.Type != agent.EventAlert {
+		return nil
+	}
+	if ev.Type != agent.EventAlert && !e.matches(ev.Call) {
+		return nil
+	}
+	msg := BuildMessage(ev, e.MapURL)
+
+	var text, html bytes.Buffer
+	if err := emailText.Execute(&text, msg); err != nil {
+		return err
+	}
+	if err := emailHTML.Execute(&html, msg); err != nil {
+		return err
+	}
+	return e.SMTP.Send(e.SubjectPrefix+msg.Title, text.String(), html.String())
+}
+
+func (e *Email) matches(c agent.CallObj) bool {
+	if len(e.CallTypes) > 0 && !containsFold(e.CallTypes, c.CallType) && !containsFold(e.CallTypes, c.FireCallType) {
+		return false
+	}
+	if len(e.Priorities) > 0 && !containsFold(e.Priorities, c.CallPriority) {
+		return false
+	}
+	return true
+}
+
+func containsFold(list []string, s string) bool {
+	if s == "" {
+		return false
+	}
+	for _, v := range list {
+		if strings.EqualFold(strings.TrimSpace(v), strings.TrimSpace(s)) {
+			return true
+		}
+	}
+	return false
+}
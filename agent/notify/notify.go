@@ -0,0 +1,232 @@
+// Package notify delivers watcher events from the agent to chat systems
+// and other destinations.
+package notify
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/dayvillefire/newworld-cadview-agent/agent"
+)
+
+const (
+	// DefaultMapURL is a printf style format used to build map links from a
+	// latitude and longitude.
+	DefaultMapURL = "https://www.google.com/maps/search/?api=1&query=%f,%f"
+)
+
+// Notifier delivers a single watcher event.
+type Notifier interface {
+	Notify(ev agent.Event) error
+}
+
+// Multi sends each event to every Notifier in the list, returning the last
+// error encountered.
+type Multi []Notifier
+
+func (m Multi) Notify(ev agent.Event) error {
+	var err error
+	for _, n := range m {
+		if nerr := n.Notify(ev); nerr != nil {
+			err = nerr
+		}
+	}
+	return err
+}
+
+// Field is a single labeled value within a Message.
+type Field struct {
+	Name  string
+	Value string
+}
+
+// Message is the destination independent rendering of an event.
+type Message struct {
+	// Title is the headline, such as "Structure Fire - 120 FREEDLEY RD".
+	Title string
+	// Text is the body of the message, if any.
+	Text string
+	// Fields hold the call details for a new call.
+	Fields []Field
+	// MapLink is a link to the call location, if the call has coordinates.
+	MapLink string
+	// FollowUp is set when the message describes a change to a call which
+	// has already been announced.
+	FollowUp bool
+}
+
+// Filter determines which events are sent to a destination. The zero
+// value sends new calls, alerts, closed calls, and units going enroute or
+// on scene.
+type Filter struct {
+	// Statuses is the list of unit statuses which are announced. Defaults to
+	// agent.UnitStatusEnroute and agent.UnitStatusOnScene.
+	Statuses []string
+	// SkipClosed suppresses closed call messages.
+	SkipClosed bool
+}
+
+// Wants determines whether an event should be announced.
+func (f Filter) Wants(ev agent.Event) bool {
+	switch ev.Type {
+	case agent.EventNewCall, agent.EventAlert:
+		return true
+	case agent.EventCallClosed:
+		return !f.SkipClosed
+	case agent.EventUnitStatus:
+		statuses := f.Statuses
+		if len(statuses) == 0 {
+			statuses = []string{agent.UnitStatusEnroute, agent.UnitStatusOnScene}
+		}
+		for _, s := range statuses {
+			if strings.EqualFold(s, ev.Status) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// MapLink builds a map link for a call using a printf style format which
+// takes the latitude and longitude. An empty format uses DefaultMapURL. An
+// empty string is returned if the call has no coordinates.
+func MapLink(format string, call agent.CallObj) string {
+	if call.LatitudeY == 0 && call.LongitudeX == 0 {
+		return ""
+	}
+	if format == "" {
+		format = DefaultMapURL
+	}
+	return fmt.Sprintf(format, call.LatitudeY, call.LongitudeX)
+}
+
+// BuildMessage renders an event as a Message.
+func BuildMessage(ev agent.Event, mapFormat string) Message {
+	c := ev.Call
+	msg := Message{MapLink: MapLink(mapFormat, c)}
+
+	switch ev.Type {
+	case agent.EventNewCall:
+		msg.Title = fmt.Sprintf("%s - %s", callType(c), c.Location)
+		msg.Text = strings.TrimSpace(c.NatureOfCall)
+		msg.Fields = append(msg.Fields,
+			Field{Name: "Call Type", Value: callType(c)},
+			Field{Name: "Location", Value: c.Location},
+		)
+		if c.CommonName != "" {
+			msg.Fields = append(msg.Fields, Field{Name: "Common Name", Value: c.CommonName})
+		}
+		if c.NatureOfCall != "" {
+			msg.Fields = append(msg.Fields, Field{Name: "Nature of Call", Value: strings.TrimSpace(c.NatureOfCall)})
+		}
+		if c.CallPriority != "" {
+			msg.Fields = append(msg.Fields, Field{Name: "Priority", Value: c.CallPriority})
+		}
+		if c.IncidentNumber != "" {
+			msg.Fields = append(msg.Fields, Field{Name: "Incident", Value: c.IncidentNumber})
+		}
+		if units := unitList(ev); units != "" {
+			msg.Fields = append(msg.Fields, Field{Name: "Units", Value: units})
+		}
+	case agent.EventUnitStatus:
+		msg.FollowUp = true
+		unit := ""
+		if ev.Unit != nil {
+			unit = ev.Unit.UnitNumber
+		}
+		msg.Title = fmt.Sprintf("%s %s", unit, strings.ToLower(statusText(ev.Status)))
+		msg.Text = fmt.Sprintf("%s - %s", callType(c), c.Location)
+	case agent.EventCallClosed:
+		msg.FollowUp = true
+		msg.Title = fmt.Sprintf("Call closed: %s - %s", callType(c), c.Location)
+	case agent.EventAlert:
+		msg.Title = fmt.Sprintf("%s - %s", callType(c), c.Location)
+		if ev.Alert != nil {
+			msg.Title = fmt.Sprintf("ALERT %s: %s", ev.Alert.Rule, msg.Title)
+			if ev.Alert.Match != "" {
+				msg.Text = fmt.Sprintf("Matched %q in %s", ev.Alert.Match, ev.Alert.Field)
+			}
+		}
+		msg.Fields = append(msg.Fields, Field{Name: "Location", Value: c.Location})
+		if c.IncidentNumber != "" {
+			msg.Fields = append(msg.Fields, Field{Name: "Incident", Value: c.IncidentNumber})
+		}
+	case agent.EventNarrative:
+		msg.FollowUp = true
+		msg.Title = fmt.Sprintf("Narrative: %s - %s", callType(c), c.Location)
+		if ev.Narrative != nil {
+			msg.Text = ev.Narrative.Narrative
+		}
+	default:
+		msg.FollowUp = true
+		msg.Title = fmt.Sprintf("Call updated: %s - %s", callType(c), c.Location)
+		msg.Text = strings.TrimSpace(c.NatureOfCall)
+	}
+
+	return msg
+}
+
+func callType(c agent.CallObj) string {
+	if c.FireCallType != "" {
+		return c.FireCallType
+	}
+	return c.CallType
+}
+
+func statusText(status string) string {
+	switch status {
+	case agent.UnitStatusEnroute:
+		return "En route"
+	case agent.UnitStatusOnScene:
+		return "On scene"
+	}
+	return status
+}
+
+func unitList(ev agent.Event) string {
+	units := []string{}
+	for _, u := range ev.Units {
+		units = append(units, u.UnitNumber)
+	}
+	if len(units) == 0 && ev.Call.PrimaryUnit != "" {
+		units = append(units, ev.Call.PrimaryUnit)
+	}
+	return strings.Join(units, ", ")
+}
+
+// postJSON sends a JSON payload to a URL, returning the response body.
+func postJSON(client *http.Client, url string, header http.Header, payload any) ([]byte, error) {
+	if client == nil {
+		client = &http.Client{Timeout: 30 * time.Second}
+	}
+	data, err := json.Marshal(payload)
+	if err != nil {
+		return []byte{}, err
+	}
+	req, err := http.NewRequest("POST", url, bytes.NewReader(data))
+	if err != nil {
+		return []byte{}, err
+	}
+	req.Header.Set("Content-Type", "application/json; charset=utf-8")
+	for k, v := range header {
+		req.Header[k] = v
+	}
+	res, err := client.Do(req)
+	if err != nil {
+		return []byte{}, err
+	}
+	defer res.Body.Close()
+	body, err := io.ReadAll(res.Body)
+	if err != nil {
+		return body, err
+	}
+	if res.StatusCode < 200 || res.StatusCode > 299 {
+		return body, fmt.Errorf("%s: %s: %s", url, res.Status, strings.TrimSpace(string(body)))
+	}
+	return body, nil
+}
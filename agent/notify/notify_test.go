@@ -0,0 +1,210 @@
+package notify
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/dayvillefire/newworld-cadview-agent/agent"
+)
+
+func testCall() agent.CallObj {
+	return agent.CallObj{
+		CallID:         591039,
+		CallType:       "Structure Fire",
+		IncidentNumber: "2022-00000345",
+		Location:       "120 FREEDLEY RD, Pomfret",
+		NatureOfCall:   "/SMOKE SHOWING/ ",
+		LatitudeY:      41.902630,
+		LongitudeX:     -71.946741,
+	}
+}
+
+func Test_BuildMessage(t *testing.T) {
+	msg := BuildMessage(agent.Event{
+		Type:  agent.EventNewCall,
+		Call:  testCall(),
+		Units: []agent.UnitObj{{UnitNumber: "ENG70"}, {UnitNumber: "TANKER70"}},
+	}, "")
+	if msg.Title != "Structure Fire - 120 FREEDLEY RD, Pomfret" {
+		t.Fatalf("unexpected title %q", msg.Title)
+	}
+	if !strings.Contains(msg.MapLink, "41.902630,-71.946741") {
+		t.Fatalf("unexpected map link %q", msg.MapLink)
+	}
+	found := false
+	for _, f := range msg.Fields {
+		if f.Name == "Units" && f.Value == "ENG70, TANKER70" {
+			found = true
+		}
+	}
+	if !found {
+		t.Fatalf("units missing from %#v", msg.Fields)
+	}
+}
+
+func Test_Slack_Thread(t *testing.T) {
+	var l sync.Mutex
+	posted := []slackMessage{}
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "Bearer xoxb-test" {
+			t.Errorf("missing authorization header")
+		}
+		var m slackMessage
+		json.NewDecoder(r.Body).Decode(&m)
+		l.Lock()
+		posted = append(posted, m)
+		l.Unlock()
+		w.Write([]byte(`{"ok":true,"ts":"1668350754.000100"}`))
+	}))
+	defer srv.Close()
+
+	s := &Slack{Token: "xoxb-test", Channel: "C123", APIURL: srv.URL + "/"}
+	call := testCall()
+	if err := s.Notify(agent.Event{Type: agent.EventNewCall, Call: call}); err != nil {
+		t.Fatalf("ERR: Notify: %s", err.Error())
+	}
+	unit := agent.UnitObj{UnitNumber: "ENG70"}
+	if err := s.Notify(agent.Event{Type: agent.EventUnitStatus, Call: call, Unit: &unit, Status: agent.UnitStatusEnroute}); err != nil {
+		t.Fatalf("ERR: Notify: %s", err.Error())
+	}
+	// Not in the default filter
+	if err := s.Notify(agent.Event{Type: agent.EventUnitStatus, Call: call, Unit: &unit, Status: agent.UnitStatusCleared}); err != nil {
+		t.Fatalf("ERR: Notify: %s", err.Error())
+	}
+
+	if len(posted) != 2 {
+		t.Fatalf("expected 2 messages, got %d", len(posted))
+	}
+	if posted[0].ThreadTS != "" || posted[1].ThreadTS != "1668350754.000100" {
+		t.Fatalf("follow-up was not threaded: %#v", posted)
+	}
+}
+
+func Test_Slack_Escape(t *testing.T) {
+	call := testCall()
+	call.Location = "<REAR> 120 FREEDLEY RD & ROUTE 44"
+	call.NatureOfCall = "SMOKE > 2 FLOORS"
+
+	payload := slackPayload(BuildMessage(agent.Event{Type: agent.EventNewCall, Call: call}, ""))
+	if payload.Blocks[0].Text.Text != "Structure Fire - <REAR> 120 FREEDLEY RD & ROUTE 44" {
+		t.Errorf("plain text header was escaped: %q", payload.Blocks[0].Text.Text)
+	}
+	fields := []string{}
+	for _, f := range payload.Blocks[1].Fields {
+		fields = append(fields, f.Text)
+	}
+	for _, want := range []string{"*Location*\n&lt;REAR&gt; 120 FREEDLEY RD &amp; ROUTE 44", "*Nature of Call*\nSMOKE &gt; 2 FLOORS"} {
+		if !strings.Contains(strings.Join(fields, "|"), want) {
+			t.Errorf("expected field %q in %q", want, fields)
+		}
+	}
+	if payload.Blocks[2].Text.Text != "<https://www.google.com/maps/search/?api=1&query=41.902630,-71.946741|Map>" {
+		t.Errorf("map link was escaped: %q", payload.Blocks[2].Text.Text)
+	}
+
+	narrative := agent.NarrativeObj{Narrative: "<b>CALLER</b> & WIFE OUT"}
+	payload = slackPayload(BuildMessage(agent.Event{Type: agent.EventNarrative, Call: call, Narrative: &narrative}, ""))
+	want := "Narrative: Structure Fire - &lt;REAR&gt; 120 FREEDLEY RD &amp; ROUTE 44\n&lt;b&gt;CALLER&lt;/b&gt; &amp; WIFE OUT"
+	if payload.Blocks[0].Text.Text != want || !strings.HasPrefix(payload.Text, "Narrative: Structure Fire - &lt;REAR&gt;") {
+		t.Errorf("unexpected follow-up %#v", payload)
+	}
+}
+
+func Test_Teams_Webhook(t *testing.T) {
+	var card teamsCard
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		json.NewDecoder(r.Body).Decode(&card)
+		w.Write([]byte("1"))
+	}))
+	defer srv.Close()
+
+	tm := &Teams{WebhookURL: srv.URL}
+	if err := tm.Notify(agent.Event{Type: agent.EventNewCall, Call: testCall()}); err != nil {
+		t.Fatalf("ERR: Notify: %s", err.Error())
+	}
+	if card.Type != "MessageCard" || len(card.PotentialAction) != 1 {
+		t.Fatalf("unexpected card %#v", card)
+	}
+}
+
+// smtpCatcher is a minimal local SMTP server which records the DATA of
+// each message it receives.
+func smtpCatcher(t *testing.T) (string, int, <-chan string) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("ERR: Listen: %s", err.Error())
+	}
+	t.Cleanup(func() { ln.Close() })
+	out := make(chan string, 1)
+	go func() {
+		conn, err := ln.Accept()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		r := bufio.NewReader(conn)
+		fmt.Fprintf(conn, "220 localhost ESMTP\r\n")
+		for {
+			line, err := r.ReadString('\n')
+			if err != nil {
+				return
+			}
+			cmd := strings.ToUpper(strings.TrimSpace(line))
+			switch {
+			case strings.HasPrefix(cmd, "EHLO"), strings.HasPrefix(cmd, "HELO"):
+				fmt.Fprintf(conn, "250 localhost\r\n")
+			case strings.HasPrefix(cmd, "DATA"):
+				fmt.Fprintf(conn, "354 go ahead\r\n")
+				var data strings.Builder
+				for {
+					l, err := r.ReadString('\n')
+					if err != nil || l == ".\r\n" {
+						break
+					}
+					data.WriteString(l)
+				}
+				out <- data.String()
+				fmt.Fprintf(conn, "250 ok\r\n")
+			case strings.HasPrefix(cmd, "QUIT"):
+				fmt.Fprintf(conn, "221 bye\r\n")
+				return
+			default:
+				fmt.Fprintf(conn, "250 ok\r\n")
+			}
+		}
+	}()
+	addr := ln.Addr().(*net.TCPAddr)
+	return addr.IP.String(), addr.Port, out
+}
+
+func Test_Email_Notify(t *testing.T) {
+	host, port, msgs := smtpCatcher(t)
+	e := &Email{
+		SMTP:      SMTP{Host: host, Port: port, From: "cad@example.org", To: []string{"chief@example.org"}},
+		CallTypes: []string{"structure fire"},
+	}
+
+	// Filtered out by call type
+	other := testCall()
+	other.CallType = "Sick Person"
+	if err := e.Notify(agent.Event{Type: agent.EventNewCall, Call: other}); err != nil {
+		t.Fatalf("ERR: Notify: %s", err.Error())
+	}
+
+	if err := e.Notify(agent.Event{Type: agent.EventNewCall, Call: testCall()}); err != nil {
+		t.Fatalf("ERR: Notify: %s", err.Error())
+	}
+	data := <-msgs
+	for _, want := range []string{"multipart/alternative", "text/plain", "text/html", "Structure Fire"} {
+		if !strings.Contains(data, want) {
+			t.Fatalf("message missing %q:\n%s", want, data)
+		}
+	}
+}
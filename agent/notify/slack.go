@@ -0,0 +1,152 @@
+package notify
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"sync"
+
+	"github.com/dayvillefire/newworld-cadview-agent/agent"
+)
+
+// Slack posts events to Slack. If Token and Channel are set, messages are
+// sent with chat.postMessage and follow-ups are threaded under the original
+// call announcement; otherwise they are posted to the incoming WebhookURL.
+// Alerts are always posted to the channel rather than a thread.
+type Slack struct {
+	// WebhookURL is the incoming webhook URL, like
+	// "https://hooks.slack.com/services/...".
+	WebhookURL string
+	// Token is a bot token with chat:write, used instead of WebhookURL.
+	Token string
+	// Channel is the channel ID to post to when Token is set.
+	Channel string
+	// APIURL overrides the Slack API base URL, with a trailing slash.
+	// Defaults to "https://slack.com/api/".
+	APIURL string
+	// MapURL is the map link format. See MapLink.
+	MapURL string
+	// Filter selects the events which are posted.
+	Filter Filter
+	// Client is the HTTP client to use, if not the default.
+	Client *http.Client
+
+	threads map[int64]string
+	l       sync.Mutex
+}
+
+type slackText struct {
+	Type string `json:"type"`
+	Text string `json:"text"`
+}
+
+type slackBlock struct {
+	Type   string      `json:"type"`
+	Text   *slackText  `json:"text,omitempty"`
+	Fields []slackText `json:"fields,omitempty"`
+}
+
+type slackMessage struct {
+	Channel  string       `json:"channel,omitempty"`
+	ThreadTS string       `json:"thread_ts,omitempty"`
+	Text     string       `json:"text"`
+	Blocks   []slackBlock `json:"blocks,omitempty"`
+}
+
+// Notify implements Notifier.
+func (s *Slack) Notify(ev agent.Event) error {
+	if !s.Filter.Wants(ev) {
+		return nil
+	}
+	payload := slackPayload(BuildMessage(ev, s.MapURL))
+
+	if s.Token == "" {
+		if s.WebhookURL == "" {
+			return fmt.Errorf("slack: no webhook url or token")
+		}
+		_, err := postJSON(s.Client, s.WebhookURL, nil, payload)
+		return err
+	}
+
+	s.l.Lock()
+	if s.threads == nil {
+		s.threads = map[int64]string{}
+	}
+	thread := s.threads[ev.Call.CallID]
+	s.l.Unlock()
+
+	payload.Channel = s.Channel
+	if ev.Type != agent.EventNewCall && ev.Type != agent.EventAlert {
+		payload.ThreadTS = thread
+	}
+
+	apiURL := s.APIURL
+	if apiURL == "" {
+		apiURL = "https://slack.com/api/"
+	}
+	body, err := postJSON(s.Client, apiURL+"chat.postMessage", http.Header{
+		"Authorization": []string{"Bearer " + s.Token},
+	}, payload)
+	if err != nil {
+		return err
+	}
+
+	var res struct {
+		OK    bool   `json:"ok"`
+		Error string `json:"error"`
+		TS    string `json:"ts"`
+	}
+	if err = json.Unmarshal(body, &res); err != nil {
+		return err
+	}
+	if !res.OK {
+		return fmt.Errorf("slack: chat.postMessage: %s", res.Error)
+	}
+
+	s.l.Lock()
+	switch ev.Type {
+	case agent.EventNewCall:
+		s.threads[ev.Call.CallID] = res.TS
+	case agent.EventCallClosed:
+		delete(s.threads, ev.Call.CallID)
+	}
+	s.l.Unlock()
+
+	return nil
+}
+
+// slackEscape escapes the characters Slack treats as control characters in
+// mrkdwn, so that CAD text like "<REAR> 120 FREEDLEY RD" is shown as is.
+var slackEscape = strings.NewReplacer("&", "&amp;", "<", "&lt;", ">", "&gt;").Replace
+
+func slackPayload(msg Message) slackMessage {
+	out := slackMessage{Text: slackEscape(msg.Title)}
+	if msg.FollowUp {
+		text := slackEscape(msg.Title)
+		if msg.Text != "" {
+			text += "\n" + slackEscape(msg.Text)
+		}
+		out.Blocks = []slackBlock{{Type: "section", Text: &slackText{Type: "mrkdwn", Text: text}}}
+		return out
+	}
+
+	out.Blocks = append(out.Blocks, slackBlock{
+		Type: "header",
+		Text: &slackText{Type: "plain_text", Text: msg.Title},
+	})
+	if len(msg.Fields) > 0 {
+		b := slackBlock{Type: "section"}
+		for _, f := range msg.Fields {
+			b.Fields = append(b.Fields, slackText{Type: "mrkdwn", Text: fmt.Sprintf("*%s*\n%s", slackEscape(f.Name), slackEscape(f.Value))})
+		}
+		out.Blocks = append(out.Blocks, b)
+	}
+	if msg.MapLink != "" {
+		out.Blocks = append(out.Blocks, slackBlock{
+			Type: "section",
+			Text: &slackText{Type: "mrkdwn", Text: fmt.Sprintf("<%s|Map>", msg.MapLink)},
+		})
+	}
+	return out
+}
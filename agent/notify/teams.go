@@ -0,0 +1,91 @@
+package notify
+
+import (
+	"fmt"
+	"net/http"
+
+	"github.com/dayvillefire/newworld-cadview-agent/agent"
+)
+
+// Teams posts events to a Microsoft Teams (or compatible) incoming webhook
+// as MessageCards. Incoming webhooks cannot thread, so follow-ups are sent
+// as compact cards referencing the incident.
+type Teams struct {
+	// WebhookURL is the incoming webhook URL.
+	WebhookURL string
+	// MapURL is the map link format. See MapLink.
+	MapURL string
+	// ThemeColor is the card accent color, like "D70000".
+	ThemeColor string
+	// Filter selects the events which are posted.
+	Filter Filter
+	// Client is the HTTP client to use, if not the default.
+	Client *http.Client
+}
+
+type teamsFact struct {
+	Name  string `json:"name"`
+	Value string `json:"value"`
+}
+
+type teamsSection struct {
+	ActivityTitle string      `json:"activityTitle,omitempty"`
+	Text          string      `json:"text,omitempty"`
+	Facts         []teamsFact `json:"facts,omitempty"`
+}
+
+type teamsTarget struct {
+	OS  string `json:"os"`
+	URI string `json:"uri"`
+}
+
+type teamsAction struct {
+	Type    string        `json:"@type"`
+	Name    string        `json:"name"`
+	Targets []teamsTarget `json:"targets"`
+}
+
+type teamsCard struct {
+	Type            string         `json:"@type"`
+	Context         string         `json:"@context"`
+	Summary         string         `json:"summary"`
+	Title           string         `json:"title"`
+	ThemeColor      string         `json:"themeColor,omitempty"`
+	Sections        []teamsSection `json:"sections,omitempty"`
+	PotentialAction []teamsAction  `json:"potentialAction,omitempty"`
+}
+
+// Notify implements Notifier.
+func (t *Teams) Notify(ev agent.Event) error {
+	if !t.Filter.Wants(ev) {
+		return nil
+	}
+	if t.WebhookURL == "" {
+		return fmt.Errorf("teams: no webhook url")
+	}
+	msg := BuildMessage(ev, t.MapURL)
+	card := teamsCard{
+		Type:       "MessageCard",
+		Context:    "https://schema.org/extensions",
+		Summary:    msg.Title,
+		Title:      msg.Title,
+		ThemeColor: t.ThemeColor,
+	}
+	section := teamsSection{Text: msg.Text}
+	if msg.FollowUp && ev.Call.IncidentNumber != "" {
+		section.ActivityTitle = "Incident " + ev.Call.IncidentNumber
+	}
+	for _, f := range msg.Fields {
+		section.Facts = append(section.Facts, teamsFact{Name: f.Name, Value: f.Value})
+	}
+	card.Sections = []teamsSection{section}
+	if msg.MapLink != "" && !msg.FollowUp {
+		card.PotentialAction = []teamsAction{{
+			Type:    "OpenUri",
+			Name:    "Map",
+			Targets: []teamsTarget{{OS: "default", URI: msg.MapLink}},
+		}}
+	}
+	_, err := postJSON(t.Client, t.WebhookURL, nil, card)
+	return err
+}
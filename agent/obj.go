@@ -37,10 +37,25 @@ type CallObj struct {
 	PrimaryUnit        string   `json:"primaryUnit"`         // "STA70"
 	Quadrant           string   `json:"quadrant"`            // "POMFRET B"
 	AllowedORI         []string `json:"allowedOri" gorm:"-"` // ["04040-561","04090"]
-	// "foregroundB":68,"foregroundG":68,"foregroundR":68
 	// "district":null,"emsCallType":null,"emsCallTypeId":null
 	// policeCallType":null,"policeCallTypeId":null,
 	// "station":null,"agencyTypes":"Fire","isPendingPolice":false,"isPendingFire":false,"isPendingEms":false
+
+	// Foreground color used by CadView to display the call, which reflects
+	// its priority.
+	ForegroundR int `json:"foregroundR"` // 68
+	ForegroundG int `json:"foregroundG"` // 68
+	ForegroundB int `json:"foregroundB"` // 68
+
+	// Zones are the names of local geofence zones containing the call. They
+	// are not provided by the CAD system; see the geo package.
+	Zones []string `json:"zones,omitempty" gorm:"-"`
+}
+
+// Tagger annotates calls as they are retrieved, such as by setting the
+// zones which contain them. See Agent.Tagger.
+type Tagger interface {
+	Tag(calls []CallObj)
 }
 
 type CallLogObj struct {
@@ -0,0 +1,34 @@
+package agent
+
+import (
+	"net/url"
+	"strings"
+	"time"
+)
+
+// Observer receives notifications about the activity of an Agent, for
+// monitoring. All methods must be safe for concurrent use.
+type Observer interface {
+	// ObserveRequest is called after every CadView API request with the
+	// endpoint, like "api/Call/GetActiveCalls", the HTTP status code, or 0
+	// if the request failed, and the time taken.
+	ObserveRequest(endpoint string, status int, d time.Duration)
+	// ObserveLogin is called after every login attempt by Init.
+	ObserveLogin(err error)
+	// ObservePing is called with the result of every Ping made by Run.
+	ObservePing(err error)
+}
+
+// endpointName returns the path of a CadView API URL relative to the
+// application, without the query string.
+func endpointName(u string) string {
+	p, err := url.Parse(u)
+	if err != nil {
+		return "unknown"
+	}
+	path := p.Path
+	if i := strings.Index(strings.ToLower(path), "/newworld.cadview/"); i >= 0 {
+		path = path[i+len("/newworld.cadview/"):]
+	}
+	return strings.TrimPrefix(path, "/")
+}
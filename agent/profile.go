@@ -0,0 +1,97 @@
+package agent
+
+import (
+	"strings"
+	"sync"
+)
+
+// LoginProfile describes the login page of a CadView release or skin.
+// Selectors may be XPath expressions or CSS selectors.
+type LoginProfile struct {
+	// LoginPath is the path of the login page, relative to BaseUrl.
+	LoginPath string
+	// UsernameSelector, PasswordSelector and SubmitSelector match the login
+	// form fields and its submit button.
+	UsernameSelector string
+	PasswordSelector string
+	SubmitSelector   string
+	// MFASelector matches the code input of a second factor challenge.
+	MFASelector string
+	// SuccessSelector matches an element which is visible once the login
+	// has succeeded.
+	SuccessSelector string
+	// SuccessURL, if set, also indicates success when the page address
+	// contains it.
+	SuccessURL string
+	// StorageKeyPrefix is the prefix of the localStorage key which holds the
+	// OIDC user and token.
+	StorageKeyPrefix string
+}
+
+// DefaultLoginProfile matches the NewWorld CadView login page.
+var DefaultLoginProfile = LoginProfile{
+	LoginPath:        "newworld.cadview/account/login",
+	UsernameSelector: "//input[@id='Username']",
+	PasswordSelector: "//input[@id='passwordField']",
+	SubmitSelector:   "//button[@id='loginbtn']",
+	MFASelector:      "input[autocomplete='one-time-code'], input[name='TwoFactorCode'], input[name='Code'], input#Code, input[name='VerificationCode']",
+	SuccessSelector:  "//*[contains(., 'Dashboard')]",
+	StorageKeyPrefix: "oidc.user:",
+}
+
+var (
+	loginProfiles  = map[string]LoginProfile{}
+	loginProfilesL sync.Mutex
+)
+
+// RegisterLoginProfile sets the login profile used for agents with a
+// BaseUrl, unless they have their own. Empty fields are taken from
+// DefaultLoginProfile.
+func RegisterLoginProfile(baseUrl string, p LoginProfile) {
+	loginProfilesL.Lock()
+	defer loginProfilesL.Unlock()
+	loginProfiles[profileKey(baseUrl)] = p
+}
+
+// LoginProfile returns the login profile used by the agent: its Profile,
+// or the one registered for its BaseUrl, with empty fields taken from
+// DefaultLoginProfile.
+func (a *Agent) LoginProfile() LoginProfile {
+	var p LoginProfile
+	if a.Profile != nil {
+		p = *a.Profile
+	} else {
+		loginProfilesL.Lock()
+		p = loginProfiles[profileKey(a.BaseUrl)]
+		loginProfilesL.Unlock()
+	}
+	return p.withDefaults()
+}
+
+func (p LoginProfile) withDefaults() LoginProfile {
+	d := DefaultLoginProfile
+	fill := func(dst *string, def string) {
+		if *dst == "" {
+			*dst = def
+		}
+	}
+	fill(&p.LoginPath, d.LoginPath)
+	fill(&p.UsernameSelector, d.UsernameSelector)
+	fill(&p.PasswordSelector, d.PasswordSelector)
+	fill(&p.SubmitSelector, d.SubmitSelector)
+	fill(&p.MFASelector, d.MFASelector)
+	fill(&p.SuccessSelector, d.SuccessSelector)
+	fill(&p.StorageKeyPrefix, d.StorageKeyPrefix)
+	p.LoginPath = strings.TrimPrefix(p.LoginPath, "/")
+	return p
+}
+
+func profileKey(baseUrl string) string {
+	return strings.TrimSuffix(strings.ToLower(baseUrl), "/")
+}
+
+// isXPath determines whether a selector is an XPath expression rather than
+// a CSS selector.
+func isXPath(sel string) bool {
+	return strings.HasPrefix(sel, "/") || strings.HasPrefix(sel, "(")
+}
@@ -0,0 +1,234 @@
+package agent
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"slices"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/chromedp/cdproto/browser"
+	"github.com/chromedp/chromedp"
+)
+
+// RemoteSession keeps a connection to a remote devtools instance for the
+// Agents which use it. Each Agent logs in within its own browser context,
+// so that several accounts can share one browser, and its tab is kept open
+// after login. The session watches the tabs, picking up tokens which the
+// CadView app renews by itself, and logs the Agents in again after the
+// remote browser restarts.
+type RemoteSession struct {
+	// CDP is the URL of the remote devtools instance, usually on port
+	// :9222.
+	CDP string
+	// Interval is how often the browser and the stored tokens are checked.
+	// Defaults to 30 seconds.
+	Interval time.Duration
+	Debug    bool
+
+	ctx        context.Context
+	cancel     func()
+	agents     []*Agent
+	reconnects int
+	cancelled  atomic.Bool
+	l          sync.Mutex
+}
+
+// Start connects to the remote browser and starts watching it.
+func (r *RemoteSession) Start() error {
+	if r.CDP == "" {
+		return fmt.Errorf("no remote devtools URL")
+	}
+	if r.Interval <= 0 {
+		r.Interval = 30 * time.Second
+	}
+	if err := r.connect(); err != nil {
+		return err
+	}
+
+	go func() {
+		for {
+			for i := 0; i < max(1, int(r.Interval/time.Second)); i++ {
+				time.Sleep(time.Second)
+				if r.cancelled.Load() {
+					return
+				}
+			}
+			r.check()
+		}
+	}()
+	return nil
+}
+
+// Stop closes the tabs of all Agents and disconnects from the browser.
+func (r *RemoteSession) Stop() {
+	r.cancelled.Store(true)
+	r.l.Lock()
+	agents := r.agents
+	r.agents = nil
+	cancel := r.cancel
+	r.ctx, r.cancel = nil, nil
+	r.l.Unlock()
+	for _, a := range agents {
+		a.Close()
+	}
+	if cancel != nil {
+		cancel()
+	}
+}
+
+// Reconnects is the number of times the session has reconnected to the
+// remote browser.
+func (r *RemoteSession) Reconnects() int {
+	r.l.Lock()
+	defer r.l.Unlock()
+	return r.reconnects
+}
+
+// connect opens the connection to the remote browser, with a tab which is
+// kept open to hold it.
+func (r *RemoteSession) connect() error {
+	log.Printf("INFO: Connecting to remote devtools URL %s", r.CDP)
+	allocCtx, allocCancel := chromedp.NewRemoteAllocator(context.Background(), r.CDP)
+	var ctx context.Context
+	var cancel context.CancelFunc
+	if r.Debug {
+		ctx, cancel = chromedp.NewContext(allocCtx, chromedp.WithDebugf(log.Printf))
+	} else {
+		ctx, cancel = chromedp.NewContext(allocCtx)
+	}
+	if err := chromedp.Run(ctx); err != nil {
+		cancel()
+		allocCancel()
+		return fmt.Errorf("connecting to %s: %w", r.CDP, err)
+	}
+
+	r.l.Lock()
+	old := r.cancel
+	r.ctx = ctx
+	r.cancel = func() {
+		cancel()
+		allocCancel()
+	}
+	r.l.Unlock()
+	if old != nil {
+		old()
+	}
+	return nil
+}
+
+// newTab opens a tab in a new browser context for an Agent, returning its
+// context and a function which closes it.
+func (r *RemoteSession) newTab() (context.Context, func(), error) {
+	r.l.Lock()
+	parent := r.ctx
+	r.l.Unlock()
+	if parent == nil {
+		return nil, nil, fmt.Errorf("remote session not started")
+	}
+	ctx, cancel := chromedp.NewContext(parent, chromedp.WithNewBrowserContext())
+	return ctx, func() { cancel() }, nil
+}
+
+// attach adds an Agent which has logged in within the session.
+func (r *RemoteSession) attach(a *Agent) {
+	r.l.Lock()
+	defer r.l.Unlock()
+	if !slices.Contains(r.agents, a) {
+		r.agents = append(r.agents, a)
+	}
+}
+
+// detach removes an Agent from the session.
+func (r *RemoteSession) detach(a *Agent) {
+	r.l.Lock()
+	defer r.l.Unlock()
+	r.agents = slices.DeleteFunc(r.agents, func(x *Agent) bool { return x == a })
+}
+
+// alive reports whether the remote browser still answers.
+func (r *RemoteSession) alive() bool {
+	r.l.Lock()
+	ctx := r.ctx
+	r.l.Unlock()
+	if ctx == nil || ctx.Err() != nil {
+		return false
+	}
+	tctx, cancel := context.WithTimeout(ctx, 10*time.Second)
+	defer cancel()
+	err := chromedp.Run(tctx, chromedp.ActionFunc(func(ctx context.Context) error {
+		_, _, _, _, _, err := browser.GetVersion().Do(ctx)
+		return err
+	}))
+	if err != nil && r.Debug {
+		log.Printf("DEBUG: RemoteSession: %s", err.Error())
+	}
+	return err == nil
+}
+
+// check reconnects to the browser if it has gone away, and otherwise
+// refreshes the token of each Agent from its tab.
+func (r *RemoteSession) check() {
+	r.l.Lock()
+	agents := slices.Clone(r.agents)
+	r.l.Unlock()
+
+	if !r.alive() {
+		log.Printf("WARN: Lost connection to remote browser %s", r.CDP)
+		if err := r.connect(); err != nil {
+			log.Printf("ERR: %s", err.Error())
+			return
+		}
+		r.l.Lock()
+		r.reconnects++
+		r.l.Unlock()
+		for _, a := range agents {
+			if r.cancelled.Load() {
+				return
+			}
+			// The old tabs went with the browser. A login already in
+			// progress for a failed request is shared rather than repeated.
+			a.Close()
+			if err := a.Reauthorize(); err != nil {
+				log.Printf("ERR: Logging in again to %s: %s", a.BaseUrl, err.Error())
+			}
+		}
+		return
+	}
+
+	for _, a := range agents {
+		if err := a.refreshAuth(); err != nil {
+			log.Printf("ERR: Reading token for %s: %s", a.BaseUrl, err.Error())
+		}
+	}
+}
+
+// refreshAuth reads the token from the tab kept open after login, in case
+// the CadView app has renewed it.
+func (a *Agent) refreshAuth() error {
+	ctx, ok := a.BrowserContext()
+	if !ok {
+		return nil
+	}
+	tctx, cancel := context.WithTimeout(ctx, 10*time.Second)
+	defer cancel()
+
+	var oidc OidcObj
+	var found bool
+	err := chromedp.Run(tctx, chromedp.ActionFunc(func(ctx context.Context) error {
+		var err error
+		oidc, found, err = a.storedAuth(ctx, a.LoginProfile().StorageKeyPrefix)
+		return err
+	}))
+	if err != nil {
+		return err
+	}
+	if !found || oidc.AccessToken == "" || oidc.AccessToken == a.GetAuth().AccessToken {
+		return nil
+	}
+	log.Printf("INFO: Token for %s renewed by CadView, expires at %d", a.BaseUrl, oidc.ExpiresAt)
+	a.SetAuth(oidc)
+	return nil
+}
@@ -0,0 +1,1769 @@
+// Protobuf schema for the cadview agent gRPC service. Messages mirror the
+// JSON objects in obj.go; date and time strings are passed through as the
+// CAD system formats them, like "11/13/2022 10:25:54".
+
+// Code generated by protoc-gen-go. DO NOT EDIT.
+// versions:
+// 	protoc-gen-go v1.36.11
+// 	protoc        v5.29.3
+// source: cadview.proto
+
+package cadviewpb
+
+import (
+	protoreflect "google.golang.org/protobuf/reflect/protoreflect"
+	protoimpl "google.golang.org/protobuf/runtime/protoimpl"
+	timestamppb "google.golang.org/protobuf/types/known/timestamppb"
+	reflect "reflect"
+	sync "sync"
+	unsafe "unsafe"
+)
+
+const (
+	// Verify that this generated code is sufficiently up-to-date.
+	_ = protoimpl.EnforceVersion(20 - protoimpl.MinVersion)
+	// Verify that runtime/protoimpl is sufficiently up-to-date.
+	_ = protoimpl.EnforceVersion(protoimpl.MaxVersion - 20)
+)
+
+// Call mirrors CallObj.
+type Call struct {
+	state              protoimpl.MessageState `protogen:"open.v1"`
+	CallId             int64                  `protobuf:"varint,1,opt,name=call_id,json=callId,proto3" json:"call_id,omitempty"`
+	ArrivedDateTime    string                 `protobuf:"bytes,2,opt,name=arrived_date_time,json=arrivedDateTime,proto3" json:"arrived_date_time,omitempty"`
+	CallNumber         int32                  `protobuf:"varint,3,opt,name=call_number,json=callNumber,proto3" json:"call_number,omitempty"`
+	CallPriority       string                 `protobuf:"bytes,4,opt,name=call_priority,json=callPriority,proto3" json:"call_priority,omitempty"`
+	CallSource         string                 `protobuf:"bytes,5,opt,name=call_source,json=callSource,proto3" json:"call_source,omitempty"`
+	CallStatus         string                 `protobuf:"bytes,6,opt,name=call_status,json=callStatus,proto3" json:"call_status,omitempty"`
+	CallType           string                 `protobuf:"bytes,7,opt,name=call_type,json=callType,proto3" json:"call_type,omitempty"`
+	CallTypeId         int32                  `protobuf:"varint,8,opt,name=call_type_id,json=callTypeId,proto3" json:"call_type_id,omitempty"`
+	CommonName         string                 `protobuf:"bytes,9,opt,name=common_name,json=commonName,proto3" json:"common_name,omitempty"`
+	ClosedFlag         bool                   `protobuf:"varint,10,opt,name=closed_flag,json=closedFlag,proto3" json:"closed_flag,omitempty"`
+	CreatedDateTime    string                 `protobuf:"bytes,11,opt,name=created_date_time,json=createdDateTime,proto3" json:"created_date_time,omitempty"`
+	DispatchedDateTime string                 `protobuf:"bytes,12,opt,name=dispatched_date_time,json=dispatchedDateTime,proto3" json:"dispatched_date_time,omitempty"`
+	FireCallType       string                 `protobuf:"bytes,13,opt,name=fire_call_type,json=fireCallType,proto3" json:"fire_call_type,omitempty"`
+	FireCallTypeId     string                 `protobuf:"bytes,14,opt,name=fire_call_type_id,json=fireCallTypeId,proto3" json:"fire_call_type_id,omitempty"`
+	IncidentNumber     string                 `protobuf:"bytes,15,opt,name=incident_number,json=incidentNumber,proto3" json:"incident_number,omitempty"`
+	LatitudeY          float64                `protobuf:"fixed64,16,opt,name=latitude_y,json=latitudeY,proto3" json:"latitude_y,omitempty"`
+	LongitudeX         float64                `protobuf:"fixed64,17,opt,name=longitude_x,json=longitudeX,proto3" json:"longitude_x,omitempty"`
+	Location           string                 `protobuf:"bytes,18,opt,name=location,proto3" json:"location,omitempty"`
+	NatureOfCall       string                 `protobuf:"bytes,19,opt,name=nature_of_call,json=natureOfCall,proto3" json:"nature_of_call,omitempty"`
+	PrimaryUnit        string                 `protobuf:"bytes,20,opt,name=primary_unit,json=primaryUnit,proto3" json:"primary_unit,omitempty"`
+	Quadrant           string                 `protobuf:"bytes,21,opt,name=quadrant,proto3" json:"quadrant,omitempty"`
+	AllowedOri         []string               `protobuf:"bytes,22,rep,name=allowed_ori,json=allowedOri,proto3" json:"allowed_ori,omitempty"`
+	ForegroundR        int32                  `protobuf:"varint,23,opt,name=foreground_r,json=foregroundR,proto3" json:"foreground_r,omitempty"`
+	ForegroundG        int32                  `protobuf:"varint,24,opt,name=foreground_g,json=foregroundG,proto3" json:"foreground_g,omitempty"`
+	ForegroundB        int32                  `protobuf:"varint,25,opt,name=foreground_b,json=foregroundB,proto3" json:"foreground_b,omitempty"`
+	Zones              []string               `protobuf:"bytes,26,rep,name=zones,proto3" json:"zones,omitempty"`
+	unknownFields      protoimpl.UnknownFields
+	sizeCache          protoimpl.SizeCache
+}
+
+func (x *Call) Reset() {
+	*x = Call{}
+	mi := &file_cadview_proto_msgTypes[0]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *Call) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*Call) ProtoMessage() {}
+
+func (x *Call) ProtoReflect() protoreflect.Message {
+	mi := &file_cadview_proto_msgTypes[0]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use Call.ProtoReflect.Descriptor instead.
+func (*Call) Descriptor() ([]byte, []int) {
+	return file_cadview_proto_rawDescGZIP(), []int{0}
+}
+
+func (x *Call) GetCallId() int64 {
+	if x != nil {
+		return x.CallId
+	}
+	return 0
+}
+
+func (x *Call) GetArrivedDateTime() string {
+	if x != nil {
+		return x.ArrivedDateTime
+	}
+	return ""
+}
+
+func (x *Call) GetCallNumber() int32 {
+	if x != nil {
+		return x.CallNumber
+	}
+	return 0
+}
+
+func (x *Call) GetCallPriority() string {
+	if x != nil {
+		return x.CallPriority
+	}
+	return ""
+}
+
+func (x *Call) GetCallSource() string {
+	if x != nil {
+		return x.CallSource
+	}
+	return ""
+}
+
+func (x *Call) GetCallStatus() string {
+	if x != nil {
+		return x.CallStatus
+	}
+	return ""
+}
+
+func (x *Call) GetCallType() string {
+	if x != nil {
+		return x.CallType
+	}
+	return ""
+}
+
+func (x *Call) GetCallTypeId() int32 {
+	if x != nil {
+		return x.CallTypeId
+	}
+	return 0
+}
+
+func (x *Call) GetCommonName() string {
+	if x != nil {
+		return x.CommonName
+	}
+	return ""
+}
+
+func (x *Call) GetClosedFlag() bool {
+	if x != nil {
+		return x.ClosedFlag
+	}
+	return false
+}
+
+func (x *Call) GetCreatedDateTime() string {
+	if x != nil {
+		return x.CreatedDateTime
+	}
+	return ""
+}
+
+func (x *Call) GetDispatchedDateTime() string {
+	if x != nil {
+		return x.DispatchedDateTime
+	}
+	return ""
+}
+
+func (x *Call) GetFireCallType() string {
+	if x != nil {
+		return x.FireCallType
+	}
+	return ""
+}
+
+func (x *Call) GetFireCallTypeId() string {
+	if x != nil {
+		return x.FireCallTypeId
+	}
+	return ""
+}
+
+func (x *Call) GetIncidentNumber() string {
+	if x != nil {
+		return x.IncidentNumber
+	}
+	return ""
+}
+
+func (x *Call) GetLatitudeY() float64 {
+	if x != nil {
+		return x.LatitudeY
+	}
+	return 0
+}
+
+func (x *Call) GetLongitudeX() float64 {
+	if x != nil {
+		return x.LongitudeX
+	}
+	return 0
+}
+
+func (x *Call) GetLocation() string {
+	if x != nil {
+		return x.Location
+	}
+	return ""
+}
+
+func (x *Call) GetNatureOfCall() string {
+	if x != nil {
+		return x.NatureOfCall
+	}
+	return ""
+}
+
+func (x *Call) GetPrimaryUnit() string {
+	if x != nil {
+		return x.PrimaryUnit
+	}
+	return ""
+}
+
+func (x *Call) GetQuadrant() string {
+	if x != nil {
+		return x.Quadrant
+	}
+	return ""
+}
+
+func (x *Call) GetAllowedOri() []string {
+	if x != nil {
+		return x.AllowedOri
+	}
+	return nil
+}
+
+func (x *Call) GetForegroundR() int32 {
+	if x != nil {
+		return x.ForegroundR
+	}
+	return 0
+}
+
+func (x *Call) GetForegroundG() int32 {
+	if x != nil {
+		return x.ForegroundG
+	}
+	return 0
+}
+
+func (x *Call) GetForegroundB() int32 {
+	if x != nil {
+		return x.ForegroundB
+	}
+	return 0
+}
+
+func (x *Call) GetZones() []string {
+	if x != nil {
+		return x.Zones
+	}
+	return nil
+}
+
+// CallLog mirrors CallLogObj.
+type CallLog struct {
+	state             protoimpl.MessageState `protogen:"open.v1"`
+	CallId            int64                  `protobuf:"varint,1,opt,name=call_id,json=callId,proto3" json:"call_id,omitempty"`
+	Id                string                 `protobuf:"bytes,2,opt,name=id,proto3" json:"id,omitempty"`
+	LogDateTime       string                 `protobuf:"bytes,3,opt,name=log_date_time,json=logDateTime,proto3" json:"log_date_time,omitempty"`
+	ActionDescription string                 `protobuf:"bytes,4,opt,name=action_description,json=actionDescription,proto3" json:"action_description,omitempty"`
+	Description       string                 `protobuf:"bytes,5,opt,name=description,proto3" json:"description,omitempty"`
+	FirstName         string                 `protobuf:"bytes,6,opt,name=first_name,json=firstName,proto3" json:"first_name,omitempty"`
+	LastName          string                 `protobuf:"bytes,7,opt,name=last_name,json=lastName,proto3" json:"last_name,omitempty"`
+	Machine           string                 `protobuf:"bytes,8,opt,name=machine,proto3" json:"machine,omitempty"`
+	unknownFields     protoimpl.UnknownFields
+	sizeCache         protoimpl.SizeCache
+}
+
+func (x *CallLog) Reset() {
+	*x = CallLog{}
+	mi := &file_cadview_proto_msgTypes[1]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *CallLog) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*CallLog) ProtoMessage() {}
+
+func (x *CallLog) ProtoReflect() protoreflect.Message {
+	mi := &file_cadview_proto_msgTypes[1]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use CallLog.ProtoReflect.Descriptor instead.
+func (*CallLog) Descriptor() ([]byte, []int) {
+	return file_cadview_proto_rawDescGZIP(), []int{1}
+}
+
+func (x *CallLog) GetCallId() int64 {
+	if x != nil {
+		return x.CallId
+	}
+	return 0
+}
+
+func (x *CallLog) GetId() string {
+	if x != nil {
+		return x.Id
+	}
+	return ""
+}
+
+func (x *CallLog) GetLogDateTime() string {
+	if x != nil {
+		return x.LogDateTime
+	}
+	return ""
+}
+
+func (x *CallLog) GetActionDescription() string {
+	if x != nil {
+		return x.ActionDescription
+	}
+	return ""
+}
+
+func (x *CallLog) GetDescription() string {
+	if x != nil {
+		return x.Description
+	}
+	return ""
+}
+
+func (x *CallLog) GetFirstName() string {
+	if x != nil {
+		return x.FirstName
+	}
+	return ""
+}
+
+func (x *CallLog) GetLastName() string {
+	if x != nil {
+		return x.LastName
+	}
+	return ""
+}
+
+func (x *CallLog) GetMachine() string {
+	if x != nil {
+		return x.Machine
+	}
+	return ""
+}
+
+// Incident mirrors IncidentObj.
+type Incident struct {
+	state          protoimpl.MessageState `protogen:"open.v1"`
+	CallId         int64                  `protobuf:"varint,1,opt,name=call_id,json=callId,proto3" json:"call_id,omitempty"`
+	Id             string                 `protobuf:"bytes,2,opt,name=id,proto3" json:"id,omitempty"`
+	IncidentNumber string                 `protobuf:"bytes,3,opt,name=incident_number,json=incidentNumber,proto3" json:"incident_number,omitempty"`
+	Ori            string                 `protobuf:"bytes,4,opt,name=ori,proto3" json:"ori,omitempty"`
+	Department     string                 `protobuf:"bytes,5,opt,name=department,proto3" json:"department,omitempty"`
+	Abbreviation   string                 `protobuf:"bytes,6,opt,name=abbreviation,proto3" json:"abbreviation,omitempty"`
+	AgencyType     string                 `protobuf:"bytes,7,opt,name=agency_type,json=agencyType,proto3" json:"agency_type,omitempty"`
+	unknownFields  protoimpl.UnknownFields
+	sizeCache      protoimpl.SizeCache
+}
+
+func (x *Incident) Reset() {
+	*x = Incident{}
+	mi := &file_cadview_proto_msgTypes[2]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *Incident) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*Incident) ProtoMessage() {}
+
+func (x *Incident) ProtoReflect() protoreflect.Message {
+	mi := &file_cadview_proto_msgTypes[2]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use Incident.ProtoReflect.Descriptor instead.
+func (*Incident) Descriptor() ([]byte, []int) {
+	return file_cadview_proto_rawDescGZIP(), []int{2}
+}
+
+func (x *Incident) GetCallId() int64 {
+	if x != nil {
+		return x.CallId
+	}
+	return 0
+}
+
+func (x *Incident) GetId() string {
+	if x != nil {
+		return x.Id
+	}
+	return ""
+}
+
+func (x *Incident) GetIncidentNumber() string {
+	if x != nil {
+		return x.IncidentNumber
+	}
+	return ""
+}
+
+func (x *Incident) GetOri() string {
+	if x != nil {
+		return x.Ori
+	}
+	return ""
+}
+
+func (x *Incident) GetDepartment() string {
+	if x != nil {
+		return x.Department
+	}
+	return ""
+}
+
+func (x *Incident) GetAbbreviation() string {
+	if x != nil {
+		return x.Abbreviation
+	}
+	return ""
+}
+
+func (x *Incident) GetAgencyType() string {
+	if x != nil {
+		return x.AgencyType
+	}
+	return ""
+}
+
+// Narrative mirrors NarrativeObj.
+type Narrative struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	CallId        int64                  `protobuf:"varint,1,opt,name=call_id,json=callId,proto3" json:"call_id,omitempty"`
+	Id            string                 `protobuf:"bytes,2,opt,name=id,proto3" json:"id,omitempty"`
+	Narrative     string                 `protobuf:"bytes,3,opt,name=narrative,proto3" json:"narrative,omitempty"`
+	EnteredDate   string                 `protobuf:"bytes,4,opt,name=entered_date,json=enteredDate,proto3" json:"entered_date,omitempty"`
+	FirstName     string                 `protobuf:"bytes,5,opt,name=first_name,json=firstName,proto3" json:"first_name,omitempty"`
+	LastName      string                 `protobuf:"bytes,6,opt,name=last_name,json=lastName,proto3" json:"last_name,omitempty"`
+	Machine       string                 `protobuf:"bytes,7,opt,name=machine,proto3" json:"machine,omitempty"`
+	NarrativeType string                 `protobuf:"bytes,8,opt,name=narrative_type,json=narrativeType,proto3" json:"narrative_type,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *Narrative) Reset() {
+	*x = Narrative{}
+	mi := &file_cadview_proto_msgTypes[3]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *Narrative) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*Narrative) ProtoMessage() {}
+
+func (x *Narrative) ProtoReflect() protoreflect.Message {
+	mi := &file_cadview_proto_msgTypes[3]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use Narrative.ProtoReflect.Descriptor instead.
+func (*Narrative) Descriptor() ([]byte, []int) {
+	return file_cadview_proto_rawDescGZIP(), []int{3}
+}
+
+func (x *Narrative) GetCallId() int64 {
+	if x != nil {
+		return x.CallId
+	}
+	return 0
+}
+
+func (x *Narrative) GetId() string {
+	if x != nil {
+		return x.Id
+	}
+	return ""
+}
+
+func (x *Narrative) GetNarrative() string {
+	if x != nil {
+		return x.Narrative
+	}
+	return ""
+}
+
+func (x *Narrative) GetEnteredDate() string {
+	if x != nil {
+		return x.EnteredDate
+	}
+	return ""
+}
+
+func (x *Narrative) GetFirstName() string {
+	if x != nil {
+		return x.FirstName
+	}
+	return ""
+}
+
+func (x *Narrative) GetLastName() string {
+	if x != nil {
+		return x.LastName
+	}
+	return ""
+}
+
+func (x *Narrative) GetMachine() string {
+	if x != nil {
+		return x.Machine
+	}
+	return ""
+}
+
+func (x *Narrative) GetNarrativeType() string {
+	if x != nil {
+		return x.NarrativeType
+	}
+	return ""
+}
+
+// ORI mirrors ORIObj.
+type ORI struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Ori           string                 `protobuf:"bytes,1,opt,name=ori,proto3" json:"ori,omitempty"`
+	Fdid          string                 `protobuf:"bytes,2,opt,name=fdid,proto3" json:"fdid,omitempty"`
+	AgencyName    string                 `protobuf:"bytes,3,opt,name=agency_name,json=agencyName,proto3" json:"agency_name,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *ORI) Reset() {
+	*x = ORI{}
+	mi := &file_cadview_proto_msgTypes[4]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *ORI) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*ORI) ProtoMessage() {}
+
+func (x *ORI) ProtoReflect() protoreflect.Message {
+	mi := &file_cadview_proto_msgTypes[4]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use ORI.ProtoReflect.Descriptor instead.
+func (*ORI) Descriptor() ([]byte, []int) {
+	return file_cadview_proto_rawDescGZIP(), []int{4}
+}
+
+func (x *ORI) GetOri() string {
+	if x != nil {
+		return x.Ori
+	}
+	return ""
+}
+
+func (x *ORI) GetFdid() string {
+	if x != nil {
+		return x.Fdid
+	}
+	return ""
+}
+
+func (x *ORI) GetAgencyName() string {
+	if x != nil {
+		return x.AgencyName
+	}
+	return ""
+}
+
+// Unit mirrors UnitObj.
+type Unit struct {
+	state                  protoimpl.MessageState `protogen:"open.v1"`
+	CallId                 int64                  `protobuf:"varint,1,opt,name=call_id,json=callId,proto3" json:"call_id,omitempty"`
+	Id                     string                 `protobuf:"bytes,2,opt,name=id,proto3" json:"id,omitempty"`
+	Ori                    string                 `protobuf:"bytes,3,opt,name=ori,proto3" json:"ori,omitempty"`
+	UnitNumber             string                 `protobuf:"bytes,4,opt,name=unit_number,json=unitNumber,proto3" json:"unit_number,omitempty"`
+	DispatchDateTime       string                 `protobuf:"bytes,5,opt,name=dispatch_date_time,json=dispatchDateTime,proto3" json:"dispatch_date_time,omitempty"`
+	EnrouteDateTime        string                 `protobuf:"bytes,6,opt,name=enroute_date_time,json=enrouteDateTime,proto3" json:"enroute_date_time,omitempty"`
+	StagedDateTime         string                 `protobuf:"bytes,7,opt,name=staged_date_time,json=stagedDateTime,proto3" json:"staged_date_time,omitempty"`
+	AtPatientDateTime      string                 `protobuf:"bytes,8,opt,name=at_patient_date_time,json=atPatientDateTime,proto3" json:"at_patient_date_time,omitempty"`
+	ArriveDateTime         string                 `protobuf:"bytes,9,opt,name=arrive_date_time,json=arriveDateTime,proto3" json:"arrive_date_time,omitempty"`
+	TransportDateTime      string                 `protobuf:"bytes,10,opt,name=transport_date_time,json=transportDateTime,proto3" json:"transport_date_time,omitempty"`
+	AtHospitalDateTime     string                 `protobuf:"bytes,11,opt,name=at_hospital_date_time,json=atHospitalDateTime,proto3" json:"at_hospital_date_time,omitempty"`
+	DepartHospitalDateTime string                 `protobuf:"bytes,12,opt,name=depart_hospital_date_time,json=departHospitalDateTime,proto3" json:"depart_hospital_date_time,omitempty"`
+	ClearDateTime          string                 `protobuf:"bytes,13,opt,name=clear_date_time,json=clearDateTime,proto3" json:"clear_date_time,omitempty"`
+	unknownFields          protoimpl.UnknownFields
+	sizeCache              protoimpl.SizeCache
+}
+
+func (x *Unit) Reset() {
+	*x = Unit{}
+	mi := &file_cadview_proto_msgTypes[5]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *Unit) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*Unit) ProtoMessage() {}
+
+func (x *Unit) ProtoReflect() protoreflect.Message {
+	mi := &file_cadview_proto_msgTypes[5]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use Unit.ProtoReflect.Descriptor instead.
+func (*Unit) Descriptor() ([]byte, []int) {
+	return file_cadview_proto_rawDescGZIP(), []int{5}
+}
+
+func (x *Unit) GetCallId() int64 {
+	if x != nil {
+		return x.CallId
+	}
+	return 0
+}
+
+func (x *Unit) GetId() string {
+	if x != nil {
+		return x.Id
+	}
+	return ""
+}
+
+func (x *Unit) GetOri() string {
+	if x != nil {
+		return x.Ori
+	}
+	return ""
+}
+
+func (x *Unit) GetUnitNumber() string {
+	if x != nil {
+		return x.UnitNumber
+	}
+	return ""
+}
+
+func (x *Unit) GetDispatchDateTime() string {
+	if x != nil {
+		return x.DispatchDateTime
+	}
+	return ""
+}
+
+func (x *Unit) GetEnrouteDateTime() string {
+	if x != nil {
+		return x.EnrouteDateTime
+	}
+	return ""
+}
+
+func (x *Unit) GetStagedDateTime() string {
+	if x != nil {
+		return x.StagedDateTime
+	}
+	return ""
+}
+
+func (x *Unit) GetAtPatientDateTime() string {
+	if x != nil {
+		return x.AtPatientDateTime
+	}
+	return ""
+}
+
+func (x *Unit) GetArriveDateTime() string {
+	if x != nil {
+		return x.ArriveDateTime
+	}
+	return ""
+}
+
+func (x *Unit) GetTransportDateTime() string {
+	if x != nil {
+		return x.TransportDateTime
+	}
+	return ""
+}
+
+func (x *Unit) GetAtHospitalDateTime() string {
+	if x != nil {
+		return x.AtHospitalDateTime
+	}
+	return ""
+}
+
+func (x *Unit) GetDepartHospitalDateTime() string {
+	if x != nil {
+		return x.DepartHospitalDateTime
+	}
+	return ""
+}
+
+func (x *Unit) GetClearDateTime() string {
+	if x != nil {
+		return x.ClearDateTime
+	}
+	return ""
+}
+
+// UnitLog mirrors UnitLogObj.
+type UnitLog struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	CallId        int64                  `protobuf:"varint,1,opt,name=call_id,json=callId,proto3" json:"call_id,omitempty"`
+	Id            string                 `protobuf:"bytes,2,opt,name=id,proto3" json:"id,omitempty"`
+	LogDateTime   string                 `protobuf:"bytes,3,opt,name=log_date_time,json=logDateTime,proto3" json:"log_date_time,omitempty"`
+	Action        string                 `protobuf:"bytes,4,opt,name=action,proto3" json:"action,omitempty"`
+	Description   string                 `protobuf:"bytes,5,opt,name=description,proto3" json:"description,omitempty"`
+	UnitNumber    string                 `protobuf:"bytes,6,opt,name=unit_number,json=unitNumber,proto3" json:"unit_number,omitempty"`
+	Status        string                 `protobuf:"bytes,7,opt,name=status,proto3" json:"status,omitempty"`
+	FirstName     string                 `protobuf:"bytes,8,opt,name=first_name,json=firstName,proto3" json:"first_name,omitempty"`
+	LastName      string                 `protobuf:"bytes,9,opt,name=last_name,json=lastName,proto3" json:"last_name,omitempty"`
+	Machine       string                 `protobuf:"bytes,10,opt,name=machine,proto3" json:"machine,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *UnitLog) Reset() {
+	*x = UnitLog{}
+	mi := &file_cadview_proto_msgTypes[6]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *UnitLog) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*UnitLog) ProtoMessage() {}
+
+func (x *UnitLog) ProtoReflect() protoreflect.Message {
+	mi := &file_cadview_proto_msgTypes[6]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use UnitLog.ProtoReflect.Descriptor instead.
+func (*UnitLog) Descriptor() ([]byte, []int) {
+	return file_cadview_proto_rawDescGZIP(), []int{6}
+}
+
+func (x *UnitLog) GetCallId() int64 {
+	if x != nil {
+		return x.CallId
+	}
+	return 0
+}
+
+func (x *UnitLog) GetId() string {
+	if x != nil {
+		return x.Id
+	}
+	return ""
+}
+
+func (x *UnitLog) GetLogDateTime() string {
+	if x != nil {
+		return x.LogDateTime
+	}
+	return ""
+}
+
+func (x *UnitLog) GetAction() string {
+	if x != nil {
+		return x.Action
+	}
+	return ""
+}
+
+func (x *UnitLog) GetDescription() string {
+	if x != nil {
+		return x.Description
+	}
+	return ""
+}
+
+func (x *UnitLog) GetUnitNumber() string {
+	if x != nil {
+		return x.UnitNumber
+	}
+	return ""
+}
+
+func (x *UnitLog) GetStatus() string {
+	if x != nil {
+		return x.Status
+	}
+	return ""
+}
+
+func (x *UnitLog) GetFirstName() string {
+	if x != nil {
+		return x.FirstName
+	}
+	return ""
+}
+
+func (x *UnitLog) GetLastName() string {
+	if x != nil {
+		return x.LastName
+	}
+	return ""
+}
+
+func (x *UnitLog) GetMachine() string {
+	if x != nil {
+		return x.Machine
+	}
+	return ""
+}
+
+// CADCall mirrors CADCall, a call with all of its related records.
+type CADCall struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Call          *Call                  `protobuf:"bytes,1,opt,name=call,proto3" json:"call,omitempty"`
+	Logs          []*CallLog             `protobuf:"bytes,2,rep,name=logs,proto3" json:"logs,omitempty"`
+	Incidents     []*Incident            `protobuf:"bytes,3,rep,name=incidents,proto3" json:"incidents,omitempty"`
+	Narratives    []*Narrative           `protobuf:"bytes,4,rep,name=narratives,proto3" json:"narratives,omitempty"`
+	Units         []*Unit                `protobuf:"bytes,5,rep,name=units,proto3" json:"units,omitempty"`
+	UnitLogs      []*UnitLog             `protobuf:"bytes,6,rep,name=unit_logs,json=unitLogs,proto3" json:"unit_logs,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *CADCall) Reset() {
+	*x = CADCall{}
+	mi := &file_cadview_proto_msgTypes[7]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *CADCall) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*CADCall) ProtoMessage() {}
+
+func (x *CADCall) ProtoReflect() protoreflect.Message {
+	mi := &file_cadview_proto_msgTypes[7]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use CADCall.ProtoReflect.Descriptor instead.
+func (*CADCall) Descriptor() ([]byte, []int) {
+	return file_cadview_proto_rawDescGZIP(), []int{7}
+}
+
+func (x *CADCall) GetCall() *Call {
+	if x != nil {
+		return x.Call
+	}
+	return nil
+}
+
+func (x *CADCall) GetLogs() []*CallLog {
+	if x != nil {
+		return x.Logs
+	}
+	return nil
+}
+
+func (x *CADCall) GetIncidents() []*Incident {
+	if x != nil {
+		return x.Incidents
+	}
+	return nil
+}
+
+func (x *CADCall) GetNarratives() []*Narrative {
+	if x != nil {
+		return x.Narratives
+	}
+	return nil
+}
+
+func (x *CADCall) GetUnits() []*Unit {
+	if x != nil {
+		return x.Units
+	}
+	return nil
+}
+
+func (x *CADCall) GetUnitLogs() []*UnitLog {
+	if x != nil {
+		return x.UnitLogs
+	}
+	return nil
+}
+
+// Alert mirrors Alert.
+type Alert struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Rule          string                 `protobuf:"bytes,1,opt,name=rule,proto3" json:"rule,omitempty"`
+	Source        string                 `protobuf:"bytes,2,opt,name=source,proto3" json:"source,omitempty"`
+	Field         string                 `protobuf:"bytes,3,opt,name=field,proto3" json:"field,omitempty"`
+	Match         string                 `protobuf:"bytes,4,opt,name=match,proto3" json:"match,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *Alert) Reset() {
+	*x = Alert{}
+	mi := &file_cadview_proto_msgTypes[8]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *Alert) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*Alert) ProtoMessage() {}
+
+func (x *Alert) ProtoReflect() protoreflect.Message {
+	mi := &file_cadview_proto_msgTypes[8]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use Alert.ProtoReflect.Descriptor instead.
+func (*Alert) Descriptor() ([]byte, []int) {
+	return file_cadview_proto_rawDescGZIP(), []int{8}
+}
+
+func (x *Alert) GetRule() string {
+	if x != nil {
+		return x.Rule
+	}
+	return ""
+}
+
+func (x *Alert) GetSource() string {
+	if x != nil {
+		return x.Source
+	}
+	return ""
+}
+
+func (x *Alert) GetField() string {
+	if x != nil {
+		return x.Field
+	}
+	return ""
+}
+
+func (x *Alert) GetMatch() string {
+	if x != nil {
+		return x.Match
+	}
+	return ""
+}
+
+// CallEvent mirrors Event, a single change in the active call list.
+type CallEvent struct {
+	state protoimpl.MessageState `protogen:"open.v1"`
+	// id increases with each event sent on a stream.
+	Id uint64 `protobuf:"varint,1,opt,name=id,proto3" json:"id,omitempty"`
+	// type is one of "snapshot", "new_call", "call_updated", "unit_status",
+	// "narrative", "call_closed" or "alert".
+	Type           string                 `protobuf:"bytes,2,opt,name=type,proto3" json:"type,omitempty"`
+	Time           *timestamppb.Timestamp `protobuf:"bytes,3,opt,name=time,proto3" json:"time,omitempty"`
+	Call           *Call                  `protobuf:"bytes,4,opt,name=call,proto3" json:"call,omitempty"`
+	Units          []*Unit                `protobuf:"bytes,5,rep,name=units,proto3" json:"units,omitempty"`
+	Narratives     []*Narrative           `protobuf:"bytes,6,rep,name=narratives,proto3" json:"narratives,omitempty"`
+	Unit           *Unit                  `protobuf:"bytes,7,opt,name=unit,proto3" json:"unit,omitempty"`
+	Status         string                 `protobuf:"bytes,8,opt,name=status,proto3" json:"status,omitempty"`
+	PreviousStatus string                 `protobuf:"bytes,9,opt,name=previous_status,json=previousStatus,proto3" json:"previous_status,omitempty"`
+	Narrative      *Narrative             `protobuf:"bytes,10,opt,name=narrative,proto3" json:"narrative,omitempty"`
+	Alert          *Alert                 `protobuf:"bytes,11,opt,name=alert,proto3" json:"alert,omitempty"`
+	unknownFields  protoimpl.UnknownFields
+	sizeCache      protoimpl.SizeCache
+}
+
+func (x *CallEvent) Reset() {
+	*x = CallEvent{}
+	mi := &file_cadview_proto_msgTypes[9]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *CallEvent) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*CallEvent) ProtoMessage() {}
+
+func (x *CallEvent) ProtoReflect() protoreflect.Message {
+	mi := &file_cadview_proto_msgTypes[9]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use CallEvent.ProtoReflect.Descriptor instead.
+func (*CallEvent) Descriptor() ([]byte, []int) {
+	return file_cadview_proto_rawDescGZIP(), []int{9}
+}
+
+func (x *CallEvent) GetId() uint64 {
+	if x != nil {
+		return x.Id
+	}
+	return 0
+}
+
+func (x *CallEvent) GetType() string {
+	if x != nil {
+		return x.Type
+	}
+	return ""
+}
+
+func (x *CallEvent) GetTime() *timestamppb.Timestamp {
+	if x != nil {
+		return x.Time
+	}
+	return nil
+}
+
+func (x *CallEvent) GetCall() *Call {
+	if x != nil {
+		return x.Call
+	}
+	return nil
+}
+
+func (x *CallEvent) GetUnits() []*Unit {
+	if x != nil {
+		return x.Units
+	}
+	return nil
+}
+
+func (x *CallEvent) GetNarratives() []*Narrative {
+	if x != nil {
+		return x.Narratives
+	}
+	return nil
+}
+
+func (x *CallEvent) GetUnit() *Unit {
+	if x != nil {
+		return x.Unit
+	}
+	return nil
+}
+
+func (x *CallEvent) GetStatus() string {
+	if x != nil {
+		return x.Status
+	}
+	return ""
+}
+
+func (x *CallEvent) GetPreviousStatus() string {
+	if x != nil {
+		return x.PreviousStatus
+	}
+	return ""
+}
+
+func (x *CallEvent) GetNarrative() *Narrative {
+	if x != nil {
+		return x.Narrative
+	}
+	return nil
+}
+
+func (x *CallEvent) GetAlert() *Alert {
+	if x != nil {
+		return x.Alert
+	}
+	return nil
+}
+
+type GetActiveCallsRequest struct {
+	state protoimpl.MessageState `protogen:"open.v1"`
+	// oris limits the result to calls allowed for these FDIDs or ORIs.
+	Oris          []string `protobuf:"bytes,1,rep,name=oris,proto3" json:"oris,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *GetActiveCallsRequest) Reset() {
+	*x = GetActiveCallsRequest{}
+	mi := &file_cadview_proto_msgTypes[10]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *GetActiveCallsRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*GetActiveCallsRequest) ProtoMessage() {}
+
+func (x *GetActiveCallsRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_cadview_proto_msgTypes[10]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use GetActiveCallsRequest.ProtoReflect.Descriptor instead.
+func (*GetActiveCallsRequest) Descriptor() ([]byte, []int) {
+	return file_cadview_proto_rawDescGZIP(), []int{10}
+}
+
+func (x *GetActiveCallsRequest) GetOris() []string {
+	if x != nil {
+		return x.Oris
+	}
+	return nil
+}
+
+type SearchClearedCallsRequest struct {
+	state protoimpl.MessageState `protogen:"open.v1"`
+	From  *timestamppb.Timestamp `protobuf:"bytes,1,opt,name=from,proto3" json:"from,omitempty"`
+	To    *timestamppb.Timestamp `protobuf:"bytes,2,opt,name=to,proto3" json:"to,omitempty"`
+	// ori is a CAD ORI or FDID, defaulting to the FDID of the agent.
+	Ori string `protobuf:"bytes,3,opt,name=ori,proto3" json:"ori,omitempty"`
+	// incident_number and call_type optionally filter the results.
+	IncidentNumber string `protobuf:"bytes,4,opt,name=incident_number,json=incidentNumber,proto3" json:"incident_number,omitempty"`
+	CallType       string `protobuf:"bytes,5,opt,name=call_type,json=callType,proto3" json:"call_type,omitempty"`
+	unknownFields  protoimpl.UnknownFields
+	sizeCache      protoimpl.SizeCache
+}
+
+func (x *SearchClearedCallsRequest) Reset() {
+	*x = SearchClearedCallsRequest{}
+	mi := &file_cadview_proto_msgTypes[11]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *SearchClearedCallsRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*SearchClearedCallsRequest) ProtoMessage() {}
+
+func (x *SearchClearedCallsRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_cadview_proto_msgTypes[11]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use SearchClearedCallsRequest.ProtoReflect.Descriptor instead.
+func (*SearchClearedCallsRequest) Descriptor() ([]byte, []int) {
+	return file_cadview_proto_rawDescGZIP(), []int{11}
+}
+
+func (x *SearchClearedCallsRequest) GetFrom() *timestamppb.Timestamp {
+	if x != nil {
+		return x.From
+	}
+	return nil
+}
+
+func (x *SearchClearedCallsRequest) GetTo() *timestamppb.Timestamp {
+	if x != nil {
+		return x.To
+	}
+	return nil
+}
+
+func (x *SearchClearedCallsRequest) GetOri() string {
+	if x != nil {
+		return x.Ori
+	}
+	return ""
+}
+
+func (x *SearchClearedCallsRequest) GetIncidentNumber() string {
+	if x != nil {
+		return x.IncidentNumber
+	}
+	return ""
+}
+
+func (x *SearchClearedCallsRequest) GetCallType() string {
+	if x != nil {
+		return x.CallType
+	}
+	return ""
+}
+
+type CallList struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Calls         []*Call                `protobuf:"bytes,1,rep,name=calls,proto3" json:"calls,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *CallList) Reset() {
+	*x = CallList{}
+	mi := &file_cadview_proto_msgTypes[12]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *CallList) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*CallList) ProtoMessage() {}
+
+func (x *CallList) ProtoReflect() protoreflect.Message {
+	mi := &file_cadview_proto_msgTypes[12]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use CallList.ProtoReflect.Descriptor instead.
+func (*CallList) Descriptor() ([]byte, []int) {
+	return file_cadview_proto_rawDescGZIP(), []int{12}
+}
+
+func (x *CallList) GetCalls() []*Call {
+	if x != nil {
+		return x.Calls
+	}
+	return nil
+}
+
+type GetCallRequest struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	CallId        int64                  `protobuf:"varint,1,opt,name=call_id,json=callId,proto3" json:"call_id,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *GetCallRequest) Reset() {
+	*x = GetCallRequest{}
+	mi := &file_cadview_proto_msgTypes[13]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *GetCallRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*GetCallRequest) ProtoMessage() {}
+
+func (x *GetCallRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_cadview_proto_msgTypes[13]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use GetCallRequest.ProtoReflect.Descriptor instead.
+func (*GetCallRequest) Descriptor() ([]byte, []int) {
+	return file_cadview_proto_rawDescGZIP(), []int{13}
+}
+
+func (x *GetCallRequest) GetCallId() int64 {
+	if x != nil {
+		return x.CallId
+	}
+	return 0
+}
+
+type GetORIsRequest struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *GetORIsRequest) Reset() {
+	*x = GetORIsRequest{}
+	mi := &file_cadview_proto_msgTypes[14]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *GetORIsRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*GetORIsRequest) ProtoMessage() {}
+
+func (x *GetORIsRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_cadview_proto_msgTypes[14]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use GetORIsRequest.ProtoReflect.Descriptor instead.
+func (*GetORIsRequest) Descriptor() ([]byte, []int) {
+	return file_cadview_proto_rawDescGZIP(), []int{14}
+}
+
+type ORIList struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Oris          []*ORI                 `protobuf:"bytes,1,rep,name=oris,proto3" json:"oris,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *ORIList) Reset() {
+	*x = ORIList{}
+	mi := &file_cadview_proto_msgTypes[15]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *ORIList) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*ORIList) ProtoMessage() {}
+
+func (x *ORIList) ProtoReflect() protoreflect.Message {
+	mi := &file_cadview_proto_msgTypes[15]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use ORIList.ProtoReflect.Descriptor instead.
+func (*ORIList) Descriptor() ([]byte, []int) {
+	return file_cadview_proto_rawDescGZIP(), []int{15}
+}
+
+func (x *ORIList) GetOris() []*ORI {
+	if x != nil {
+		return x.Oris
+	}
+	return nil
+}
+
+type WatchActiveCallsRequest struct {
+	state protoimpl.MessageState `protogen:"open.v1"`
+	// oris limits events to calls allowed for these FDIDs or ORIs.
+	Oris []string `protobuf:"bytes,1,rep,name=oris,proto3" json:"oris,omitempty"`
+	// Events after last_event_id which are still buffered are replayed.
+	// Otherwise a "snapshot" event holding each active call is sent first.
+	LastEventId   uint64 `protobuf:"varint,2,opt,name=last_event_id,json=lastEventId,proto3" json:"last_event_id,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *WatchActiveCallsRequest) Reset() {
+	*x = WatchActiveCallsRequest{}
+	mi := &file_cadview_proto_msgTypes[16]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *WatchActiveCallsRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*WatchActiveCallsRequest) ProtoMessage() {}
+
+func (x *WatchActiveCallsRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_cadview_proto_msgTypes[16]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use WatchActiveCallsRequest.ProtoReflect.Descriptor instead.
+func (*WatchActiveCallsRequest) Descriptor() ([]byte, []int) {
+	return file_cadview_proto_rawDescGZIP(), []int{16}
+}
+
+func (x *WatchActiveCallsRequest) GetOris() []string {
+	if x != nil {
+		return x.Oris
+	}
+	return nil
+}
+
+func (x *WatchActiveCallsRequest) GetLastEventId() uint64 {
+	if x != nil {
+		return x.LastEventId
+	}
+	return 0
+}
+
+var File_cadview_proto protoreflect.FileDescriptor
+
+const file_cadview_proto_rawDesc = "" +
+	"\n" +
+	"\rcadview.proto\x12\n" +
+	"cadview.v1\x1a\x1fgoogle/protobuf/timestamp.proto\"\x8d\a\n" +
+	"\x04Call\x12\x17\n" +
+	"\acall_id\x18\x01 \x01(\x03R\x06callId\x12*\n" +
+	"\x11arrived_date_time\x18\x02 \x01(\tR\x0farrivedDateTime\x12\x1f\n" +
+	"\vcall_number\x18\x03 \x01(\x05R\n" +
+	"callNumber\x12#\n" +
+	"\rcall_priority\x18\x04 \x01(\tR\fcallPriority\x12\x1f\n" +
+	"\vcall_source\x18\x05 \x01(\tR\n" +
+	"callSource\x12\x1f\n" +
+	"\vcall_status\x18\x06 \x01(\tR\n" +
+	"callStatus\x12\x1b\n" +
+	"\tcall_type\x18\a \x01(\tR\bcallType\x12 \n" +
+	"\fcall_type_id\x18\b \x01(\x05R\n" +
+	"callTypeId\x12\x1f\n" +
+	"\vcommon_name\x18\t \x01(\tR\n" +
+	"commonName\x12\x1f\n" +
+	"\vclosed_flag\x18\n" +
+	" \x01(\bR\n" +
+	"closedFlag\x12*\n" +
+	"\x11created_date_time\x18\v \x01(\tR\x0fcreatedDateTime\x120\n" +
+	"\x14dispatched_date_time\x18\f \x01(\tR\x12dispatchedDateTime\x12$\n" +
+	"\x0efire_call_type\x18\r \x01(\tR\ffireCallType\x12)\n" +
+	"\x11fire_call_type_id\x18\x0e \x01(\tR\x0efireCallTypeId\x12'\n" +
+	"\x0fincident_number\x18\x0f \x01(\tR\x0eincidentNumber\x12\x1d\n" +
+	"\n" +
+	"latitude_y\x18\x10 \x01(\x01R\tlatitudeY\x12\x1f\n" +
+	"\vlongitude_x\x18\x11 \x01(\x01R\n" +
+	"longitudeX\x12\x1a\n" +
+	"\blocation\x18\x12 \x01(\tR\blocation\x12$\n" +
+	"\x0enature_of_call\x18\x13 \x01(\tR\fnatureOfCall\x12!\n" +
+	"\fprimary_unit\x18\x14 \x01(\tR\vprimaryUnit\x12\x1a\n" +
+	"\bquadrant\x18\x15 \x01(\tR\bquadrant\x12\x1f\n" +
+	"\vallowed_ori\x18\x16 \x03(\tR\n" +
+	"allowedOri\x12!\n" +
+	"\fforeground_r\x18\x17 \x01(\x05R\vforegroundR\x12!\n" +
+	"\fforeground_g\x18\x18 \x01(\x05R\vforegroundG\x12!\n" +
+	"\fforeground_b\x18\x19 \x01(\x05R\vforegroundB\x12\x14\n" +
+	"\x05zones\x18\x1a \x03(\tR\x05zones\"\xfd\x01\n" +
+	"\aCallLog\x12\x17\n" +
+	"\acall_id\x18\x01 \x01(\x03R\x06callId\x12\x0e\n" +
+	"\x02id\x18\x02 \x01(\tR\x02id\x12\"\n" +
+	"\rlog_date_time\x18\x03 \x01(\tR\vlogDateTime\x12-\n" +
+	"\x12action_description\x18\x04 \x01(\tR\x11actionDescription\x12 \n" +
+	"\vdescription\x18\x05 \x01(\tR\vdescription\x12\x1d\n" +
+	"\n" +
+	"first_name\x18\x06 \x01(\tR\tfirstName\x12\x1b\n" +
+	"\tlast_name\x18\a \x01(\tR\blastName\x12\x18\n" +
+	"\amachine\x18\b \x01(\tR\amachine\"\xd3\x01\n" +
+	"\bIncident\x12\x17\n" +
+	"\acall_id\x18\x01 \x01(\x03R\x06callId\x12\x0e\n" +
+	"\x02id\x18\x02 \x01(\tR\x02id\x12'\n" +
+	"\x0fincident_number\x18\x03 \x01(\tR\x0eincidentNumber\x12\x10\n" +
+	"\x03ori\x18\x04 \x01(\tR\x03ori\x12\x1e\n" +
+	"\n" +
+	"department\x18\x05 \x01(\tR\n" +
+	"department\x12\"\n" +
+	"\fabbreviation\x18\x06 \x01(\tR\fabbreviation\x12\x1f\n" +
+	"\vagency_type\x18\a \x01(\tR\n" +
+	"agencyType\"\xf2\x01\n" +
+	"\tNarrative\x12\x17\n" +
+	"\acall_id\x18\x01 \x01(\x03R\x06callId\x12\x0e\n" +
+	"\x02id\x18\x02 \x01(\tR\x02id\x12\x1c\n" +
+	"\tnarrative\x18\x03 \x01(\tR\tnarrative\x12!\n" +
+	"\fentered_date\x18\x04 \x01(\tR\venteredDate\x12\x1d\n" +
+	"\n" +
+	"first_name\x18\x05 \x01(\tR\tfirstName\x12\x1b\n" +
+	"\tlast_name\x18\x06 \x01(\tR\blastName\x12\x18\n" +
+	"\amachine\x18\a \x01(\tR\amachine\x12%\n" +
+	"\x0enarrative_type\x18\b \x01(\tR\rnarrativeType\"L\n" +
+	"\x03ORI\x12\x10\n" +
+	"\x03ori\x18\x01 \x01(\tR\x03ori\x12\x12\n" +
+	"\x04fdid\x18\x02 \x01(\tR\x04fdid\x12\x1f\n" +
+	"\vagency_name\x18\x03 \x01(\tR\n" +
+	"agencyName\"\x87\x04\n" +
+	"\x04Unit\x12\x17\n" +
+	"\acall_id\x18\x01 \x01(\x03R\x06callId\x12\x0e\n" +
+	"\x02id\x18\x02 \x01(\tR\x02id\x12\x10\n" +
+	"\x03ori\x18\x03 \x01(\tR\x03ori\x12\x1f\n" +
+	"\vunit_number\x18\x04 \x01(\tR\n" +
+	"unitNumber\x12,\n" +
+	"\x12dispatch_date_time\x18\x05 \x01(\tR\x10dispatchDateTime\x12*\n" +
+	"\x11enroute_date_time\x18\x06 \x01(\tR\x0fenrouteDateTime\x12(\n" +
+	"\x10staged_date_time\x18\a \x01(\tR\x0estagedDateTime\x12/\n" +
+	"\x14at_patient_date_time\x18\b \x01(\tR\x11atPatientDateTime\x12(\n" +
+	"\x10arrive_date_time\x18\t \x01(\tR\x0earriveDateTime\x12.\n" +
+	"\x13transport_date_time\x18\n" +
+	" \x01(\tR\x11transportDateTime\x121\n" +
+	"\x15at_hospital_date_time\x18\v \x01(\tR\x12atHospitalDateTime\x129\n" +
+	"\x19depart_hospital_date_time\x18\f \x01(\tR\x16departHospitalDateTime\x12&\n" +
+	"\x0fclear_date_time\x18\r \x01(\tR\rclearDateTime\"\x9f\x02\n" +
+	"\aUnitLog\x12\x17\n" +
+	"\acall_id\x18\x01 \x01(\x03R\x06callId\x12\x0e\n" +
+	"\x02id\x18\x02 \x01(\tR\x02id\x12\"\n" +
+	"\rlog_date_time\x18\x03 \x01(\tR\vlogDateTime\x12\x16\n" +
+	"\x06action\x18\x04 \x01(\tR\x06action\x12 \n" +
+	"\vdescription\x18\x05 \x01(\tR\vdescription\x12\x1f\n" +
+	"\vunit_number\x18\x06 \x01(\tR\n" +
+	"unitNumber\x12\x16\n" +
+	"\x06status\x18\a \x01(\tR\x06status\x12\x1d\n" +
+	"\n" +
+	"first_name\x18\b \x01(\tR\tfirstName\x12\x1b\n" +
+	"\tlast_name\x18\t \x01(\tR\blastName\x12\x18\n" +
+	"\amachine\x18\n" +
+	" \x01(\tR\amachine\"\x9d\x02\n" +
+	"\aCADCall\x12$\n" +
+	"\x04call\x18\x01 \x01(\v2\x10.cadview.v1.CallR\x04call\x12'\n" +
+	"\x04logs\x18\x02 \x03(\v2\x13.cadview.v1.CallLogR\x04logs\x122\n" +
+	"\tincidents\x18\x03 \x03(\v2\x14.cadview.v1.IncidentR\tincidents\x125\n" +
+	"\n" +
+	"narratives\x18\x04 \x03(\v2\x15.cadview.v1.NarrativeR\n" +
+	"narratives\x12&\n" +
+	"\x05units\x18\x05 \x03(\v2\x10.cadview.v1.UnitR\x05units\x120\n" +
+	"\tunit_logs\x18\x06 \x03(\v2\x13.cadview.v1.UnitLogR\bunitLogs\"_\n" +
+	"\x05Alert\x12\x12\n" +
+	"\x04rule\x18\x01 \x01(\tR\x04rule\x12\x16\n" +
+	"\x06source\x18\x02 \x01(\tR\x06source\x12\x14\n" +
+	"\x05field\x18\x03 \x01(\tR\x05field\x12\x14\n" +
+	"\x05match\x18\x04 \x01(\tR\x05match\"\xa9\x03\n" +
+	"\tCallEvent\x12\x0e\n" +
+	"\x02id\x18\x01 \x01(\x04R\x02id\x12\x12\n" +
+	"\x04type\x18\x02 \x01(\tR\x04type\x12.\n" +
+	"\x04time\x18\x03 \x01(\v2\x1a.google.protobuf.TimestampR\x04time\x12$\n" +
+	"\x04call\x18\x04 \x01(\v2\x10.cadview.v1.CallR\x04call\x12&\n" +
+	"\x05units\x18\x05 \x03(\v2\x10.cadview.v1.UnitR\x05units\x125\n" +
+	"\n" +
+	"narratives\x18\x06 \x03(\v2\x15.cadview.v1.NarrativeR\n" +
+	"narratives\x12$\n" +
+	"\x04unit\x18\a \x01(\v2\x10.cadview.v1.UnitR\x04unit\x12\x16\n" +
+	"\x06status\x18\b \x01(\tR\x06status\x12'\n" +
+	"\x0fprevious_status\x18\t \x01(\tR\x0epreviousStatus\x123\n" +
+	"\tnarrative\x18\n" +
+	" \x01(\v2\x15.cadview.v1.NarrativeR\tnarrative\x12'\n" +
+	"\x05alert\x18\v \x01(\v2\x11.cadview.v1.AlertR\x05alert\"+\n" +
+	"\x15GetActiveCallsRequest\x12\x12\n" +
+	"\x04oris\x18\x01 \x03(\tR\x04oris\"\xcf\x01\n" +
+	"\x19SearchClearedCallsRequest\x12.\n" +
+	"\x04from\x18\x01 \x01(\v2\x1a.google.protobuf.TimestampR\x04from\x12*\n" +
+	"\x02to\x18\x02 \x01(\v2\x1a.google.protobuf.TimestampR\x02to\x12\x10\n" +
+	"\x03ori\x18\x03 \x01(\tR\x03ori\x12'\n" +
+	"\x0fincident_number\x18\x04 \x01(\tR\x0eincidentNumber\x12\x1b\n" +
+	"\tcall_type\x18\x05 \x01(\tR\bcallType\"2\n" +
+	"\bCallList\x12&\n" +
+	"\x05calls\x18\x01 \x03(\v2\x10.cadview.v1.CallR\x05calls\")\n" +
+	"\x0eGetCallRequest\x12\x17\n" +
+	"\acall_id\x18\x01 \x01(\x03R\x06callId\"\x10\n" +
+	"\x0eGetORIsRequest\".\n" +
+	"\aORIList\x12#\n" +
+	"\x04oris\x18\x01 \x03(\v2\x0f.cadview.v1.ORIR\x04oris\"Q\n" +
+	"\x17WatchActiveCallsRequest\x12\x12\n" +
+	"\x04oris\x18\x01 \x03(\tR\x04oris\x12\"\n" +
+	"\rlast_event_id\x18\x02 \x01(\x04R\vlastEventId2\xf1\x02\n" +
+	"\aCadView\x12I\n" +
+	"\x0eGetActiveCalls\x12!.cadview.v1.GetActiveCallsRequest\x1a\x14.cadview.v1.CallList\x12Q\n" +
+	"\x12SearchClearedCalls\x12%.cadview.v1.SearchClearedCallsRequest\x1a\x14.cadview.v1.CallList\x12:\n" +
+	"\aGetCall\x12\x1a.cadview.v1.GetCallRequest\x1a\x13.cadview.v1.CADCall\x12:\n" +
+	"\aGetORIs\x12\x1a.cadview.v1.GetORIsRequest\x1a\x13.cadview.v1.ORIList\x12P\n" +
+	"\x10WatchActiveCalls\x12#.cadview.v1.WatchActiveCallsRequest\x1a\x15.cadview.v1.CallEvent0\x01BDZBgithub.com/dayvillefire/newworld-cadview-agent/agent/rpc/cadviewpbb\x06proto3"
+
+var (
+	file_cadview_proto_rawDescOnce sync.Once
+	file_cadview_proto_rawDescData []byte
+)
+
+func file_cadview_proto_rawDescGZIP() []byte {
+	file_cadview_proto_rawDescOnce.Do(func() {
+		file_cadview_proto_rawDescData = protoimpl.X.CompressGZIP(unsafe.Slice(unsafe.StringData(file_cadview_proto_rawDesc), len(file_cadview_proto_rawDesc)))
+	})
+	return file_cadview_proto_rawDescData
+}
+
+var file_cadview_proto_msgTypes = make([]protoimpl.MessageInfo, 17)
+var file_cadview_proto_goTypes = []any{
+	(*Call)(nil),                      // 0: cadview.v1.Call
+	(*CallLog)(nil),                   // 1: cadview.v1.CallLog
+	(*Incident)(nil),                  // 2: cadview.v1.Incident
+	(*Narrative)(nil),                 // 3: cadview.v1.Narrative
+	(*ORI)(nil),                       // 4: cadview.v1.ORI
+	(*Unit)(nil),                      // 5: cadview.v1.Unit
+	(*UnitLog)(nil),                   // 6: cadview.v1.UnitLog
+	(*CADCall)(nil),                   // 7: cadview.v1.CADCall
+	(*Alert)(nil),                     // 8: cadview.v1.Alert
+	(*CallEvent)(nil),                 // 9: cadview.v1.CallEvent
+	(*GetActiveCallsRequest)(nil),     // 10: cadview.v1.GetActiveCallsRequest
+	(*SearchClearedCallsRequest)(nil), // 11: cadview.v1.SearchClearedCallsRequest
+	(*CallList)(nil),                  // 12: cadview.v1.CallList
+	(*GetCallRequest)(nil),            // 13: cadview.v1.GetCallRequest
+	(*GetORIsRequest)(nil),            // 14: cadview.v1.GetORIsRequest
+	(*ORIList)(nil),                   // 15: cadview.v1.ORIList
+	(*WatchActiveCallsRequest)(nil),   // 16: cadview.v1.WatchActiveCallsRequest
+	(*timestamppb.Timestamp)(nil),     // 17: google.protobuf.Timestamp
+}
+var file_cadview_proto_depIdxs = []int32{
+	0,  // 0: cadview.v1.CADCall.call:type_name -> cadview.v1.Call
+	1,  // 1: cadview.v1.CADCall.logs:type_name -> cadview.v1.CallLog
+	2,  // 2: cadview.v1.CADCall.incidents:type_name -> cadview.v1.Incident
+	3,  // 3: cadview.v1.CADCall.narratives:type_name -> cadview.v1.Narrative
+	5,  // 4: cadview.v1.CADCall.units:type_name -> cadview.v1.Unit
+	6,  // 5: cadview.v1.CADCall.unit_logs:type_name -> cadview.v1.UnitLog
+	17, // 6: cadview.v1.CallEvent.time:type_name -> google.protobuf.Timestamp
+	0,  // 7: cadview.v1.CallEvent.call:type_name -> cadview.v1.Call
+	5,  // 8: cadview.v1.CallEvent.units:type_name -> cadview.v1.Unit
+	3,  // 9: cadview.v1.CallEvent.narratives:type_name -> cadview.v1.Narrative
+	5,  // 10: cadview.v1.CallEvent.unit:type_name -> cadview.v1.Unit
+	3,  // 11: cadview.v1.CallEvent.narrative:type_name -> cadview.v1.Narrative
+	8,  // 12: cadview.v1.CallEvent.alert:type_name -> cadview.v1.Alert
+	17, // 13: cadview.v1.SearchClearedCallsRequest.from:type_name -> google.protobuf.Timestamp
+	17, // 14: cadview.v1.SearchClearedCallsRequest.to:type_name -> google.protobuf.Timestamp
+	0,  // 15: cadview.v1.CallList.calls:type_name -> cadview.v1.Call
+	4,  // 16: cadview.v1.ORIList.oris:type_name -> cadview.v1.ORI
+	10, // 17: cadview.v1.CadView.GetActiveCalls:input_type -> cadview.v1.GetActiveCallsRequest
+	11, // 18: cadview.v1.CadView.SearchClearedCalls:input_type -> cadview.v1.SearchClearedCallsRequest
+	13, // 19: cadview.v1.CadView.GetCall:input_type -> cadview.v1.GetCallRequest
+	14, // 20: cadview.v1.CadView.GetORIs:input_type -> cadview.v1.GetORIsRequest
+	16, // 21: cadview.v1.CadView.WatchActiveCalls:input_type -> cadview.v1.WatchActiveCallsRequest
+	12, // 22: cadview.v1.CadView.GetActiveCalls:output_type -> cadview.v1.CallList
+	12, // 23: cadview.v1.CadView.SearchClearedCalls:output_type -> cadview.v1.CallList
+	7,  // 24: cadview.v1.CadView.GetCall:output_type -> cadview.v1.CADCall
+	15, // 25: cadview.v1.CadView.GetORIs:output_type -> cadview.v1.ORIList
+	9,  // 26: cadview.v1.CadView.WatchActiveCalls:output_type -> cadview.v1.CallEvent
+	22, // [22:27] is the sub-list for method output_type
+	17, // [17:22] is the sub-list for method input_type
+	17, // [17:17] is the sub-list for extension type_name
+	17, // [17:17] is the sub-list for extension extendee
+	0,  // [0:17] is the sub-list for field type_name
+}
+
+func init() { file_cadview_proto_init() }
+func file_cadview_proto_init() {
+	if File_cadview_proto != nil {
+		return
+	}
+	type x struct{}
+	out := protoimpl.TypeBuilder{
+		File: protoimpl.DescBuilder{
+			GoPackagePath: reflect.TypeOf(x{}).PkgPath(),
+			RawDescriptor: unsafe.Slice(unsafe.StringData(file_cadview_proto_rawDesc), len(file_cadview_proto_rawDesc)),
+			NumEnums:      0,
+			NumMessages:   17,
+			NumExtensions: 0,
+			NumServices:   1,
+		},
+		GoTypes:           file_cadview_proto_goTypes,
+		DependencyIndexes: file_cadview_proto_depIdxs,
+		MessageInfos:      file_cadview_proto_msgTypes,
+	}.Build()
+	File_cadview_proto = out.File
+	file_cadview_proto_goTypes = nil
+	file_cadview_proto_depIdxs = nil
+}
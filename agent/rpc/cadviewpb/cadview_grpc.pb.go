@@ -0,0 +1,285 @@
+// Protobuf schema for the cadview agent gRPC service. Messages mirror the
+// JSON objects in obj.go; date and time strings are passed through as the
+// CAD system formats them, like "11/13/2022 10:25:54".
+
+// Code generated by protoc-gen-go-grpc. DO NOT EDIT.
+// versions:
+// - protoc-gen-go-grpc v1.5.1
+// - protoc             v5.29.3
+// source: cadview.proto
+
+package cadviewpb
+
+import (
+	context "context"
+	grpc "google.golang.org/grpc"
+	codes "google.golang.org/grpc/codes"
+	status "google.golang.org/grpc/status"
+)
+
+// This is a compile-time assertion to ensure that this generated file
+// is compatible with the grpc package it is being compiled against.
+// Requires gRPC-Go v1.64.0 or later.
+const _ = grpc.SupportPackageIsVersion9
+
+const (
+	CadView_GetActiveCalls_FullMethodName     = "/cadview.v1.CadView/GetActiveCalls"
+	CadView_SearchClearedCalls_FullMethodName = "/cadview.v1.CadView/SearchClearedCalls"
+	CadView_GetCall_FullMethodName            = "/cadview.v1.CadView/GetCall"
+	CadView_GetORIs_FullMethodName            = "/cadview.v1.CadView/GetORIs"
+	CadView_WatchActiveCalls_FullMethodName   = "/cadview.v1.CadView/WatchActiveCalls"
+)
+
+// CadViewClient is the client API for CadView service.
+//
+// For semantics around ctx use and closing/ending streaming RPCs, please refer to https://pkg.go.dev/google.golang.org/grpc/?tab=doc#ClientConn.NewStream.
+//
+// CadView exposes the data available to an authenticated agent.
+type CadViewClient interface {
+	GetActiveCalls(ctx context.Context, in *GetActiveCallsRequest, opts ...grpc.CallOption) (*CallList, error)
+	SearchClearedCalls(ctx context.Context, in *SearchClearedCallsRequest, opts ...grpc.CallOption) (*CallList, error)
+	GetCall(ctx context.Context, in *GetCallRequest, opts ...grpc.CallOption) (*CADCall, error)
+	GetORIs(ctx context.Context, in *GetORIsRequest, opts ...grpc.CallOption) (*ORIList, error)
+	WatchActiveCalls(ctx context.Context, in *WatchActiveCallsRequest, opts ...grpc.CallOption) (grpc.ServerStreamingClient[CallEvent], error)
+}
+
+type cadViewClient struct {
+	cc grpc.ClientConnInterface
+}
+
+func NewCadViewClient(cc grpc.ClientConnInterface) CadViewClient {
+	return &cadViewClient{cc}
+}
+
+func (c *cadViewClient) GetActiveCalls(ctx context.Context, in *GetActiveCallsRequest, opts ...grpc.CallOption) (*CallList, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(CallList)
+	err := c.cc.Invoke(ctx, CadView_GetActiveCalls_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *cadViewClient) SearchClearedCalls(ctx context.Context, in *SearchClearedCallsRequest, opts ...grpc.CallOption) (*CallList, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(CallList)
+	err := c.cc.Invoke(ctx, CadView_SearchClearedCalls_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *cadViewClient) GetCall(ctx context.Context, in *GetCallRequest, opts ...grpc.CallOption) (*CADCall, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(CADCall)
+	err := c.cc.Invoke(ctx, CadView_GetCall_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *cadViewClient) GetORIs(ctx context.Context, in *GetORIsRequest, opts ...grpc.CallOption) (*ORIList, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(ORIList)
+	err := c.cc.Invoke(ctx, CadView_GetORIs_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *cadViewClient) WatchActiveCalls(ctx context.Context, in *WatchActiveCallsRequest, opts ...grpc.CallOption) (grpc.ServerStreamingClient[CallEvent], error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	stream, err := c.cc.NewStream(ctx, &CadView_ServiceDesc.Streams[0], CadView_WatchActiveCalls_FullMethodName, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	x := &grpc.GenericClientStream[WatchActiveCallsRequest, CallEvent]{ClientStream: stream}
+	if err := x.ClientStream.SendMsg(in); err != nil {
+		return nil, err
+	}
+	if err := x.ClientStream.CloseSend(); err != nil {
+		return nil, err
+	}
+	return x, nil
+}
+
+// This type alias is provided for backwards compatibility with existing code that references the prior non-generic stream type by name.
+type CadView_WatchActiveCallsClient = grpc.ServerStreamingClient[CallEvent]
+
+// CadViewServer is the server API for CadView service.
+// All implementations must embed UnimplementedCadViewServer
+// for forward compatibility.
+//
+// CadView exposes the data available to an authenticated agent.
+type CadViewServer interface {
+	GetActiveCalls(context.Context, *GetActiveCallsRequest) (*CallList, error)
+	SearchClearedCalls(context.Context, *SearchClearedCallsRequest) (*CallList, error)
+	GetCall(context.Context, *GetCallRequest) (*CADCall, error)
+	GetORIs(context.Context, *GetORIsRequest) (*ORIList, error)
+	WatchActiveCalls(*WatchActiveCallsRequest, grpc.ServerStreamingServer[CallEvent]) error
+	mustEmbedUnimplementedCadViewServer()
+}
+
+// UnimplementedCadViewServer must be embedded to have
+// forward compatible implementations.
+//
+// NOTE: this should be embedded by value instead of pointer to avoid a nil
+// pointer dereference when methods are called.
+type UnimplementedCadViewServer struct{}
+
+func (UnimplementedCadViewServer) GetActiveCalls(context.Context, *GetActiveCallsRequest) (*CallList, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method GetActiveCalls not implemented")
+}
+func (UnimplementedCadViewServer) SearchClearedCalls(context.Context, *SearchClearedCallsRequest) (*CallList, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method SearchClearedCalls not implemented")
+}
+func (UnimplementedCadViewServer) GetCall(context.Context, *GetCallRequest) (*CADCall, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method GetCall not implemented")
+}
+func (UnimplementedCadViewServer) GetORIs(context.Context, *GetORIsRequest) (*ORIList, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method GetORIs not implemented")
+}
+func (UnimplementedCadViewServer) WatchActiveCalls(*WatchActiveCallsRequest, grpc.ServerStreamingServer[CallEvent]) error {
+	return status.Errorf(codes.Unimplemented, "method WatchActiveCalls not implemented")
+}
+func (UnimplementedCadViewServer) mustEmbedUnimplementedCadViewServer() {}
+func (UnimplementedCadViewServer) testEmbeddedByValue()                 {}
+
+// UnsafeCadViewServer may be embedded to opt out of forward compatibility for this service.
+// Use of this interface is not recommended, as added methods to CadViewServer will
+// result in compilation errors.
+type UnsafeCadViewServer interface {
+	mustEmbedUnimplementedCadViewServer()
+}
+
+func RegisterCadViewServer(s grpc.ServiceRegistrar, srv CadViewServer) {
+	// If the following call pancis, it indicates UnimplementedCadViewServer was
+	// embedded by pointer and is nil.  This will cause panics if an
+	// unimplemented method is ever invoked, so we test this at initialization
+	// time to prevent it from happening at runtime later due to I/O.
+	if t, ok := srv.(interface{ testEmbeddedByValue() }); ok {
+		t.testEmbeddedByValue()
+	}
+	s.RegisterService(&CadView_ServiceDesc, srv)
+}
+
+func _CadView_GetActiveCalls_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(GetActiveCallsRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(CadViewServer).GetActiveCalls(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: CadView_GetActiveCalls_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(CadViewServer).GetActiveCalls(ctx, req.(*GetActiveCallsRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _CadView_SearchClearedCalls_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(SearchClearedCallsRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(CadViewServer).SearchClearedCalls(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: CadView_SearchClearedCalls_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(CadViewServer).SearchClearedCalls(ctx, req.(*SearchClearedCallsRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _CadView_GetCall_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(GetCallRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(CadViewServer).GetCall(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: CadView_GetCall_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(CadViewServer).GetCall(ctx, req.(*GetCallRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _CadView_GetORIs_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(GetORIsRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(CadViewServer).GetORIs(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: CadView_GetORIs_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(CadViewServer).GetORIs(ctx, req.(*GetORIsRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _CadView_WatchActiveCalls_Handler(srv interface{}, stream grpc.ServerStream) error {
+	m := new(WatchActiveCallsRequest)
+	if err := stream.RecvMsg(m); err != nil {
+		return err
+	}
+	return srv.(CadViewServer).WatchActiveCalls(m, &grpc.GenericServerStream[WatchActiveCallsRequest, CallEvent]{ServerStream: stream})
+}
+
+// This type alias is provided for backwards compatibility with existing code that references the prior non-generic stream type by name.
+type CadView_WatchActiveCallsServer = grpc.ServerStreamingServer[CallEvent]
+
+// CadView_ServiceDesc is the grpc.ServiceDesc for CadView service.
+// It's only intended for direct use with grpc.RegisterService,
+// and not to be introspected or modified (even as a copy)
+var CadView_ServiceDesc = grpc.ServiceDesc{
+	ServiceName: "cadview.v1.CadView",
+	HandlerType: (*CadViewServer)(nil),
+	Methods: []grpc.MethodDesc{
+		{
+			MethodName: "GetActiveCalls",
+			Handler:    _CadView_GetActiveCalls_Handler,
+		},
+		{
+			MethodName: "SearchClearedCalls",
+			Handler:    _CadView_SearchClearedCalls_Handler,
+		},
+		{
+			MethodName: "GetCall",
+			Handler:    _CadView_GetCall_Handler,
+		},
+		{
+			MethodName: "GetORIs",
+			Handler:    _CadView_GetORIs_Handler,
+		},
+	},
+	Streams: []grpc.StreamDesc{
+		{
+			StreamName:    "WatchActiveCalls",
+			Handler:       _CadView_WatchActiveCalls_Handler,
+			ServerStreams: true,
+		},
+	},
+	Metadata: "cadview.proto",
+}
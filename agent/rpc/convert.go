@@ -0,0 +1,154 @@
+package rpc
+
+import (
+	"github.com/dayvillefire/newworld-cadview-agent/agent"
+	"github.com/dayvillefire/newworld-cadview-agent/agent/rpc/cadviewpb"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+// CallToProto converts a CallObj to its protobuf form.
+func CallToProto(c agent.CallObj) *cadviewpb.Call {
+	return &cadviewpb.Call{
+		CallId:             c.CallID,
+		ArrivedDateTime:    c.ArrivedDateTime,
+		CallNumber:         int32(c.CallNumber),
+		CallPriority:       c.CallPriority,
+		CallSource:         c.CallSource,
+		CallStatus:         c.CallStatus,
+		CallType:           c.CallType,
+		CallTypeId:         int32(c.CallTypeID),
+		CommonName:         c.CommonName,
+		ClosedFlag:         c.ClosedFlag,
+		CreatedDateTime:    c.CreatedDateTime,
+		DispatchedDateTime: c.DispatchedDateTime,
+		FireCallType:       c.FireCallType,
+		FireCallTypeId:     c.FireCallTypeID,
+		IncidentNumber:     c.IncidentNumber,
+		LatitudeY:          c.LatitudeY,
+		LongitudeX:         c.LongitudeX,
+		Location:           c.Location,
+		NatureOfCall:       c.NatureOfCall,
+		PrimaryUnit:        c.PrimaryUnit,
+		Quadrant:           c.Quadrant,
+		AllowedOri:         c.AllowedORI,
+		ForegroundR:        int32(c.ForegroundR),
+		ForegroundG:        int32(c.ForegroundG),
+		ForegroundB:        int32(c.ForegroundB),
+		Zones:              c.Zones,
+	}
+}
+
+// UnitToProto converts a UnitObj to its protobuf form.
+func UnitToProto(u agent.UnitObj) *cadviewpb.Unit {
+	return &cadviewpb.Unit{
+		CallId:                 u.CallID,
+		Id:                     u.ID,
+		Ori:                    u.ORI,
+		UnitNumber:             u.UnitNumber,
+		DispatchDateTime:       u.DispatchDateTime,
+		EnrouteDateTime:        u.EnrouteDateTime,
+		StagedDateTime:         u.StagedDateTime,
+		AtPatientDateTime:      u.AtPatientDateTime,
+		ArriveDateTime:         u.ArriveDateTime,
+		TransportDateTime:      u.TransportDateTime,
+		AtHospitalDateTime:     u.AtHospitalDateTime,
+		DepartHospitalDateTime: u.DepartHospitalDateTime,
+		ClearDateTime:          u.ClearDateTime,
+	}
+}
+
+// NarrativeToProto converts a NarrativeObj to its protobuf form.
+func NarrativeToProto(n agent.NarrativeObj) *cadviewpb.Narrative {
+	return &cadviewpb.Narrative{
+		CallId:        n.CallID,
+		Id:            n.ID,
+		Narrative:     n.Narrative,
+		EnteredDate:   n.EnteredDate,
+		FirstName:     n.FirstName,
+		LastName:      n.LastName,
+		Machine:       n.Machine,
+		NarrativeType: n.NarrativeType,
+	}
+}
+
+// CADCallToProto converts a CADCall and all of its records to protobuf form.
+func CADCallToProto(c agent.CADCall) *cadviewpb.CADCall {
+	out := &cadviewpb.CADCall{Call: CallToProto(c.Call)}
+	for _, l := range c.Logs {
+		out.Logs = append(out.Logs, &cadviewpb.CallLog{
+			CallId:            l.CallID,
+			Id:                l.ID,
+			LogDateTime:       l.LogDateTime,
+			ActionDescription: l.ActionDescription,
+			Description:       l.Description,
+			FirstName:         l.FirstName,
+			LastName:          l.LastName,
+			Machine:           l.Machine,
+		})
+	}
+	for _, i := range c.Incidents {
+		out.Incidents = append(out.Incidents, &cadviewpb.Incident{
+			CallId:         i.CallID,
+			Id:             i.ID,
+			IncidentNumber: i.IncidentNumber,
+			Ori:            i.ORI,
+			Department:     i.Department,
+			Abbreviation:   i.Abbreviation,
+			AgencyType:     i.AgencyType,
+		})
+	}
+	for _, n := range c.Narratives {
+		out.Narratives = append(out.Narratives, NarrativeToProto(n))
+	}
+	for _, u := range c.Units {
+		out.Units = append(out.Units, UnitToProto(u))
+	}
+	for _, l := range c.UnitLogs {
+		out.UnitLogs = append(out.UnitLogs, &cadviewpb.UnitLog{
+			CallId:      l.CallID,
+			Id:          l.ID,
+			LogDateTime: l.LogDateTime,
+			Action:      l.Action,
+			Description: l.Description,
+			UnitNumber:  l.UnitNumber,
+			Status:      l.Status,
+			FirstName:   l.FirstName,
+			LastName:    l.LastName,
+			Machine:     l.Machine,
+		})
+	}
+	return out
+}
+
+// EventToProto converts a watcher Event to its protobuf form.
+func EventToProto(id uint64, ev agent.Event) *cadviewpb.CallEvent {
+	out := &cadviewpb.CallEvent{
+		Id:             id,
+		Type:           string(ev.Type),
+		Time:           timestamppb.New(ev.Time),
+		Call:           CallToProto(ev.Call),
+		Status:         ev.Status,
+		PreviousStatus: ev.PreviousStatus,
+	}
+	for _, u := range ev.Units {
+		out.Units = append(out.Units, UnitToProto(u))
+	}
+	for _, n := range ev.Narratives {
+		out.Narratives = append(out.Narratives, NarrativeToProto(n))
+	}
+	if ev.Unit != nil {
+		out.Unit = UnitToProto(*ev.Unit)
+	}
+	if ev.Narrative != nil {
+		out.Narrative = NarrativeToProto(*ev.Narrative)
+	}
+	if ev.Alert != nil {
+		out.Alert = &cadviewpb.Alert{
+			Rule:   ev.Alert.Rule,
+			Source: ev.Alert.Source,
+			Field:  ev.Alert.Field,
+			Match:  ev.Alert.Match,
+		}
+	}
+	return out
+}
@@ -0,0 +1,246 @@
+// Package rpc serves the agent over gRPC using the schema in cadview.proto.
+package rpc
+
+//go:generate protoc --go_out=cadviewpb --go_opt=paths=source_relative --go-grpc_out=cadviewpb --go-grpc_opt=paths=source_relative cadview.proto
+
+import (
+	"context"
+	"errors"
+	"log"
+	"net"
+	"strings"
+	"time"
+
+	"github.com/dayvillefire/newworld-cadview-agent/agent"
+	"github.com/dayvillefire/newworld-cadview-agent/agent/rpc/cadviewpb"
+	"github.com/dayvillefire/newworld-cadview-agent/agent/server"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+// Server implements cadviewpb.CadViewServer backed by an Agent.
+type Server struct {
+	cadviewpb.UnimplementedCadViewServer
+
+	// Agent is the initialized agent used for all requests.
+	Agent *agent.Agent
+	// Feed provides live events for WatchActiveCalls. It is shared with
+	// the HTTP server so that there is a single upstream poll loop.
+	Feed *server.Feed
+	// APIKeys are the keys accepted in the "x-api-key" request metadata.
+	// If empty, requests are not authenticated.
+	APIKeys []string
+}
+
+// ListenAndServe serves gRPC requests on addr until an error occurs.
+func (s *Server) ListenAndServe(addr string) error {
+	ln, err := net.Listen("tcp", addr)
+	if err != nil {
+		return err
+	}
+	if len(s.APIKeys) == 0 {
+		log.Printf("WARN: No API keys configured, gRPC requests will not be authenticated")
+	}
+	log.Printf("INFO: gRPC listening on %s", addr)
+	return s.NewGRPCServer().Serve(ln)
+}
+
+// NewGRPCServer returns a grpc.Server with the service and API key
+// authentication registered.
+func (s *Server) NewGRPCServer(opts ...grpc.ServerOption) *grpc.Server {
+	opts = append(opts,
+		grpc.UnaryInterceptor(func(ctx context.Context, req any, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (any, error) {
+			if err := s.authorize(ctx); err != nil {
+				return nil, err
+			}
+			return handler(ctx, req)
+		}),
+		grpc.StreamInterceptor(func(srv any, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+			if err := s.authorize(ss.Context()); err != nil {
+				return err
+			}
+			return handler(srv, ss)
+		}),
+	)
+	g := grpc.NewServer(opts...)
+	cadviewpb.RegisterCadViewServer(g, s)
+	return g
+}
+
+func (s *Server) authorize(ctx context.Context) error {
+	if len(s.APIKeys) == 0 {
+		return nil
+	}
+	md, _ := metadata.FromIncomingContext(ctx)
+	for _, key := range md.Get("x-api-key") {
+		if server.ValidKey(s.APIKeys, key) {
+			return nil
+		}
+	}
+	return status.Error(codes.Unauthenticated, "invalid api key")
+}
+
+// call runs fn against the agent, logging in again and retrying once if
+// the session is no longer authorized. Errors other than gRPC status errors
+// are reported as unavailable.
+func (s *Server) call(fn func() error) error {
+	start := time.Now()
+	err := fn()
+	if errors.Is(err, agent.ErrNotAuthorized) {
+		if err = s.Agent.ReauthorizeSince(start); err == nil {
+			err = fn()
+		}
+	}
+	if _, ok := status.FromError(err); err != nil && !ok {
+		return status.Error(codes.Unavailable, err.Error())
+	}
+	return err
+}
+
+func (s *Server) GetActiveCalls(ctx context.Context, req *cadviewpb.GetActiveCallsRequest) (*cadviewpb.CallList, error) {
+	var calls []agent.CallObj
+	err := s.call(func() error {
+		var err error
+		calls, err = s.Agent.GetActiveCalls()
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	out := &cadviewpb.CallList{}
+	for _, c := range calls {
+		if server.MatchORI(c, req.GetOris()) {
+			out.Calls = append(out.Calls, CallToProto(c))
+		}
+	}
+	return out, nil
+}
+
+func (s *Server) SearchClearedCalls(ctx context.Context, req *cadviewpb.SearchClearedCallsRequest) (*cadviewpb.CallList, error) {
+	to := time.Now()
+	from := to.Add(-24 * time.Hour)
+	if req.GetTo() != nil {
+		to = req.GetTo().AsTime().Local()
+	}
+	if req.GetFrom() != nil {
+		from = req.GetFrom().AsTime().Local()
+	}
+	if !from.Before(to) {
+		return nil, status.Error(codes.InvalidArgument, "from must be before to")
+	}
+
+	var calls []agent.CallObj
+	err := s.call(func() error {
+		oris, err := s.Agent.GetORIs()
+		if err != nil {
+			return err
+		}
+		ori := req.GetOri()
+		if ori == "" {
+			ori = s.Agent.FDID
+		}
+		if fdidOri := agent.FDIDToORI(oris, ori); fdidOri != "" {
+			ori = fdidOri
+		}
+		ok, err := s.Agent.Permits(ori)
+		if err != nil {
+			return err
+		}
+		if !ok {
+			return status.Errorf(codes.PermissionDenied, "ori %s not permitted", ori)
+		}
+		calls, err = s.Agent.GetClearedCalls(from, to, ori)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	out := &cadviewpb.CallList{}
+	for _, c := range calls {
+		if req.GetIncidentNumber() != "" && c.IncidentNumber != req.GetIncidentNumber() {
+			continue
+		}
+		if req.GetCallType() != "" && !strings.EqualFold(c.CallType, req.GetCallType()) && !strings.EqualFold(c.FireCallType, req.GetCallType()) {
+			continue
+		}
+		out.Calls = append(out.Calls, CallToProto(c))
+	}
+	return out, nil
+}
+
+func (s *Server) GetCall(ctx context.Context, req *cadviewpb.GetCallRequest) (*cadviewpb.CADCall, error) {
+	if req.GetCallId() <= 0 {
+		return nil, status.Error(codes.InvalidArgument, "call_id is required")
+	}
+	var cad agent.CADCall
+	err := s.call(func() error {
+		var err error
+		cad, err = s.Agent.RetrieveCADCall(agent.CallObj{CallID: req.GetCallId()})
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return CADCallToProto(cad), nil
+}
+
+func (s *Server) GetORIs(ctx context.Context, req *cadviewpb.GetORIsRequest) (*cadviewpb.ORIList, error) {
+	var oris []agent.ORIObj
+	err := s.call(func() error {
+		var err error
+		oris, err = s.Agent.GetORIs()
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	out := &cadviewpb.ORIList{}
+	for _, o := range oris {
+		out.Oris = append(out.Oris, &cadviewpb.ORI{Ori: o.ORI, Fdid: o.FDID, AgencyName: o.AgencyName})
+	}
+	return out, nil
+}
+
+func (s *Server) WatchActiveCalls(req *cadviewpb.WatchActiveCallsRequest, stream cadviewpb.CadView_WatchActiveCallsServer) error {
+	if s.Feed == nil {
+		return status.Error(codes.Unimplemented, "live events are not enabled")
+	}
+	sub, replay, snapshot := s.Feed.Subscribe(req.GetOris(), req.GetLastEventId())
+	defer s.Feed.Unsubscribe(sub)
+
+	if snapshot {
+		snap := s.Feed.Snapshot(sub)
+		for _, c := range snap.Calls {
+			ev := &cadviewpb.CallEvent{
+				Type: string(server.EventSnapshot),
+				Time: timestamppb.New(snap.Time),
+				Call: CallToProto(c),
+			}
+			if err := stream.Send(ev); err != nil {
+				return err
+			}
+		}
+	}
+	for _, fe := range replay {
+		if err := stream.Send(EventToProto(fe.ID, fe.Event)); err != nil {
+			return err
+		}
+	}
+	for {
+		select {
+		case <-stream.Context().Done():
+			return nil
+		case fe, ok := <-sub.Events():
+			if !ok {
+				return status.Error(codes.ResourceExhausted, "client fell behind")
+			}
+			if err := stream.Send(EventToProto(fe.ID, fe.Event)); err != nil {
+				return err
+			}
+		}
+	}
+}
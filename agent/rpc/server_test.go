@@ -0,0 +1,84 @@
+package rpc
+
+import (
+	"context"
+	"encoding/json"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/dayvillefire/newworld-cadview-agent/agent"
+	"github.com/dayvillefire/newworld-cadview-agent/agent/rpc/cadviewpb"
+	"github.com/dayvillefire/newworld-cadview-agent/agent/server"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"google.golang.org/grpc/test/bufconn"
+)
+
+func Test_Server_RPC(t *testing.T) {
+	cad := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode([]agent.CallObj{
+			{CallID: 591039, IncidentNumber: "2022-00000345", AllowedORI: []string{"04040"}},
+			{CallID: 591040, AllowedORI: []string{"04090"}},
+		})
+	}))
+	defer cad.Close()
+	a := &agent.Agent{BaseUrl: cad.URL + "/"}
+	a.SetAuth(agent.OidcObj{TokenType: "Bearer", AccessToken: "test"})
+
+	feed := &server.Feed{}
+	s := &Server{Agent: a, Feed: feed, APIKeys: []string{"secret"}}
+	ln := bufconn.Listen(1 << 20)
+	g := s.NewGRPCServer()
+	go g.Serve(ln)
+	defer g.Stop()
+
+	conn, err := grpc.NewClient("passthrough:///bufnet",
+		grpc.WithContextDialer(func(ctx context.Context, _ string) (net.Conn, error) { return ln.DialContext(ctx) }),
+		grpc.WithTransportCredentials(insecure.NewCredentials()),
+	)
+	if err != nil {
+		t.Fatalf("ERR: NewClient: %s", err.Error())
+	}
+	defer conn.Close()
+	client := cadviewpb.NewCadViewClient(conn)
+
+	_, err = client.GetActiveCalls(context.Background(), &cadviewpb.GetActiveCallsRequest{})
+	if status.Code(err) != codes.Unauthenticated {
+		t.Fatalf("expected Unauthenticated, got %v", err)
+	}
+
+	ctx := metadata.AppendToOutgoingContext(context.Background(), "x-api-key", "secret")
+	list, err := client.GetActiveCalls(ctx, &cadviewpb.GetActiveCallsRequest{Oris: []string{"04040"}})
+	if err != nil {
+		t.Fatalf("ERR: GetActiveCalls: %s", err.Error())
+	}
+	if len(list.GetCalls()) != 1 || list.GetCalls()[0].GetIncidentNumber() != "2022-00000345" {
+		t.Fatalf("unexpected calls %v", list.GetCalls())
+	}
+
+	// Searches are limited to the agencies the user is permitted
+	_, err = client.SearchClearedCalls(ctx, &cadviewpb.SearchClearedCallsRequest{Ori: "04040"})
+	if status.Code(err) != codes.PermissionDenied {
+		t.Fatalf("expected PermissionDenied, got %v", err)
+	}
+
+	// Events after last_event_id are replayed
+	feed.Publish(agent.Event{Type: agent.EventNewCall, Call: agent.CallObj{CallID: 1}})
+	feed.Publish(agent.Event{Type: agent.EventCallClosed, Call: agent.CallObj{CallID: 1}})
+	stream, err := client.WatchActiveCalls(ctx, &cadviewpb.WatchActiveCallsRequest{LastEventId: 1})
+	if err != nil {
+		t.Fatalf("ERR: WatchActiveCalls: %s", err.Error())
+	}
+	ev, err := stream.Recv()
+	if err != nil {
+		t.Fatalf("ERR: Recv: %s", err.Error())
+	}
+	if ev.GetType() != string(agent.EventCallClosed) || ev.GetId() != 2 {
+		t.Fatalf("unexpected event %v", ev)
+	}
+}
@@ -0,0 +1,165 @@
+package server
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/dayvillefire/newworld-cadview-agent/agent"
+)
+
+// queryDateFormats are the accepted formats for the from and to parameters.
+var queryDateFormats = []string{
+	time.RFC3339,
+	"2006-01-02T15:04:05",
+	"2006-01-02",
+}
+
+func parseQueryDate(s string) (time.Time, error) {
+	for _, f := range queryDateFormats {
+		if t, err := time.ParseInLocation(f, s, time.Local); err == nil {
+			return t, nil
+		}
+	}
+	return time.Time{}, fmt.Errorf("invalid date %q", s)
+}
+
+func (s *Server) handleActiveCalls(w http.ResponseWriter, r *http.Request) {
+	body, err := s.cached("active", func() (any, error) {
+		return s.Agent.GetActiveCalls()
+	})
+	if err != nil {
+		writeError(w, http.StatusBadGateway, err)
+		return
+	}
+	writeJSON(w, body)
+}
+
+// handleClearedCalls serves /calls/cleared?from&to&ori. from and to default
+// to the last 24 hours, to the minute, and ori accepts either a CAD ORI or an FDID,
+// defaulting to the FDID of the agent.
+func (s *Server) handleClearedCalls(w http.ResponseWriter, r *http.Request) {
+	q := r.URL.Query()
+	from, to := defaultClearedWindow(time.Now())
+	var err error
+	if v := q.Get("from"); v != "" {
+		if from, err = parseQueryDate(v); err != nil {
+			writeError(w, http.StatusBadRequest, err)
+			return
+		}
+	}
+	if v := q.Get("to"); v != "" {
+		if to, err = parseQueryDate(v); err != nil {
+			writeError(w, http.StatusBadRequest, err)
+			return
+		}
+	}
+	if !from.Before(to) {
+		writeError(w, http.StatusBadRequest, errors.New("from must be before to"))
+		return
+	}
+
+	ori, err := s.resolveORI(q.Get("ori"))
+	if errors.Is(err, errORINotPermitted) {
+		writeError(w, http.StatusForbidden, err)
+		return
+	}
+	if err != nil {
+		writeError(w, http.StatusBadGateway, err)
+		return
+	}
+	if ori == "" {
+		writeError(w, http.StatusBadRequest, errors.New("unknown ori"))
+		return
+	}
+
+	key := fmt.Sprintf("cleared:%d:%d:%s", from.Unix(), to.Unix(), ori)
+	body, err := s.cached(key, func() (any, error) {
+		return s.Agent.GetClearedCalls(from, to, ori)
+	})
+	if err != nil {
+		writeError(w, http.StatusBadGateway, err)
+		return
+	}
+	writeJSON(w, body)
+}
+
+// defaultClearedWindow returns the 24 hours ending at the minute after now,
+// so that repeated searches without from or to share a cache entry.
+func defaultClearedWindow(now time.Time) (time.Time, time.Time) {
+	to := now.Truncate(time.Minute).Add(time.Minute)
+	return to.Add(-24 * time.Hour), to
+}
+
+// errORINotPermitted is returned by resolveORI for agencies the logged in
+// user may not search.
+var errORINotPermitted = errors.New("ori not permitted")
+
+// resolveORI converts an FDID to an ORI, passing through values which are
+// already ORIs. An empty ORI is returned for unknown values.
+func (s *Server) resolveORI(v string) (string, error) {
+	if v == "" {
+		v = s.Agent.FDID
+	}
+	oris, err := s.oris()
+	if err != nil {
+		return "", err
+	}
+	ori := agent.FDIDToORI(oris, v)
+	for _, o := range oris {
+		if o.ORI == v {
+			ori = v
+			break
+		}
+	}
+	if ori == "" {
+		return "", nil
+	}
+	ok, err := s.Agent.Permits(ori)
+	if err != nil {
+		return "", err
+	}
+	if !ok {
+		return "", fmt.Errorf("%w: %s", errORINotPermitted, v)
+	}
+	return ori, nil
+}
+
+// oris returns the ORIs from the same cache entry as /oris.
+func (s *Server) oris() ([]agent.ORIObj, error) {
+	var oris []agent.ORIObj
+	_, err := s.cachedValue("oris", &oris, func() (any, error) {
+		return s.Agent.GetORIs()
+	})
+	return oris, err
+}
+
+// handleCall serves /calls/{id}, returning the full CADCall for a call ID.
+func (s *Server) handleCall(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.ParseInt(r.PathValue("id"), 10, 64)
+	if err != nil || id <= 0 {
+		writeError(w, http.StatusBadRequest, errors.New("invalid call id"))
+		return
+	}
+	body, err := s.cached(fmt.Sprintf("call:%d", id), func() (any, error) {
+		return s.Agent.RetrieveCADCall(agent.CallObj{CallID: id})
+	})
+	if err != nil {
+		writeError(w, http.StatusBadGateway, err)
+		return
+	}
+	writeJSON(w, body)
+}
+
+func (s *Server) handleORIs(w http.ResponseWriter, r *http.Request) {
+	body, err := s.cached("oris", func() (any, error) {
+		return s.Agent.GetORIs()
+	})
+	if err != nil {
+		writeError(w, http.StatusBadGateway, err)
+		return
+	}
+	writeJSON(w, body)
+}
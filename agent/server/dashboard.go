@@ -0,0 +1,166 @@
+package server
+
+import (
+	"embed"
+	"fmt"
+	"html/template"
+	"log"
+	"net/http"
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/dayvillefire/newworld-cadview-agent/agent"
+)
+
+//go:embed templates/*.html
+var templateFS embed.FS
+
+var dashboardTemplate = template.Must(template.New("dashboard.html").Funcs(template.FuncMap{
+	"age": formatAge,
+}).ParseFS(templateFS, "templates/dashboard.html"))
+
+type dashboardUnit struct {
+	UnitNumber string
+	Status     string
+	Time       string
+}
+
+type dashboardCall struct {
+	Call agent.CallObj
+	// Created is the creation time in Unix milliseconds, for age timers.
+	Created int64
+	Age     time.Duration
+	// Color is the CadView priority color as a CSS color.
+	Color template.CSS
+	Units []dashboardUnit
+}
+
+type dashboardPage struct {
+	Board   bool
+	Refresh int
+	Updated time.Time
+	Error   string
+	Calls   []dashboardCall
+}
+
+// handleDashboard renders the active incident dashboard. ?board=1 selects
+// the station bay big board layout, and ?ori filters calls.
+func (s *Server) handleDashboard(w http.ResponseWriter, r *http.Request) {
+	q := r.URL.Query()
+	page := dashboardPage{
+		Board:   q.Get("board") != "" && q.Get("board") != "0",
+		Refresh: 15,
+		Updated: time.Now(),
+		Calls:   []dashboardCall{},
+	}
+	oris, _ := feedParams(r)
+
+	calls, err := s.activeCalls()
+	if err != nil {
+		log.Printf("ERR: dashboard: %s", err.Error())
+		page.Error = err.Error()
+	}
+	for _, c := range calls {
+		if !MatchORI(c, oris) {
+			continue
+		}
+		dc := dashboardCall{Call: c, Color: callColor(c)}
+		if t, err := agent.ParseDateTime(c.CreatedDateTime); err == nil {
+			dc.Created = t.UnixMilli()
+			dc.Age = time.Since(t)
+		}
+		dc.Units = s.dashboardUnits(c)
+		page.Calls = append(page.Calls, dc)
+	}
+	sort.Slice(page.Calls, func(i, j int) bool {
+		return page.Calls[i].Created > page.Calls[j].Created
+	})
+
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	if err = dashboardTemplate.Execute(w, page); err != nil {
+		log.Printf("ERR: dashboard: %s", err.Error())
+	}
+}
+
+// activeCalls uses the feed's watcher if there is one, rather than polling
+// separately.
+func (s *Server) activeCalls() ([]agent.CallObj, error) {
+	if s.Feed != nil && s.Feed.Watcher != nil {
+		return s.Feed.Watcher.Calls(), nil
+	}
+	var calls []agent.CallObj
+	_, err := s.call(func() (any, error) {
+		var err error
+		calls, err = s.Agent.GetActiveCalls()
+		return calls, err
+	})
+	return calls, err
+}
+
+// dashboardUnits returns the units assigned to a call with the status of
+// their latest unit log entry, falling back to the unit timestamps.
+func (s *Server) dashboardUnits(c agent.CallObj) []dashboardUnit {
+	callId := strconv.FormatInt(c.CallID, 10)
+	var units []agent.UnitObj
+	var logs []agent.UnitLogObj
+	_, err := s.cachedValue("units:"+callId, &units, func() (any, error) {
+		return s.Agent.GetCallUnits(callId)
+	})
+	if err != nil {
+		log.Printf("ERR: dashboard: GetCallUnits(%s): %s", callId, err.Error())
+	}
+	_, err = s.cachedValue("unitlogs:"+callId, &logs, func() (any, error) {
+		return s.Agent.GetCallUnitLogs(callId)
+	})
+	if err != nil {
+		log.Printf("ERR: dashboard: GetCallUnitLogs(%s): %s", callId, err.Error())
+	}
+
+	latest := map[string]agent.UnitLogObj{}
+	latestAt := map[string]time.Time{}
+	for _, l := range logs {
+		t, err := agent.ParseDateTime(l.LogDateTime)
+		if err != nil || l.Status == "" {
+			continue
+		}
+		if prev, ok := latestAt[l.UnitNumber]; !ok || !t.Before(prev) {
+			latest[l.UnitNumber] = l
+			latestAt[l.UnitNumber] = t
+		}
+	}
+
+	out := []dashboardUnit{}
+	for _, u := range units {
+		du := dashboardUnit{UnitNumber: u.UnitNumber, Status: agent.UnitStatus(u)}
+		if l, ok := latest[u.UnitNumber]; ok {
+			du.Status = l.Status
+			du.Time = l.LogDateTime
+		}
+		if du.Status == agent.UnitStatusCleared {
+			continue
+		}
+		out = append(out, du)
+	}
+	return out
+}
+
+// callColor converts the CadView foreground color of a call to CSS. It is
+// built from clamped integers only, so it is safe to mark as CSS.
+func callColor(c agent.CallObj) template.CSS {
+	if c.ForegroundR == 0 && c.ForegroundG == 0 && c.ForegroundB == 0 {
+		return ""
+	}
+	channel := func(v int) int {
+		return min(max(v, 0), 255)
+	}
+	return template.CSS(fmt.Sprintf("rgb(%d,%d,%d)", channel(c.ForegroundR), channel(c.ForegroundG), channel(c.ForegroundB)))
+}
+
+func formatAge(d time.Duration) string {
+	d = d.Truncate(time.Second)
+	if d < 0 {
+		d = 0
+	}
+	return fmt.Sprintf("%d:%02d:%02d", int(d.Hours()), int(d.Minutes())%60, int(d.Seconds())%60)
+}
@@ -0,0 +1,340 @@
+package server
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/dayvillefire/newworld-cadview-agent/agent"
+	"github.com/gorilla/websocket"
+)
+
+// EventSnapshot is sent to newly connected clients which are not resuming,
+// or cannot resume, holding the current active call list.
+const EventSnapshot agent.EventType = "snapshot"
+
+// Feed fans a single Watcher out to any number of Server-Sent Events and
+// WebSocket clients, keeping recent events so clients can resume.
+type Feed struct {
+	// Watcher is the upstream poll loop. The feed subscribes to it when
+	// it is started or first used.
+	Watcher *agent.Watcher
+	// Buffer is the number of recent events kept for resuming clients.
+	// Defaults to 1000.
+	Buffer int
+	// Heartbeat is the interval between keepalive messages. Defaults to
+	// 15 seconds.
+	Heartbeat time.Duration
+
+	events  []FeedEvent
+	lastID  uint64
+	clients map[*Subscription]bool
+	once    sync.Once
+	l       sync.Mutex
+}
+
+// FeedEvent is an Event with its position in the feed.
+type FeedEvent struct {
+	ID    uint64      `json:"id"`
+	Type  string      `json:"type"`
+	Event agent.Event `json:"event"`
+}
+
+// SnapshotEvent is the payload of a snapshot message.
+type SnapshotEvent struct {
+	Type  agent.EventType `json:"type"`
+	Time  time.Time       `json:"time"`
+	Calls []agent.CallObj `json:"calls"`
+}
+
+// Subscription is a single client of a Feed.
+type Subscription struct {
+	oris []string
+	ch   chan FeedEvent
+}
+
+// Events delivers the events for the client. It is closed when the client
+// unsubscribes or is dropped for falling behind.
+func (c *Subscription) Events() <-chan FeedEvent {
+	return c.ch
+}
+
+func (f *Feed) init() {
+	f.once.Do(func() {
+		f.clients = map[*Subscription]bool{}
+		if f.Watcher != nil {
+			f.Watcher.Subscribe(f.Publish)
+		}
+	})
+}
+
+// Start subscribes the feed to its Watcher and starts the Watcher polling.
+func (f *Feed) Start() {
+	f.init()
+	f.Watcher.Start()
+}
+
+// Publish records an event and delivers it to every connected client.
+// Clients which cannot keep up are disconnected.
+func (f *Feed) Publish(ev agent.Event) {
+	f.init()
+	buffer := f.Buffer
+	if buffer <= 0 {
+		buffer = 1000
+	}
+
+	f.l.Lock()
+	defer f.l.Unlock()
+	f.lastID++
+	fe := FeedEvent{ID: f.lastID, Type: string(ev.Type), Event: ev}
+	f.events = append(f.events, fe)
+	if len(f.events) > buffer {
+		f.events = f.events[len(f.events)-buffer:]
+	}
+	for c := range f.clients {
+		if !c.wants(ev) {
+			continue
+		}
+		select {
+		case c.ch <- fe:
+		default:
+			log.Printf("WARN: Feed: dropping slow client")
+			delete(f.clients, c)
+			close(c.ch)
+		}
+	}
+}
+
+// Subscribe registers a client with an ORI filter, returning the buffered
+// events after lastID which it should be sent first. If the buffer no
+// longer holds every event after lastID, or lastID is from before the feed
+// started, snapshot is set and the client should be sent a Snapshot
+// instead. The client must read from Events and call Unsubscribe when it is
+// done.
+func (f *Feed) Subscribe(oris []string, lastID uint64) (c *Subscription, replay []FeedEvent, snapshot bool) {
+	f.init()
+	c = &Subscription{oris: oris, ch: make(chan FeedEvent, 64)}
+	replay = []FeedEvent{}
+
+	f.l.Lock()
+	defer f.l.Unlock()
+	first := f.lastID + 1
+	if len(f.events) > 0 {
+		first = f.events[0].ID
+	}
+	if lastID == 0 || lastID > f.lastID || lastID+1 < first {
+		snapshot = true
+	} else {
+		for _, fe := range f.events {
+			if fe.ID > lastID && c.wants(fe.Event) {
+				replay = append(replay, fe)
+			}
+		}
+	}
+	f.clients[c] = true
+	return c, replay, snapshot
+}
+
+// Unsubscribe removes a client registered with Subscribe.
+func (f *Feed) Unsubscribe(c *Subscription) {
+	f.l.Lock()
+	defer f.l.Unlock()
+	if f.clients[c] {
+		delete(f.clients, c)
+		close(c.ch)
+	}
+}
+
+// Snapshot returns the current active calls visible to a client.
+func (f *Feed) Snapshot(c *Subscription) SnapshotEvent {
+	out := SnapshotEvent{Type: EventSnapshot, Time: time.Now(), Calls: []agent.CallObj{}}
+	if f.Watcher == nil {
+		return out
+	}
+	for _, call := range f.Watcher.Calls() {
+		if c.wants(agent.Event{Call: call}) {
+			out.Calls = append(out.Calls, call)
+		}
+	}
+	return out
+}
+
+// wants determines whether an event is visible to a client with an ORI
+// filter. AllowedORI entries look like "04040-561" or "04090", and match a
+// filter of "04040" or "04040-561".
+func (c *Subscription) wants(ev agent.Event) bool {
+	return MatchORI(ev.Call, c.oris)
+}
+
+// MatchORI determines whether a call is allowed for any of a list of
+// FDIDs or ORIs. An empty list matches every call.
+func MatchORI(call agent.CallObj, oris []string) bool {
+	if len(oris) == 0 {
+		return true
+	}
+	for _, allowed := range call.AllowedORI {
+		for _, o := range oris {
+			if allowed == o || strings.HasPrefix(allowed, o+"-") {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// feedParams reads the ori filter and resume position from a request.
+func feedParams(r *http.Request) ([]string, uint64) {
+	oris := []string{}
+	for _, v := range r.URL.Query()["ori"] {
+		for _, o := range strings.Split(v, ",") {
+			if o = strings.TrimSpace(o); o != "" {
+				oris = append(oris, o)
+			}
+		}
+	}
+	last := r.Header.Get("Last-Event-ID")
+	if last == "" {
+		last = r.URL.Query().Get("lastEventId")
+	}
+	lastID, _ := strconv.ParseUint(last, 10, 64)
+	return oris, lastID
+}
+
+func (f *Feed) heartbeat() time.Duration {
+	if f.Heartbeat <= 0 {
+		return 15 * time.Second
+	}
+	return f.Heartbeat
+}
+
+// ServeSSE streams events as Server-Sent Events. Clients may filter with
+// ?ori=04040,04090 and resume with the Last-Event-ID header or the
+// lastEventId parameter.
+func (f *Feed) ServeSSE(w http.ResponseWriter, r *http.Request) {
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		writeError(w, http.StatusInternalServerError, fmt.Errorf("streaming not supported"))
+		return
+	}
+	oris, lastID := feedParams(r)
+	c, replay, snapshot := f.Subscribe(oris, lastID)
+	defer f.Unsubscribe(c)
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("Connection", "keep-alive")
+	w.Header().Set("X-Accel-Buffering", "no")
+
+	if snapshot {
+		data, _ := json.Marshal(f.Snapshot(c))
+		fmt.Fprintf(w, "event: %s\ndata: %s\n\n", EventSnapshot, data)
+	}
+	for _, fe := range replay {
+		writeSSE(w, fe)
+	}
+	flusher.Flush()
+
+	ticker := time.NewTicker(f.heartbeat())
+	defer ticker.Stop()
+	for {
+		select {
+		case <-r.Context().Done():
+			return
+		case fe, ok := <-c.ch:
+			if !ok {
+				return
+			}
+			writeSSE(w, fe)
+			flusher.Flush()
+		case <-ticker.C:
+			fmt.Fprintf(w, ": heartbeat %d\n\n", time.Now().Unix())
+			flusher.Flush()
+		}
+	}
+}
+
+func writeSSE(w http.ResponseWriter, fe FeedEvent) {
+	data, err := json.Marshal(fe.Event)
+	if err != nil {
+		log.Printf("ERR: Feed: %s", err.Error())
+		return
+	}
+	fmt.Fprintf(w, "id: %d\nevent: %s\ndata: %s\n\n", fe.ID, fe.Type, data)
+}
+
+var upgrader = websocket.Upgrader{
+	ReadBufferSize:  1024,
+	WriteBufferSize: 4096,
+	// Browser dashboards may be served from other origins; access is
+	// controlled by API key instead.
+	CheckOrigin: func(r *http.Request) bool { return true },
+}
+
+// ServeWebSocket streams events as JSON WebSocket messages of the form
+// {"id":1,"type":"new_call","event":{...}}, with the same filter and resume
+// parameters as ServeSSE.
+func (f *Feed) ServeWebSocket(w http.ResponseWriter, r *http.Request) {
+	oris, lastID := feedParams(r)
+	conn, err := upgrader.Upgrade(w, r, nil)
+	if err != nil {
+		log.Printf("ERR: Feed: upgrade: %s", err.Error())
+		return
+	}
+	defer conn.Close()
+
+	c, replay, snapshot := f.Subscribe(oris, lastID)
+	defer f.Unsubscribe(c)
+
+	// Reads are only needed to process control frames and notice closes
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		for {
+			if _, _, err := conn.ReadMessage(); err != nil {
+				return
+			}
+		}
+	}()
+
+	write := func(v any) error {
+		conn.SetWriteDeadline(time.Now().Add(10 * time.Second))
+		return conn.WriteJSON(v)
+	}
+
+	if snapshot {
+		if err = write(f.Snapshot(c)); err != nil {
+			return
+		}
+	}
+	for _, fe := range replay {
+		if err = write(fe); err != nil {
+			return
+		}
+	}
+
+	ticker := time.NewTicker(f.heartbeat())
+	defer ticker.Stop()
+	for {
+		select {
+		case <-done:
+			return
+		case fe, ok := <-c.ch:
+			if !ok {
+				return
+			}
+			if err = write(fe); err != nil {
+				return
+			}
+		case <-ticker.C:
+			err = conn.WriteControl(websocket.PingMessage, []byte{}, time.Now().Add(10*time.Second))
+			if err != nil {
+				return
+			}
+		}
+	}
+}
@@ -0,0 +1,89 @@
+package server
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/dayvillefire/newworld-cadview-agent/agent"
+)
+
+// readyInterval is how often /readyz checks the session with CadView.
+const readyInterval = 30 * time.Second
+
+// HealthReport is the response of /healthz and /readyz.
+type HealthReport struct {
+	Status   string       `json:"status"`
+	Problems []string     `json:"problems,omitempty"`
+	Agent    agent.Status `json:"agent"`
+}
+
+// readyCheck limits how often /readyz asks CadView whether the session is
+// authorized.
+type readyCheck struct {
+	at time.Time
+	l  sync.Mutex
+}
+
+func (s *Server) handleHealthz(w http.ResponseWriter, r *http.Request) {
+	maxAge := s.MaxPingAge
+	if maxAge <= 0 {
+		maxAge = 5 * time.Minute
+	}
+	st := s.Agent.Status()
+	problems := []string{}
+	if st.LoginError != "" {
+		problems = append(problems, "last login failed: "+st.LoginError)
+	}
+	// Pings which have never succeeded count from the first failure
+	if st.PingError != "" && !st.PingFailingSince.IsZero() && time.Since(st.PingFailingSince) > maxAge {
+		problems = append(problems, fmt.Sprintf("pings failing since %s: %s", st.PingFailingSince.Format(time.RFC3339), st.PingError))
+	}
+	writeHealth(w, st, problems)
+}
+
+func (s *Server) handleReadyz(w http.ResponseWriter, r *http.Request) {
+	s.ready.l.Lock()
+	if s.Agent.Status().Initialized && time.Since(s.ready.at) > readyInterval {
+		// The result is recorded in the agent status
+		s.Agent.IsAuthorized()
+		s.ready.at = time.Now()
+	}
+	s.ready.l.Unlock()
+
+	st := s.Agent.Status()
+	problems := []string{}
+	if !st.Initialized {
+		problems = append(problems, "not logged in")
+	}
+	if s.Agent.TokenExpired() {
+		problems = append(problems, "token expired at "+st.TokenExpires.Format(time.RFC3339))
+	}
+	if st.PingError != "" {
+		problems = append(problems, "ping failed: "+st.PingError)
+	}
+	if st.AuthorizedError != "" {
+		problems = append(problems, "not authorized: "+st.AuthorizedError)
+	}
+	writeHealth(w, st, problems)
+}
+
+func writeHealth(w http.ResponseWriter, st agent.Status, problems []string) {
+	out := HealthReport{Status: "ok", Problems: problems, Agent: st}
+	status := http.StatusOK
+	if len(problems) > 0 {
+		out.Status = "unavailable"
+		status = http.StatusServiceUnavailable
+	}
+	body, err := json.Marshal(out)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json; charset=utf-8")
+	w.Header().Set("Cache-Control", "no-store")
+	w.WriteHeader(status)
+	w.Write(body)
+}
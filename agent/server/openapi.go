@@ -0,0 +1,163 @@
+package server
+
+//go:generate go run ../internal/openapigen -out openapi_schemas.json ../obj.go ../watcher.go ../status.go
+
+import (
+	_ "embed"
+	"encoding/json"
+	"log"
+	"net/http"
+	"strings"
+)
+
+// openapiSchemas holds the component schemas generated from the agent
+// types, with examples taken from their inline comments.
+//
+//go:embed openapi_schemas.json
+var openapiSchemas []byte
+
+type apiParam struct {
+	Name        string
+	In          string
+	Description string
+	Format      string
+	Required    bool
+}
+
+// OpenAPI builds the OpenAPI 3 document describing the server's routes.
+func (s *Server) OpenAPI() map[string]any {
+	schemas := map[string]any{}
+	if err := json.Unmarshal(openapiSchemas, &schemas); err != nil {
+		log.Printf("ERR: OpenAPI: %s", err.Error())
+	}
+	schemas["HealthReport"] = map[string]any{
+		"type": "object",
+		"properties": map[string]any{
+			"status":   map[string]any{"type": "string", "enum": []string{"ok", "unavailable"}},
+			"problems": map[string]any{"type": "array", "items": map[string]any{"type": "string"}},
+			"agent":    schemaRef("Status"),
+		},
+	}
+	schemas["Error"] = map[string]any{
+		"type": "object",
+		"properties": map[string]any{
+			"error": map[string]any{"type": "string"},
+		},
+	}
+
+	paths := map[string]any{}
+	for _, rt := range s.routeTable() {
+		op := map[string]any{
+			"summary":   rt.Summary,
+			"responses": rt.responses(),
+		}
+		if rt.Public {
+			op["security"] = []any{}
+		}
+		if len(rt.Params) > 0 {
+			params := []any{}
+			for _, p := range rt.Params {
+				schema := map[string]any{"type": "string"}
+				switch p.Format {
+				case "int64":
+					schema = map[string]any{"type": "integer", "format": "int64"}
+				case "":
+				default:
+					schema["format"] = p.Format
+				}
+				params = append(params, map[string]any{
+					"name":        p.Name,
+					"in":          p.In,
+					"description": p.Description,
+					"required":    p.Required,
+					"schema":      schema,
+				})
+			}
+			op["parameters"] = params
+		}
+		item, ok := paths[rt.Path].(map[string]any)
+		if !ok {
+			item = map[string]any{}
+			paths[rt.Path] = item
+		}
+		item[strings.ToLower(rt.Method)] = op
+	}
+
+	return map[string]any{
+		"openapi": "3.0.3",
+		"info": map[string]any{
+			"title":       "cadview-server",
+			"description": "Data from a NewWorld CadView instance, served by a single authenticated agent.",
+			"version":     "1.0.0",
+		},
+		"paths": paths,
+		"components": map[string]any{
+			"schemas": schemas,
+			"securitySchemes": map[string]any{
+				"apiKey": map[string]any{"type": "apiKey", "in": "header", "name": "X-API-Key"},
+				"bearer": map[string]any{"type": "http", "scheme": "bearer"},
+			},
+		},
+		"security": []any{
+			map[string]any{"apiKey": []any{}},
+			map[string]any{"bearer": []any{}},
+		},
+	}
+}
+
+// responses describes the responses of a route.
+func (rt route) responses() map[string]any {
+	ok := map[string]any{"description": rt.Summary}
+	switch rt.Response {
+	case "websocket":
+		return map[string]any{
+			"101": map[string]any{"description": "Switching to the WebSocket protocol"},
+		}
+	case "text/html", "text/event-stream":
+		ok["content"] = map[string]any{rt.Response: map[string]any{"schema": map[string]any{"type": "string"}}}
+	case "application/json":
+		ok["content"] = map[string]any{rt.Response: map[string]any{"schema": map[string]any{"type": "object"}}}
+	default:
+		ok["content"] = map[string]any{"application/json": map[string]any{"schema": schemaRef(rt.Response)}}
+	}
+	out := map[string]any{"200": ok}
+	if rt.Response == "HealthReport" {
+		out["503"] = map[string]any{
+			"description": "The check failed",
+			"content":     ok["content"],
+		}
+	}
+	if !rt.Public {
+		out["401"] = errorResponse("Missing or invalid API key")
+	}
+	if strings.HasPrefix(rt.Path, "/calls") || rt.Path == "/oris" {
+		out["400"] = errorResponse("Invalid parameters")
+		out["502"] = errorResponse("CadView request failed")
+	}
+	return out
+}
+
+func schemaRef(name string) map[string]any {
+	if strings.HasPrefix(name, "[]") {
+		return map[string]any{"type": "array", "items": schemaRef(strings.TrimPrefix(name, "[]"))}
+	}
+	return map[string]any{"$ref": "#/components/schemas/" + name}
+}
+
+func errorResponse(description string) map[string]any {
+	return map[string]any{
+		"description": description,
+		"content": map[string]any{
+			"application/json": map[string]any{"schema": schemaRef("Error")},
+		},
+	}
+}
+
+func (s *Server) handleOpenAPI(w http.ResponseWriter, r *http.Request) {
+	body, err := json.MarshalIndent(s.OpenAPI(), "", "  ")
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err)
+		return
+	}
+	writeJSON(w, body)
+}
@@ -0,0 +1,222 @@
+package server
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net"
+	"net/http"
+	"os"
+	"path"
+	"strings"
+	"time"
+
+	"github.com/dayvillefire/newworld-cadview-agent/agent"
+)
+
+// DefaultProxyAllow is the list of read-only CadView API endpoints which
+// are forwarded by default.
+var DefaultProxyAllow = []string{
+	"/NewWorld.CadView/api/CadView/IsAuthorized",
+	"/NewWorld.CadView/api/CadView/Ping",
+	"/NewWorld.CadView/api/CadView/GetAllUserSettings",
+	"/NewWorld.CadView/api/CadView/GetOrisForClearedCallSearch",
+	"/NewWorld.CadView/api/Call/GetActiveCalls",
+	"/NewWorld.CadView/api/Call/SearchClearedCalls",
+	"/NewWorld.CadView/api/Call/GetCall",
+	"/NewWorld.CadView/api/Call/GetCallIncidents",
+	"/NewWorld.CadView/api/Call/GetCallLog",
+	"/NewWorld.CadView/api/Call/GetCallNarratives",
+	"/NewWorld.CadView/api/Call/GetCallUnits",
+	"/NewWorld.CadView/api/Call/GetCallUnitLogs",
+}
+
+// proxyHeaders are the request headers passed through to CadView.
+var proxyHeaders = []string{"Accept", "Accept-Language", "User-Agent"}
+
+// Proxy forwards an allow-listed set of read-only NewWorld.CadView/api/*
+// requests to the CadView instance of an Agent, adding the agent's
+// Authorization header so that tools which cannot log in can still use the
+// API. Tokens are renewed before they expire or when CadView stops
+// accepting them.
+type Proxy struct {
+	Agent *agent.Agent
+	// Allow is the list of forwarded paths, matched without regard to case.
+	// Entries may use path.Match wildcards, like
+	// "/NewWorld.CadView/api/Call/*". Defaults to DefaultProxyAllow.
+	Allow []string
+	// APIKeys are the keys accepted in the X-API-Key header. If empty,
+	// requests are not authenticated, so ListenAndServe only listens on a
+	// loopback address.
+	APIKeys []string
+	// Audit receives a line for every proxied request. Defaults to standard
+	// error.
+	Audit *log.Logger
+	// Client is the HTTP client to use, if not the default.
+	Client *http.Client
+}
+
+// ListenAndServe serves proxied requests on addr until an error occurs.
+// Without APIKeys, addr must be a loopback address, as anyone who can reach
+// the proxy can use the agent's session.
+func (p *Proxy) ListenAndServe(addr string) error {
+	if len(p.APIKeys) == 0 && !loopback(addr) {
+		return fmt.Errorf("proxy on %s requires API keys unless it listens on a loopback address like 127.0.0.1", addr)
+	}
+	log.Printf("INFO: Proxy listening on %s for %s", addr, p.Agent.BaseUrl)
+	srv := &http.Server{
+		Addr:              addr,
+		Handler:           p,
+		ReadHeaderTimeout: 10 * time.Second,
+	}
+	return srv.ListenAndServe()
+}
+
+// loopback reports whether a listen address only accepts local
+// connections.
+func loopback(addr string) bool {
+	host, _, err := net.SplitHostPort(addr)
+	if err != nil {
+		return false
+	}
+	if host == "localhost" {
+		return true
+	}
+	ip := net.ParseIP(host)
+	return ip != nil && ip.IsLoopback()
+}
+
+type statusRecorder struct {
+	http.ResponseWriter
+	status int
+	bytes  int
+}
+
+func (r *statusRecorder) WriteHeader(status int) {
+	r.status = status
+	r.ResponseWriter.WriteHeader(status)
+}
+
+func (r *statusRecorder) Write(b []byte) (int, error) {
+	if r.status == 0 {
+		r.status = http.StatusOK
+	}
+	n, err := r.ResponseWriter.Write(b)
+	r.bytes += n
+	return n, err
+}
+
+func (p *Proxy) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	start := time.Now()
+	rec := &statusRecorder{ResponseWriter: w}
+	note := ""
+	defer func() {
+		audit := p.Audit
+		if audit == nil {
+			audit = log.New(os.Stderr, "AUDIT: ", log.LstdFlags)
+		}
+		audit.Printf("%s %s %s %d %d %s %s", r.RemoteAddr, r.Method, r.URL.RequestURI(), rec.status, rec.bytes, time.Since(start).Round(time.Millisecond), note)
+	}()
+
+	if r.Method != http.MethodGet && r.Method != http.MethodHead {
+		note = "method not allowed"
+		writeError(rec, http.StatusMethodNotAllowed, errors.New(note))
+		return
+	}
+	if len(p.APIKeys) > 0 && !ValidKey(p.APIKeys, r.Header.Get("X-API-Key")) {
+		note = "invalid api key"
+		writeError(rec, http.StatusUnauthorized, errors.New(note))
+		return
+	}
+	if !p.allowed(r.URL.Path) {
+		note = "path not allowed"
+		writeError(rec, http.StatusForbidden, errors.New(note))
+		return
+	}
+
+	if exp := p.Agent.GetAuth().ExpiresAt; exp > 0 && time.Now().Add(time.Minute).Unix() > exp {
+		note = "token renewed"
+		if err := p.Agent.ReauthorizeSince(start); err != nil {
+			writeError(rec, http.StatusBadGateway, err)
+			return
+		}
+	}
+
+	res, body, err := p.forward(r)
+	if err == nil && unauthorizedResponse(res, body) {
+		note = "token renewed"
+		if err = p.Agent.ReauthorizeSince(start); err == nil {
+			res, body, err = p.forward(r)
+		}
+	}
+	if err != nil {
+		note = err.Error()
+		writeError(rec, http.StatusBadGateway, err)
+		return
+	}
+
+	for _, h := range []string{"Content-Type", "Cache-Control", "Expires", "Last-Modified", "Etag"} {
+		if v := res.Header.Get(h); v != "" {
+			rec.Header().Set(h, v)
+		}
+	}
+	rec.WriteHeader(res.StatusCode)
+	if r.Method != http.MethodHead {
+		rec.Write(body)
+	}
+}
+
+func (p *Proxy) allowed(urlPath string) bool {
+	allow := p.Allow
+	if len(allow) == 0 {
+		allow = DefaultProxyAllow
+	}
+	urlPath = strings.ToLower(path.Clean(urlPath))
+	for _, a := range allow {
+		if ok, _ := path.Match(strings.ToLower(a), urlPath); ok {
+			return true
+		}
+	}
+	return false
+}
+
+// forward sends a request upstream with the agent's credentials.
+func (p *Proxy) forward(r *http.Request) (*http.Response, []byte, error) {
+	client := p.Client
+	if client == nil {
+		client = &http.Client{Timeout: 60 * time.Second}
+	}
+	url := p.Agent.BaseUrl + strings.TrimPrefix(path.Clean(r.URL.Path), "/")
+	if r.URL.RawQuery != "" {
+		url += "?" + r.URL.RawQuery
+	}
+	req, err := http.NewRequestWithContext(r.Context(), http.MethodGet, url, nil)
+	if err != nil {
+		return nil, []byte{}, err
+	}
+	for _, h := range proxyHeaders {
+		if v := r.Header.Get(h); v != "" {
+			req.Header.Set(h, v)
+		}
+	}
+	auth := p.Agent.GetAuth()
+	req.Header.Set("Authorization", auth.TokenType+" "+auth.AccessToken)
+
+	res, err := client.Do(req)
+	if err != nil {
+		return nil, []byte{}, err
+	}
+	defer res.Body.Close()
+	body, err := io.ReadAll(res.Body)
+	return res, body, err
+}
+
+// unauthorizedResponse detects an expired session, which CadView reports
+// either with a 401 or by serving the HTML login page.
+func unauthorizedResponse(res *http.Response, body []byte) bool {
+	if res.StatusCode == http.StatusUnauthorized {
+		return true
+	}
+	return res.StatusCode == http.StatusOK && (len(body) < 1 || body[0] == '<')
+}
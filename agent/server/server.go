@@ -0,0 +1,316 @@
+// Package server exposes a single authenticated Agent to other tools over
+// HTTP, so that one CadView session can serve a whole department.
+package server
+
+import (
+	"crypto/subtle"
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/dayvillefire/newworld-cadview-agent/agent"
+)
+
+// Server serves JSON endpoints backed by an Agent.
+type Server struct {
+	// Agent is the agent used for all requests. It must already be logged
+	// in, with Init or TransferAuthFrom.
+	Agent *agent.Agent
+	// APIKeys are the keys accepted in the X-API-Key header or as a bearer
+	// token. If empty, requests are not authenticated.
+	APIKeys []string
+	// CacheTTL is how long responses are cached. Defaults to 15 seconds;
+	// a negative value disables caching.
+	CacheTTL time.Duration
+	// Feed streams active call changes at /events and /ws if set.
+	Feed *Feed
+	// Metrics serves Prometheus metrics at /metrics if set, usually from
+	// metrics.Metrics.Handler.
+	Metrics http.Handler
+	// MaxPingAge is how long /healthz tolerates the keepalive pings made by
+	// Agent.Run failing before it reports the agent as unhealthy. Defaults
+	// to 5 minutes.
+	MaxPingAge time.Duration
+	// Debug turns on request logging.
+	Debug bool
+
+	mux   *http.ServeMux
+	cache map[string]cacheEntry
+	l     sync.Mutex
+	once  sync.Once
+	ready readyCheck
+}
+
+type cacheEntry struct {
+	body    []byte
+	expires time.Time
+}
+
+// Handler returns the HTTP handler for the server.
+func (s *Server) Handler() http.Handler {
+	s.once.Do(s.routes)
+	return s.mux
+}
+
+// ListenAndServe serves requests on addr until an error occurs.
+func (s *Server) ListenAndServe(addr string) error {
+	if len(s.APIKeys) == 0 {
+		log.Printf("WARN: No API keys configured, requests will not be authenticated")
+	}
+	log.Printf("INFO: Listening on %s", addr)
+	srv := &http.Server{
+		Addr:              addr,
+		Handler:           s.Handler(),
+		ReadHeaderTimeout: 10 * time.Second,
+	}
+	return srv.ListenAndServe()
+}
+
+// route is a single endpoint of the server, along with the details used to
+// describe it in the OpenAPI document.
+type route struct {
+	Method  string
+	Path    string
+	Handler http.HandlerFunc
+	// Public endpoints do not require an API key.
+	Public  bool
+	Summary string
+	Params  []apiParam
+	// Response is the schema name of a JSON response, like "CallObj" or
+	// "[]CallObj", or a media type such as "text/html".
+	Response string
+}
+
+func (s *Server) routeTable() []route {
+	routes := []route{
+		{
+			Method: "GET", Path: "/calls/active", Handler: s.handleActiveCalls,
+			Summary:  "Active calls",
+			Response: "[]CallObj",
+		},
+		{
+			Method: "GET", Path: "/calls/cleared", Handler: s.handleClearedCalls,
+			Summary: "Cleared calls",
+			Params: []apiParam{
+				{Name: "from", In: "query", Format: "date-time", Description: "Start of the search, as a date or RFC 3339 time. Defaults to 24 hours ago."},
+				{Name: "to", In: "query", Format: "date-time", Description: "End of the search, as a date or RFC 3339 time. Defaults to now."},
+				{Name: "ori", In: "query", Description: "CAD ORI or FDID, which the user must be permitted to search. Defaults to the FDID of the agent."},
+			},
+			Response: "[]CallObj",
+		},
+		{
+			Method: "GET", Path: "/calls/{id}", Handler: s.handleCall,
+			Summary:  "Full call record, including units, unit logs, narratives and logs",
+			Params:   []apiParam{{Name: "id", In: "path", Required: true, Format: "int64", Description: "Call ID"}},
+			Response: "CADCall",
+		},
+		{
+			Method: "GET", Path: "/oris", Handler: s.handleORIs,
+			Summary:  "ORIs available for cleared call searches",
+			Response: "[]ORIObj",
+		},
+		{
+			Method: "GET", Path: "/dashboard", Handler: s.handleDashboard,
+			Summary: "Active incident dashboard",
+			Params: []apiParam{
+				{Name: "board", In: "query", Description: "Set to 1 for the station bay big board layout."},
+				{Name: "ori", In: "query", Description: "Comma separated FDIDs or ORIs to show."},
+			},
+			Response: "text/html",
+		},
+		{
+			Method: "GET", Path: "/healthz", Handler: s.handleHealthz,
+			Public:   true,
+			Summary:  "Liveness: fails once logins fail or keepalive pings have failed for too long",
+			Response: "HealthReport",
+		},
+		{
+			Method: "GET", Path: "/readyz", Handler: s.handleReadyz,
+			Public:   true,
+			Summary:  "Readiness: fails unless the session is logged in, unexpired and authorized by CadView",
+			Response: "HealthReport",
+		},
+		{
+			Method: "GET", Path: "/openapi.json", Handler: s.handleOpenAPI,
+			Public:   true,
+			Summary:  "This OpenAPI document",
+			Response: "application/json",
+		},
+	}
+	if s.Metrics != nil {
+		routes = append(routes, route{
+			Method: "GET", Path: "/metrics", Handler: s.Metrics.ServeHTTP,
+			Summary:  "Prometheus metrics",
+			Response: "text/plain",
+		})
+	}
+	if s.Feed != nil {
+		feedParams := []apiParam{
+			{Name: "ori", In: "query", Description: "Comma separated FDIDs or ORIs to receive events for."},
+			{Name: "lastEventId", In: "query", Format: "int64", Description: "Resume after this event, as an alternative to the Last-Event-ID header."},
+		}
+		routes = append(routes,
+			route{
+				Method: "GET", Path: "/events", Handler: s.Feed.ServeSSE,
+				Summary:  "Server-Sent Events stream of Event objects, starting with a SnapshotEvent",
+				Params:   feedParams,
+				Response: "text/event-stream",
+			},
+			route{
+				Method: "GET", Path: "/ws", Handler: s.Feed.ServeWebSocket,
+				Summary:  "WebSocket stream of FeedEvent objects, starting with a SnapshotEvent",
+				Params:   feedParams,
+				Response: "websocket",
+			},
+		)
+	}
+	return routes
+}
+
+func (s *Server) routes() {
+	s.mux = http.NewServeMux()
+	if s.Feed != nil {
+		s.Feed.init()
+	}
+	for _, rt := range s.routeTable() {
+		var h http.Handler = rt.Handler
+		if !rt.Public {
+			h = s.authorized(rt.Handler)
+		}
+		s.mux.Handle(rt.Method+" "+rt.Path, h)
+	}
+	s.mux.Handle("GET /{$}", http.RedirectHandler("/dashboard", http.StatusFound))
+}
+
+// authorized wraps a handler with API key authentication.
+func (s *Server) authorized(fn http.HandlerFunc) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if s.Debug {
+			log.Printf("DEBUG: %s %s %s", r.RemoteAddr, r.Method, r.URL.String())
+		}
+		if len(s.APIKeys) > 0 && !ValidKey(s.APIKeys, requestKey(r)) {
+			writeError(w, http.StatusUnauthorized, errors.New("invalid api key"))
+			return
+		}
+		fn(w, r)
+	})
+}
+
+// queryKeyPaths are the paths which accept an API key in the query string.
+var queryKeyPaths = map[string]bool{
+	"/dashboard": true,
+	"/events":    true,
+	"/ws":        true,
+}
+
+func requestKey(r *http.Request) string {
+	if key := r.Header.Get("X-API-Key"); key != "" {
+		return key
+	}
+	// Dashboards, EventSource and WebSocket clients in browsers cannot set
+	// headers
+	if key := r.URL.Query().Get("key"); key != "" && queryKeyPaths[r.URL.Path] {
+		return key
+	}
+	if auth := r.Header.Get("Authorization"); strings.HasPrefix(auth, "Bearer ") {
+		return strings.TrimPrefix(auth, "Bearer ")
+	}
+	return ""
+}
+
+// ValidKey reports whether key is one of keys, comparing in constant time.
+func ValidKey(keys []string, key string) bool {
+	if key == "" {
+		return false
+	}
+	for _, k := range keys {
+		if subtle.ConstantTimeCompare([]byte(k), []byte(key)) == 1 {
+			return true
+		}
+	}
+	return false
+}
+
+// cached returns a cached response for key, or calls fn and caches its
+// JSON encoded result.
+func (s *Server) cached(key string, fn func() (any, error)) ([]byte, error) {
+	ttl := s.CacheTTL
+	if ttl == 0 {
+		ttl = 15 * time.Second
+	}
+
+	s.l.Lock()
+	if s.cache == nil {
+		s.cache = map[string]cacheEntry{}
+	}
+	if e, ok := s.cache[key]; ok && time.Now().Before(e.expires) {
+		s.l.Unlock()
+		return e.body, nil
+	}
+	s.l.Unlock()
+
+	v, err := s.call(fn)
+	if err != nil {
+		return []byte{}, err
+	}
+	body, err := json.Marshal(v)
+	if err != nil {
+		return []byte{}, err
+	}
+	s.store(key, body, ttl)
+	return body, nil
+}
+
+// cachedValue is like cached, but decodes the response into v.
+func (s *Server) cachedValue(key string, v any, fn func() (any, error)) ([]byte, error) {
+	body, err := s.cached(key, fn)
+	if err != nil {
+		return body, err
+	}
+	return body, json.Unmarshal(body, v)
+}
+
+// store caches a response, expiring any stale entries.
+func (s *Server) store(key string, body []byte, ttl time.Duration) {
+	if ttl <= 0 {
+		return
+	}
+	s.l.Lock()
+	defer s.l.Unlock()
+	now := time.Now()
+	for k, e := range s.cache {
+		if now.After(e.expires) {
+			delete(s.cache, k)
+		}
+	}
+	s.cache[key] = cacheEntry{body: body, expires: now.Add(ttl)}
+}
+
+// call runs fn against the agent, logging in again and retrying once if
+// the session is no longer authorized.
+func (s *Server) call(fn func() (any, error)) (any, error) {
+	start := time.Now()
+	v, err := fn()
+	if !errors.Is(err, agent.ErrNotAuthorized) {
+		return v, err
+	}
+	if err = s.Agent.ReauthorizeSince(start); err != nil {
+		return v, err
+	}
+	return fn()
+}
+
+func writeJSON(w http.ResponseWriter, body []byte) {
+	w.Header().Set("Content-Type", "application/json; charset=utf-8")
+	w.Write(body)
+}
+
+func writeError(w http.ResponseWriter, status int, err error) {
+	w.Header().Set("Content-Type", "application/json; charset=utf-8")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(map[string]string{"error": err.Error()})
+}
@@ -0,0 +1,474 @@
+package server
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/dayvillefire/newworld-cadview-agent/agent"
+)
+
+// fakeCAD is a local stand-in for the CadView API.
+type fakeCAD struct {
+	requests     atomic.Int32
+	unauthorized atomic.Bool
+}
+
+func (f *fakeCAD) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	f.requests.Add(1)
+	var out any
+	switch {
+	case strings.HasSuffix(r.URL.Path, "/api/Call/GetActiveCalls"):
+		out = []agent.CallObj{{CallID: 591039, CallType: "Structure Fire", AllowedORI: []string{"04040"}, ForegroundR: 204, ForegroundG: 0, ForegroundB: 300}}
+	case strings.HasSuffix(r.URL.Path, "/api/Call/SearchClearedCalls"):
+		out = []agent.CallObj{{CallID: 1, IncidentNumber: "2022-00000345 ori=" + r.URL.Query().Get("ori")}}
+	case strings.HasSuffix(r.URL.Path, "/api/CadView/GetOrisForClearedCallSearch"):
+		out = []agent.ORIObj{{ORI: "26", FDID: "04040", AgencyName: "Pomfret"}}
+	case strings.HasSuffix(r.URL.Path, "/api/CadView/Ping"), strings.HasSuffix(r.URL.Path, "/api/CadView/IsAuthorized"):
+		out = !f.unauthorized.Load()
+	case strings.HasSuffix(r.URL.Path, "/api/Call/GetCall"):
+		out = agent.CallObj{CallID: 591039, IncidentNumber: "2022-00000345"}
+	default:
+		out = []any{}
+	}
+	json.NewEncoder(w).Encode(out)
+}
+
+func newTestServer(t *testing.T) (*Server, *fakeCAD) {
+	f := &fakeCAD{}
+	cad := httptest.NewServer(f)
+	t.Cleanup(cad.Close)
+	a := &agent.Agent{BaseUrl: cad.URL + "/", FDID: "04040"}
+	a.SetAuth(agent.OidcObj{TokenType: "Bearer", AccessToken: "test"})
+	return &Server{Agent: a, APIKeys: []string{"secret"}}, f
+}
+
+func get(t *testing.T, h http.Handler, path, key string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest("GET", path, nil)
+	if key != "" {
+		req.Header.Set("X-API-Key", key)
+	}
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, req)
+	return rec
+}
+
+func Test_Server_API(t *testing.T) {
+	s, f := newTestServer(t)
+	h := s.Handler()
+
+	if rec := get(t, h, "/oris", ""); rec.Code != http.StatusUnauthorized {
+		t.Fatalf("expected 401 without key, got %d", rec.Code)
+	}
+
+	rec := get(t, h, "/calls/cleared?from=2022-10-13&to=2022-10-14&ori=04040", "secret")
+	if rec.Code != http.StatusOK || !strings.Contains(rec.Body.String(), "ori=26") {
+		t.Fatalf("unexpected cleared response %d: %s", rec.Code, rec.Body.String())
+	}
+
+	var cad agent.CADCall
+	rec = get(t, h, "/calls/591039", "secret")
+	if err := json.Unmarshal(rec.Body.Bytes(), &cad); err != nil || cad.Call.IncidentNumber != "2022-00000345" {
+		t.Fatalf("unexpected call response %d: %s", rec.Code, rec.Body.String())
+	}
+
+	// Repeated requests are served from the cache, once the ORIs cached by
+	// the cleared call search have expired
+	s.l.Lock()
+	s.cache = nil
+	s.l.Unlock()
+	before := f.requests.Load()
+	for i := 0; i < 3; i++ {
+		if rec := get(t, h, "/oris", "secret"); rec.Code != http.StatusOK {
+			t.Fatalf("unexpected oris response %d", rec.Code)
+		}
+	}
+	if f.requests.Load()-before != 1 {
+		t.Fatalf("expected one upstream request, got %d", f.requests.Load()-before)
+	}
+}
+
+func Test_Server_ClearedORIs(t *testing.T) {
+	s, f := newTestServer(t)
+	h := s.Handler()
+
+	// The ORIs used to resolve the FDID, and those the user is permitted,
+	// are cached between searches
+	for i, day := range []string{"2022-10-13", "2022-10-14", "2022-10-15"} {
+		before := f.requests.Load()
+		rec := get(t, h, "/calls/cleared?from="+day+"&to="+day+"T23:59:59&ori=04040", "secret")
+		if rec.Code != http.StatusOK {
+			t.Fatalf("unexpected cleared response %d: %s", rec.Code, rec.Body.String())
+		}
+		want := int32(1)
+		if i == 0 {
+			want = 3
+		}
+		if got := f.requests.Load() - before; got != want {
+			t.Fatalf("search %d: expected %d upstream requests, got %d", i, want, got)
+		}
+	}
+}
+
+func Test_Server_ClearedDefaultWindow(t *testing.T) {
+	now := time.Date(2022, 10, 14, 10, 15, 42, 0, time.UTC)
+	from, to := defaultClearedWindow(now)
+	if want := time.Date(2022, 10, 14, 10, 16, 0, 0, time.UTC); !to.Equal(want) || !from.Equal(want.Add(-24*time.Hour)) {
+		t.Fatalf("unexpected window %s - %s", from, to)
+	}
+	// Searches within the same minute share a cache key
+	if from2, to2 := defaultClearedWindow(now.Add(17 * time.Second)); !from2.Equal(from) || !to2.Equal(to) {
+		t.Fatalf("window moved within the minute: %s - %s", from2, to2)
+	}
+}
+
+func Test_Feed_Resume(t *testing.T) {
+	f := &Feed{}
+	call := agent.CallObj{CallID: 1, AllowedORI: []string{"04040-561"}}
+	other := agent.CallObj{CallID: 2, AllowedORI: []string{"04090"}}
+	f.Publish(agent.Event{Type: agent.EventNewCall, Call: call})
+	f.Publish(agent.Event{Type: agent.EventNewCall, Call: other})
+	f.Publish(agent.Event{Type: agent.EventCallClosed, Call: call})
+
+	c, replay, snapshot := f.Subscribe([]string{"04040"}, 1)
+	defer f.Unsubscribe(c)
+	if snapshot || len(replay) != 1 || replay[0].ID != 3 || replay[0].Event.Type != agent.EventCallClosed {
+		t.Fatalf("unexpected replay %#v", replay)
+	}
+
+	f.Publish(agent.Event{Type: agent.EventNewCall, Call: other})
+	f.Publish(agent.Event{Type: agent.EventCallUpdated, Call: call})
+	fe := <-c.ch
+	if fe.ID != 5 || fe.Event.Call.CallID != 1 {
+		t.Fatalf("filtered client received %#v", fe)
+	}
+}
+
+func Test_Feed_ResumeGap(t *testing.T) {
+	f := &Feed{Buffer: 2}
+	for i := 1; i <= 4; i++ {
+		f.Publish(agent.Event{Type: agent.EventNewCall, Call: agent.CallObj{CallID: int64(i)}})
+	}
+
+	// Event 2 is gone, so resuming after 1 needs a snapshot
+	c, replay, snapshot := f.Subscribe(nil, 1)
+	f.Unsubscribe(c)
+	if !snapshot || len(replay) != 0 {
+		t.Fatalf("expected a snapshot, got replay %#v", replay)
+	}
+	// Resuming after 2 is covered by the buffer
+	c, replay, snapshot = f.Subscribe(nil, 2)
+	f.Unsubscribe(c)
+	if snapshot || len(replay) != 2 {
+		t.Fatalf("expected events 3 and 4, got %#v (snapshot %v)", replay, snapshot)
+	}
+	// An ID from before a restart
+	c, _, snapshot = f.Subscribe(nil, 10)
+	f.Unsubscribe(c)
+	if !snapshot {
+		t.Fatalf("expected a snapshot for an unknown event ID")
+	}
+}
+
+func Test_Feed_SSE(t *testing.T) {
+	s, _ := newTestServer(t)
+	s.Feed = &Feed{}
+	srv := httptest.NewServer(s.Handler())
+	defer srv.Close()
+
+	res, err := http.Get(srv.URL + "/events?key=secret")
+	if err != nil {
+		t.Fatalf("ERR: %s", err.Error())
+	}
+	defer res.Body.Close()
+	r := bufio.NewReader(res.Body)
+	readEvent := func() string {
+		var b strings.Builder
+		for {
+			line, err := r.ReadString('\n')
+			if err != nil || line == "\n" {
+				return b.String()
+			}
+			b.WriteString(line)
+		}
+	}
+	if ev := readEvent(); !strings.HasPrefix(ev, "event: snapshot") {
+		t.Fatalf("expected snapshot, got %q", ev)
+	}
+	s.Feed.Publish(agent.Event{Type: agent.EventNewCall, Call: agent.CallObj{CallID: 7}})
+	if ev := readEvent(); !strings.HasPrefix(ev, "id: 1\nevent: new_call\n") {
+		t.Fatalf("unexpected event %q", ev)
+	}
+}
+
+func Test_Server_Dashboard(t *testing.T) {
+	s, _ := newTestServer(t)
+	h := s.Handler()
+
+	rec := get(t, h, "/dashboard?board=1&key=secret", "")
+	body := rec.Body.String()
+	if rec.Code != http.StatusOK || !strings.Contains(body, `class="board"`) || !strings.Contains(body, "Structure Fire") {
+		t.Fatalf("unexpected dashboard %d: %s", rec.Code, body)
+	}
+	if strings.Contains(body, "http://") || strings.Contains(body, "https://") {
+		t.Fatalf("dashboard references external resources")
+	}
+	if !strings.Contains(body, `style="--priority: rgb(204,0,255)"`) || strings.Contains(body, "ZgotmplZ") {
+		t.Fatalf("priority color not rendered: %s", body)
+	}
+	if rec := get(t, h, "/calls/active?key=secret", ""); rec.Code != http.StatusUnauthorized {
+		t.Fatalf("query key accepted outside of browser paths")
+	}
+}
+
+func Test_Proxy(t *testing.T) {
+	var auth atomic.Value
+	cad := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		auth.Store(r.Header.Get("Authorization"))
+		w.Write([]byte(`[{"callId":591039}]`))
+	}))
+	defer cad.Close()
+
+	a := &agent.Agent{BaseUrl: cad.URL + "/"}
+	a.SetAuth(agent.OidcObj{TokenType: "Bearer", AccessToken: "token"})
+	var audit strings.Builder
+	p := &Proxy{Agent: a, Audit: log.New(&audit, "", 0)}
+
+	rec := httptest.NewRecorder()
+	p.ServeHTTP(rec, httptest.NewRequest("GET", "/newworld.cadview/api/Call/GetActiveCalls", nil))
+	if rec.Code != http.StatusOK || auth.Load() != "Bearer token" || !strings.Contains(rec.Body.String(), "591039") {
+		t.Fatalf("unexpected proxy response %d: %s", rec.Code, rec.Body.String())
+	}
+
+	for _, req := range []*http.Request{
+		httptest.NewRequest("POST", "/NewWorld.CadView/api/Call/GetActiveCalls", nil),
+		httptest.NewRequest("GET", "/NewWorld.CadView/api/Call/UpdateCall", nil),
+		httptest.NewRequest("GET", "/NewWorld.CadView/api/Call/../CadView/SaveUserSettings", nil),
+	} {
+		rec := httptest.NewRecorder()
+		p.ServeHTTP(rec, req)
+		if rec.Code == http.StatusOK {
+			t.Fatalf("%s %s was forwarded", req.Method, req.URL.Path)
+		}
+	}
+	if n := strings.Count(audit.String(), "\n"); n != 4 {
+		t.Fatalf("expected 4 audit lines, got %d:\n%s", n, audit.String())
+	}
+
+	// Keys are required off the loopback interface
+	if err := p.ListenAndServe(":0"); err == nil || !strings.Contains(err.Error(), "requires API keys") {
+		t.Fatalf("expected the proxy to refuse a public address, got %v", err)
+	}
+	for addr, want := range map[string]bool{"127.0.0.1:8081": true, "[::1]:8081": true, "localhost:8081": true, "0.0.0.0:8081": false, "10.0.0.5:8081": false} {
+		if loopback(addr) != want {
+			t.Errorf("loopback(%s) != %v", addr, want)
+		}
+	}
+
+	p.APIKeys = []string{"secret"}
+	rec = httptest.NewRecorder()
+	p.ServeHTTP(rec, httptest.NewRequest("GET", "/NewWorld.CadView/api/Call/GetActiveCalls", nil))
+	if rec.Code != http.StatusUnauthorized {
+		t.Fatalf("expected 401 without a key, got %d", rec.Code)
+	}
+}
+
+func Test_Server_Health(t *testing.T) {
+	s, f := newTestServer(t)
+	h := s.Handler()
+
+	var report HealthReport
+	rec := get(t, h, "/healthz", "")
+	if err := json.Unmarshal(rec.Body.Bytes(), &report); err != nil || rec.Code != http.StatusOK || report.Status != "ok" {
+		t.Fatalf("unexpected healthz response %d: %s", rec.Code, rec.Body.String())
+	}
+
+	// The test agent has a token but has not logged in
+	rec = get(t, h, "/readyz", "")
+	if rec.Code != http.StatusServiceUnavailable || !strings.Contains(rec.Body.String(), "not logged in") {
+		t.Fatalf("unexpected readyz response %d: %s", rec.Code, rec.Body.String())
+	}
+
+	// A server answering false is not a healthy session
+	if err := s.Agent.Ping(); err != nil {
+		t.Fatalf("ERR: %s", err.Error())
+	}
+	f.unauthorized.Store(true)
+	if err := s.Agent.Ping(); err != agent.ErrNotAuthorized {
+		t.Fatalf("expected ErrNotAuthorized, got %v", err)
+	}
+	rec = get(t, h, "/readyz", "")
+	if !strings.Contains(rec.Body.String(), "ping failed: not authorized") || !strings.Contains(rec.Body.String(), `"lastPing"`) {
+		t.Fatalf("unexpected readyz response %d: %s", rec.Code, rec.Body.String())
+	}
+
+	// Pings which have never succeeded fail liveness once too old
+	s, f = newTestServer(t)
+	s.MaxPingAge = time.Millisecond
+	f.unauthorized.Store(true)
+	s.Agent.Ping()
+	time.Sleep(5 * time.Millisecond)
+	rec = get(t, s.Handler(), "/healthz", "")
+	if rec.Code != http.StatusServiceUnavailable || !strings.Contains(rec.Body.String(), "pings failing since") {
+		t.Fatalf("unexpected healthz response %d: %s", rec.Code, rec.Body.String())
+	}
+}
+
+// Test_Server_OpenAPI checks that the OpenAPI document matches the live
+// handlers and the agent types, so that changes to either without
+// running go generate fail CI.
+func Test_Server_OpenAPI(t *testing.T) {
+	s, _ := newTestServer(t)
+	s.Feed = &Feed{}
+	h := s.Handler()
+
+	rec := get(t, h, "/openapi.json", "")
+	if rec.Code != http.StatusOK {
+		t.Fatalf("unexpected openapi response %d", rec.Code)
+	}
+	var doc struct {
+		Paths      map[string]map[string]any `json:"paths"`
+		Components struct {
+			Schemas map[string]map[string]any `json:"schemas"`
+		} `json:"components"`
+	}
+	if err := json.Unmarshal(rec.Body.Bytes(), &doc); err != nil {
+		t.Fatalf("ERR: %s", err.Error())
+	}
+	schemas := doc.Components.Schemas
+
+	// Every $ref resolves
+	var walk func(v any)
+	walk = func(v any) {
+		switch v := v.(type) {
+		case map[string]any:
+			if ref, ok := v["$ref"].(string); ok {
+				if _, ok := schemas[strings.TrimPrefix(ref, "#/components/schemas/")]; !ok {
+					t.Errorf("unresolved reference %s", ref)
+				}
+			}
+			for _, x := range v {
+				walk(x)
+			}
+		case []any:
+			for _, x := range v {
+				walk(x)
+			}
+		}
+	}
+	walk(doc.Paths)
+	for _, sc := range schemas {
+		walk(sc)
+	}
+
+	// The generated schemas are current with the agent types
+	for _, v := range []any{agent.CADCall{}, agent.CallObj{}, agent.CallLogObj{}, agent.IncidentObj{},
+		agent.NarrativeObj{}, agent.ORIObj{}, agent.UnitObj{}, agent.UnitLogObj{}, agent.Event{}, agent.Alert{},
+		agent.Status{}} {
+		rt := reflect.TypeOf(v)
+		props, _ := schemas[rt.Name()]["properties"].(map[string]any)
+		fields := map[string]bool{}
+		for i := 0; i < rt.NumField(); i++ {
+			name, _, _ := strings.Cut(rt.Field(i).Tag.Get("json"), ",")
+			if name == "" || name == "-" {
+				continue
+			}
+			fields[name] = true
+			if _, ok := props[name]; !ok {
+				t.Errorf("%s.%s is missing from the schema, run go generate", rt.Name(), name)
+			}
+		}
+		for name := range props {
+			if !fields[name] {
+				t.Errorf("%s.%s is no longer in the type, run go generate", rt.Name(), name)
+			}
+		}
+	}
+
+	// Every route is documented, and JSON responses match their schemas
+	for _, rt := range s.routeTable() {
+		if _, ok := doc.Paths[rt.Path][strings.ToLower(rt.Method)]; !ok {
+			t.Errorf("%s %s is not documented", rt.Method, rt.Path)
+		}
+		if strings.Contains(rt.Response, "/") || rt.Response == "websocket" {
+			continue
+		}
+		path := strings.Replace(rt.Path, "{id}", "591039", 1)
+		rec := get(t, h, path, "secret")
+		if rec.Code != http.StatusOK && !(rt.Response == "HealthReport" && rec.Code == http.StatusServiceUnavailable) {
+			t.Fatalf("%s: unexpected status %d", path, rec.Code)
+		}
+		var body any
+		json.Unmarshal(rec.Body.Bytes(), &body)
+		var ref map[string]any
+		b, _ := json.Marshal(schemaRef(rt.Response))
+		json.Unmarshal(b, &ref)
+		if err := checkSchema(body, ref, schemas); err != nil {
+			t.Errorf("%s: %s", path, err.Error())
+		}
+	}
+}
+
+// checkSchema reports properties and types in v which are not described
+// by schema.
+func checkSchema(v any, schema map[string]any, schemas map[string]map[string]any) error {
+	if ref, ok := schema["$ref"].(string); ok {
+		return checkSchema(v, schemas[strings.TrimPrefix(ref, "#/components/schemas/")], schemas)
+	}
+	if v == nil {
+		return nil
+	}
+	switch schema["type"] {
+	case "object":
+		obj, ok := v.(map[string]any)
+		if !ok {
+			return fmt.Errorf("expected object, got %T", v)
+		}
+		props, _ := schema["properties"].(map[string]any)
+		extra, _ := schema["additionalProperties"].(map[string]any)
+		for k, x := range obj {
+			p, ok := props[k].(map[string]any)
+			if !ok && extra != nil {
+				p, ok = extra, true
+			}
+			if !ok {
+				return fmt.Errorf("undocumented property %q", k)
+			}
+			if err := checkSchema(x, p, schemas); err != nil {
+				return fmt.Errorf("%s: %w", k, err)
+			}
+		}
+	case "array":
+		arr, ok := v.([]any)
+		if !ok {
+			return fmt.Errorf("expected array, got %T", v)
+		}
+		items, _ := schema["items"].(map[string]any)
+		for _, x := range arr {
+			if err := checkSchema(x, items, schemas); err != nil {
+				return err
+			}
+		}
+	case "string":
+		if _, ok := v.(string); !ok {
+			return fmt.Errorf("expected string, got %T", v)
+		}
+	case "integer", "number":
+		if _, ok := v.(float64); !ok {
+			return fmt.Errorf("expected number, got %T", v)
+		}
+	case "boolean":
+		if _, ok := v.(bool); !ok {
+			return fmt.Errorf("expected boolean, got %T", v)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,239 @@
+package agent
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"slices"
+	"strings"
+	"time"
+)
+
+// UserSettings are the CadView settings of the logged in user, as returned
+// by GetAllUserSettings.
+type UserSettings struct {
+	// Settings holds each setting by name, as CadView returned it.
+	Settings map[string]json.RawMessage
+	// ORIs are the agencies the user may see, if the settings list them in
+	// the form GetOrisForClearedCallSearch returns. It is nil when they do
+	// not, which means the permitted agencies are unknown rather than that
+	// none are permitted.
+	ORIs []ORIObj
+}
+
+// Identity is who the agent is logged in as, from the OIDC profile claims.
+type Identity struct {
+	// Subject is the sub claim, the user ID at the identity provider.
+	Subject string `json:"sub"`
+	// IDP is the identity provider which authenticated the user.
+	IDP string `json:"idp"`
+	// AuthTime is when the user authenticated.
+	AuthTime  time.Time `json:"authTime"`
+	SessionID string    `json:"sid"`
+}
+
+// settingsORIKeys are the names under which the settings may list the
+// agencies the user may see. GetAllUserSettings is not documented, so these
+// are guesses, and a list under one of them is only used if every entry has
+// an ORI ID. Otherwise PermittedORIs falls back to the ORIs offered for
+// cleared call searches.
+var settingsORIKeys = []string{"oris", "userOris", "permittedOris", "agencies", "userAgencies"}
+
+// Get decodes a setting into v. Names are matched without regard to case.
+func (s UserSettings) Get(name string, v any) error {
+	raw, ok := s.lookup(name)
+	if !ok {
+		return fmt.Errorf("no setting %q", name)
+	}
+	return json.Unmarshal(raw, v)
+}
+
+// String returns a setting as a string, if it is a JSON string, number or
+// boolean.
+func (s UserSettings) String(name string) (string, bool) {
+	raw, ok := s.lookup(name)
+	if !ok {
+		return "", false
+	}
+	var v any
+	if err := json.Unmarshal(raw, &v); err != nil {
+		return "", false
+	}
+	switch t := v.(type) {
+	case string:
+		return t, true
+	case float64, bool:
+		return string(raw), true
+	}
+	return "", false
+}
+
+func (s UserSettings) lookup(name string) (json.RawMessage, bool) {
+	if raw, ok := s.Settings[name]; ok {
+		return raw, true
+	}
+	for k, raw := range s.Settings {
+		if strings.EqualFold(k, name) {
+			return raw, true
+		}
+	}
+	return nil, false
+}
+
+// parseUserSettings parses GetAllUserSettings, which is either an object
+// keyed by setting name or a list of name and value pairs. The payload is
+// not documented, so the field names accepted for list entries are guesses
+// at what CadView may send.
+func parseUserSettings(body []byte) (UserSettings, error) {
+	s := UserSettings{Settings: map[string]json.RawMessage{}}
+	if err := json.Unmarshal(body, &s.Settings); err != nil {
+		var list []map[string]json.RawMessage
+		if lerr := json.Unmarshal(body, &list); lerr != nil {
+			return s, err
+		}
+		for _, item := range list {
+			var name string
+			var value json.RawMessage
+			for k, v := range item {
+				switch strings.ToLower(k) {
+				case "name", "key", "settingname", "settingkey":
+					json.Unmarshal(v, &name)
+				case "value", "settingvalue":
+					value = v
+				}
+			}
+			if name != "" {
+				s.Settings[name] = value
+			}
+		}
+	}
+
+	for _, key := range settingsORIKeys {
+		var oris []ORIObj
+		if s.Get(key, &oris) == nil && validORIs(oris) {
+			s.ORIs = oris
+			break
+		}
+	}
+	return s, nil
+}
+
+// validORIs reports whether a decoded list looks like the ORIs returned by
+// GetOrisForClearedCallSearch. Any JSON array of objects decodes into
+// []ORIObj, so an unrelated setting would otherwise read as a list of
+// agencies matching nothing.
+func validORIs(oris []ORIObj) bool {
+	if len(oris) == 0 {
+		return false
+	}
+	for _, o := range oris {
+		if o.ORI == "" {
+			return false
+		}
+	}
+	return true
+}
+
+// GetUserSettings retrieves the settings of the logged in user.
+func (a *Agent) GetUserSettings() (UserSettings, error) {
+	// https://cadview.qvec.org/NewWorld.CadView/api/CadView/GetAllUserSettings
+
+	url := a.BaseUrl + "NewWorld.CadView/api/CadView/GetAllUserSettings"
+	body, err := a.authorizedGet(url)
+	if err != nil {
+		return UserSettings{}, err
+	}
+	return parseUserSettings(body)
+}
+
+// loadUserInfo reads the user settings and permitted ORIs from the login
+// traffic, which the CadView app requests as it starts.
+func (a *Agent) loadUserInfo() {
+	settings := (*UserSettings)(nil)
+	if body, ok := a.Capture.Body("*/NewWorld.CadView/api/CadView/GetAllUserSettings"); ok {
+		s, err := parseUserSettings(body)
+		if err != nil {
+			log.Printf("ERR: Parsing user settings: %s", err.Error())
+		} else {
+			settings = &s
+		}
+	}
+	var oris []ORIObj
+	if body, ok := a.Capture.Body("*/NewWorld.CadView/api/CadView/GetOrisForClearedCallSearch"); ok {
+		if err := json.Unmarshal(body, &oris); err != nil {
+			log.Printf("ERR: Parsing ORIs: %s", err.Error())
+			oris = nil
+		}
+	}
+
+	a.l.Lock()
+	defer a.l.Unlock()
+	a.settings = settings
+	a.oris = oris
+}
+
+// UserSettings returns the settings of the logged in user, as captured
+// during login or otherwise retrieved from CadView.
+func (a *Agent) UserSettings() (UserSettings, error) {
+	a.l.Lock()
+	settings := a.settings
+	a.l.Unlock()
+	if settings != nil {
+		return *settings, nil
+	}
+	s, err := a.GetUserSettings()
+	if err != nil {
+		return s, err
+	}
+	a.l.Lock()
+	a.settings = &s
+	a.l.Unlock()
+	return s, nil
+}
+
+// PermittedORIs returns the agencies the logged in user may search, from
+// the settings if they list them, or else from the ORIs CadView offers for
+// cleared call searches. Settings which CadView returned in a form not
+// recognised here do not restrict the list.
+func (a *Agent) PermittedORIs() ([]ORIObj, error) {
+	a.l.Lock()
+	oris := a.oris
+	settings := a.settings
+	a.l.Unlock()
+	if settings != nil && len(settings.ORIs) > 0 {
+		return settings.ORIs, nil
+	}
+	if oris != nil {
+		return oris, nil
+	}
+	oris, err := a.GetORIs()
+	if err != nil {
+		return nil, err
+	}
+	a.l.Lock()
+	a.oris = oris
+	a.l.Unlock()
+	return oris, nil
+}
+
+// Permits reports whether the logged in user may search an ORI, given as
+// either the internal ORI or the FDID.
+func (a *Agent) Permits(ori string) (bool, error) {
+	oris, err := a.PermittedORIs()
+	if err != nil {
+		return false, err
+	}
+	return slices.ContainsFunc(oris, func(o ORIObj) bool {
+		return o.ORI == ori || o.FDID == ori
+	}), nil
+}
+
+// Identity returns who the agent is logged in as.
+func (a *Agent) Identity() Identity {
+	p := a.GetAuth().Profile
+	id := Identity{Subject: p.Sub, IDP: p.IDP, SessionID: p.Sid}
+	if p.AuthTime > 0 {
+		id.AuthTime = time.Unix(p.AuthTime, 0)
+	}
+	return id
+}
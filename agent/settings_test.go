@@ -0,0 +1,91 @@
+package agent
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/chromedp/cdproto/network"
+)
+
+func Test_UserSettings_Parse(t *testing.T) {
+	s, err := parseUserSettings([]byte(`{"DefaultOri":"26","PageSize":50,"Oris":[{"oriId":"26","value":"04040","agencyName":"Dayville"}]}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if v, ok := s.String("defaultOri"); !ok || v != "26" {
+		t.Errorf("unexpected defaultOri %q", v)
+	}
+	var size int
+	if err := s.Get("PageSize", &size); err != nil || size != 50 {
+		t.Errorf("unexpected PageSize %d, %v", size, err)
+	}
+	if len(s.ORIs) != 1 || s.ORIs[0].FDID != "04040" {
+		t.Errorf("unexpected ORIs %#v", s.ORIs)
+	}
+
+	s, err = parseUserSettings([]byte(`[{"settingName":"Theme","settingValue":"dark"},{"name":"Refresh","value":true}]`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if v, _ := s.String("Theme"); v != "dark" {
+		t.Errorf("unexpected Theme %q", v)
+	}
+	if v, _ := s.String("Refresh"); v != "true" {
+		t.Errorf("unexpected Refresh %q", v)
+	}
+	// Unrecognised lists under the guessed keys leave the ORIs unknown
+	s, err = parseUserSettings([]byte(`{"agencies":[{"id":5,"name":"Dayville"}]}`))
+	if err != nil || s.ORIs != nil {
+		t.Errorf("expected no ORIs from an unrecognised list, got %#v, %v", s.ORIs, err)
+	}
+	if _, err := parseUserSettings([]byte(`<html>`)); err == nil {
+		t.Error("expected parse error")
+	}
+}
+
+func Test_UserSettings_Agent(t *testing.T) {
+	requests := 0
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		if strings.HasSuffix(r.URL.Path, "/api/CadView/GetOrisForClearedCallSearch") {
+			w.Write([]byte(`[{"oriId":"26","value":"04040","agencyName":"Dayville"}]`))
+			return
+		}
+		http.NotFound(w, r)
+	}))
+	defer srv.Close()
+
+	a := &Agent{BaseUrl: srv.URL + "/", Capture: &Capture{}}
+	a.auth.TokenType = "Bearer"
+	a.auth.Profile.Sub = "42"
+	a.auth.Profile.IDP = "local"
+	a.auth.Profile.AuthTime = 1668350754
+
+	// Settings captured during login, without a recognisable ORI list
+	id := network.RequestID("1")
+	a.Capture.event(&network.EventRequestWillBeSent{RequestID: id, Request: &network.Request{URL: srv.URL + "/NewWorld.CadView/api/CadView/GetAllUserSettings"}})
+	a.Capture.event(&network.EventResponseReceived{RequestID: id, Response: &network.Response{MimeType: "application/json"}})
+	a.Capture.event(&network.EventLoadingFinished{RequestID: id})
+	a.Capture.setBody(id, []byte(`{"Theme":"dark","agencies":[{"id":5,"name":"Dayville"}]}`))
+	a.loadUserInfo()
+
+	if s, err := a.UserSettings(); err != nil || s.Settings["Theme"] == nil {
+		t.Fatalf("unexpected settings %#v, %v", s, err)
+	}
+	if ok, err := a.Permits("04040"); err != nil || !ok {
+		t.Errorf("expected 04040 to be permitted, %v", err)
+	}
+	if ok, _ := a.Permits("99"); ok {
+		t.Error("expected 99 not to be permitted")
+	}
+	if requests != 1 {
+		t.Errorf("expected ORIs to be retrieved once, got %d requests", requests)
+	}
+
+	if id := a.Identity(); id.Subject != "42" || id.IDP != "local" || !id.AuthTime.Equal(time.Unix(1668350754, 0)) {
+		t.Errorf("unexpected identity %#v", id)
+	}
+}
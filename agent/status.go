@@ -0,0 +1,123 @@
+package agent
+
+import (
+	"time"
+)
+
+// Browser states reported by Status
+const (
+	BrowserIdle      = "idle"
+	BrowserLoggingIn = "logging_in"
+	BrowserOpen      = "open"
+	BrowserClosed    = "closed"
+	BrowserFailed    = "failed"
+)
+
+// Status describes the session state of an Agent, for health checks.
+type Status struct {
+	// Initialized is set once a login has succeeded.
+	Initialized bool `json:"initialized"`
+	// Browser is the state of the browser used to log in. The browser is
+	// closed once the token has been obtained, unless it is kept alive.
+	Browser string `json:"browser"`
+	// LastLogin is the time of the last login attempt, and LoginError its
+	// error, if any.
+	LastLogin  time.Time `json:"lastLogin,omitzero"`
+	LoginError string    `json:"loginError,omitempty"`
+	// TokenExpires is the expiry time of the session token.
+	TokenExpires time.Time `json:"tokenExpires,omitzero"`
+	// LastPing is the time of the last successful Ping, and PingError the
+	// error from the most recent Ping, if it failed. PingFailingSince is
+	// the time of the first of the current run of failed pings.
+	LastPing         time.Time `json:"lastPing,omitzero"`
+	PingError        string    `json:"pingError,omitempty"`
+	PingFailingSince time.Time `json:"pingFailingSince,omitzero"`
+	// LastAuthorized is the time of the last successful IsAuthorized check,
+	// and AuthorizedError the error from the most recent check, if it
+	// failed.
+	LastAuthorized  time.Time `json:"lastAuthorized,omitzero"`
+	AuthorizedError string    `json:"authorizedError,omitempty"`
+	// Synced holds the time calls were last retrieved for each ORI.
+	Synced map[string]time.Time `json:"synced"`
+}
+
+// Status returns the current session state of the agent.
+func (a *Agent) Status() Status {
+	a.l.Lock()
+	defer a.l.Unlock()
+	st := a.status
+	if st.Browser == "" {
+		st.Browser = BrowserIdle
+	}
+	st.Initialized = a.initialized
+	if a.auth.ExpiresAt > 0 {
+		st.TokenExpires = time.Unix(a.auth.ExpiresAt, 0)
+	}
+	st.Synced = make(map[string]time.Time, len(a.status.Synced))
+	for k, v := range a.status.Synced {
+		st.Synced[k] = v
+	}
+	return st
+}
+
+// TokenExpired determines whether the session token has expired.
+func (a *Agent) TokenExpired() bool {
+	exp := a.GetAuth().ExpiresAt
+	return exp > 0 && time.Now().Unix() >= exp
+}
+
+func (a *Agent) setBrowser(state string) {
+	a.l.Lock()
+	a.status.Browser = state
+	a.l.Unlock()
+}
+
+func (a *Agent) recordLogin(err error) {
+	a.l.Lock()
+	defer a.l.Unlock()
+	a.status.LastLogin = time.Now()
+	a.status.LoginError = errString(err)
+}
+
+func (a *Agent) recordPing(err error) {
+	a.l.Lock()
+	defer a.l.Unlock()
+	switch {
+	case err == nil:
+		a.status.LastPing = time.Now()
+		a.status.PingFailingSince = time.Time{}
+	case a.status.PingFailingSince.IsZero():
+		a.status.PingFailingSince = time.Now()
+	}
+	a.status.PingError = errString(err)
+}
+
+func (a *Agent) recordAuthorized(err error) {
+	a.l.Lock()
+	defer a.l.Unlock()
+	if err == nil {
+		a.status.LastAuthorized = time.Now()
+	}
+	a.status.AuthorizedError = errString(err)
+}
+
+func (a *Agent) recordSync(oris ...string) {
+	a.l.Lock()
+	defer a.l.Unlock()
+	if a.status.Synced == nil {
+		a.status.Synced = map[string]time.Time{}
+	}
+	now := time.Now()
+	for _, ori := range oris {
+		if ori != "" {
+			a.status.Synced[ori] = now
+		}
+	}
+}
+
+func errString(err error) string {
+	if err == nil {
+		return ""
+	}
+	return err.Error()
+}
@@ -2,7 +2,6 @@ package agent
 
 import (
 	"log"
-	"strings"
 	"time"
 )
 
@@ -22,6 +21,12 @@ func FDIDToORI(orimap []ORIObj, fdid string) string {
 	return ""
 }
 
+// ParseDateTime parses a date and time as returned by the CAD system, like
+// "11/13/2022 10:25:54", in the local time zone.
+func ParseDateTime(dt string) (time.Time, error) {
+	return time.ParseInLocation(dateFormat, dt, time.Local)
+}
+
 func parseDate(dt string) time.Time {
 	t, err := time.Parse(dateFormat, dt)
 	if err != nil {
@@ -30,12 +35,3 @@ func parseDate(dt string) time.Time {
 	}
 	return t
 }
-
-// unwantedTraffic determines if a URL should be stored in memory or not
-func unwantedTraffic(url string) bool {
-	return !strings.HasPrefix(url, "http") ||
-		strings.HasSuffix(url, ".css") ||
-		strings.HasSuffix(url, ".js") ||
-		strings.HasSuffix(url, ".svg") ||
-		strings.HasSuffix(url, ".woff2")
-}
@@ -0,0 +1,367 @@
+package agent
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// EventType describes the kind of change reported by a Watcher.
+type EventType string
+
+const (
+	// EventNewCall is raised when a call first appears in the active list.
+	EventNewCall EventType = "new_call"
+	// EventCallUpdated is raised when the fields of an active call change.
+	EventCallUpdated EventType = "call_updated"
+	// EventUnitStatus is raised when a unit is added to a call or its
+	// status changes.
+	EventUnitStatus EventType = "unit_status"
+	// EventNarrative is raised for each narrative entry added to a call
+	// after it was first seen.
+	EventNarrative EventType = "narrative"
+	// EventCallClosed is raised when a call drops out of the active list.
+	EventCallClosed EventType = "call_closed"
+	// EventAlert is raised by alerting rules rather than the Watcher, when
+	// a call or one of its updates matches a rule.
+	EventAlert EventType = "alert"
+)
+
+// Unit statuses derived from the timestamps on a UnitObj
+const (
+	UnitStatusDispatched     = "DISPATCHED"
+	UnitStatusEnroute        = "ENROUTE"
+	UnitStatusStaged         = "STAGED"
+	UnitStatusOnScene        = "ONSCENE"
+	UnitStatusAtPatient      = "AT PATIENT"
+	UnitStatusTransporting   = "TRANSPORTING"
+	UnitStatusAtHospital     = "AT HOSPITAL"
+	UnitStatusDepartHospital = "DEPART HOSPITAL"
+	UnitStatusCleared        = "CLEARED"
+)
+
+// Event is a single change in the active call list.
+type Event struct {
+	Type EventType `json:"type"`
+	Time time.Time `json:"time"`
+	Call CallObj   `json:"call"`
+	// Units and Narratives hold the call's units and narratives at the
+	// time a new call is first seen, if Watcher.Details is set.
+	Units      []UnitObj      `json:"units,omitempty"`
+	Narratives []NarrativeObj `json:"narratives,omitempty"`
+	// Unit and Status are set for EventUnitStatus.
+	Unit   *UnitObj `json:"unit,omitempty"`
+	Status string   `json:"status,omitempty"`
+	// PreviousStatus is the last known status of the unit, if any.
+	PreviousStatus string `json:"previousStatus,omitempty"`
+	// Narrative is set for EventNarrative.
+	Narrative *NarrativeObj `json:"narrative,omitempty"`
+	// Alert is set for EventAlert.
+	Alert *Alert `json:"alert,omitempty"`
+}
+
+// Alert describes why an alerting rule matched a call.
+type Alert struct {
+	// Rule is the name of the rule which matched.
+	Rule string `json:"rule"`
+	// Source is the kind of rule, like "keyword".
+	Source string `json:"source"`
+	// Field is the call field which matched, like "narrative".
+	Field string `json:"field,omitempty"`
+	// Match is the text which matched.
+	Match string `json:"match,omitempty"`
+}
+
+// Watcher polls the active call list of an Agent and reports changes to
+// its subscribers as Events.
+type Watcher struct {
+	// Agent is the agent used to poll the CAD system.
+	Agent *Agent
+	// Interval is the time between polls. Defaults to 30 seconds.
+	Interval time.Duration
+	// Details retrieves units and narratives for every active call on
+	// every poll, which is required for unit status and narrative events.
+	Details bool
+	// SkipExisting suppresses new call events for calls which are already
+	// active when the first poll happens.
+	SkipExisting bool
+
+	calls    map[int64]*watchState
+	handlers []func(Event)
+	polled   bool
+	lastPoll time.Time
+
+	cancelled atomic.Bool
+	l         sync.Mutex
+}
+
+type watchState struct {
+	call CallObj
+	// units is keyed by unitKey, as the same unit may be assigned to a
+	// call more than once.
+	units      map[string]UnitObj
+	narratives map[string]bool
+	// narrativesLoaded is set once the call's narratives have been
+	// retrieved, so that a failed first fetch does not report every
+	// existing narrative as new on the next poll.
+	narrativesLoaded bool
+}
+
+// unitKey identifies a unit assignment on a call by its record ID,
+// falling back to the unit number.
+func unitKey(u UnitObj) string {
+	if u.ID != "" {
+		return u.ID
+	}
+	return u.UnitNumber
+}
+
+// Subscribe registers a function to be called for every Event. Handlers
+// are called in order from the polling goroutine.
+func (w *Watcher) Subscribe(fn func(Event)) {
+	w.l.Lock()
+	defer w.l.Unlock()
+	w.handlers = append(w.handlers, fn)
+}
+
+// Calls returns the active calls known to the watcher as of the last poll.
+func (w *Watcher) Calls() []CallObj {
+	w.l.Lock()
+	defer w.l.Unlock()
+	out := make([]CallObj, 0, len(w.calls))
+	for _, st := range w.calls {
+		out = append(out, st.call)
+	}
+	return out
+}
+
+// LastPoll returns the time of the last successful poll, or the zero time
+// if there has not been one.
+func (w *Watcher) LastPoll() time.Time {
+	w.l.Lock()
+	defer w.l.Unlock()
+	return w.lastPoll
+}
+
+// CallUnits returns the units last seen on an active call. It is only
+// populated when Details is set.
+func (w *Watcher) CallUnits(callID int64) []UnitObj {
+	w.l.Lock()
+	defer w.l.Unlock()
+	st, ok := w.calls[callID]
+	if !ok {
+		return []UnitObj{}
+	}
+	out := make([]UnitObj, 0, len(st.units))
+	for _, u := range st.units {
+		out = append(out, u)
+	}
+	return out
+}
+
+// Start polls in the background until Stop is called.
+func (w *Watcher) Start() {
+	interval := w.Interval
+	if interval <= 0 {
+		interval = 30 * time.Second
+	}
+	go func() {
+		for {
+			if w.Agent.Debug {
+				log.Printf("Watcher: Poll()")
+			}
+			start := time.Now()
+			_, err := w.Poll()
+			if errors.Is(err, ErrNotAuthorized) {
+				err = w.Agent.ReauthorizeSince(start)
+			}
+			if err != nil {
+				log.Printf("ERR: Watcher: %s", err.Error())
+			}
+			for i := time.Duration(0); i < interval; i += time.Second {
+				time.Sleep(time.Second)
+				if w.cancelled.Load() {
+					return
+				}
+			}
+		}
+	}()
+}
+
+// Stop ends background polling started with Start.
+func (w *Watcher) Stop() {
+	w.cancelled.Store(true)
+}
+
+// Poll retrieves the active call list once, dispatches any resulting
+// Events to subscribers and returns them.
+func (w *Watcher) Poll() ([]Event, error) {
+	calls, err := w.Agent.ActiveCalls()
+	if err != nil {
+		return []Event{}, err
+	}
+
+	type details struct {
+		units        []UnitObj
+		narratives   []NarrativeObj
+		narrativesOK bool
+	}
+	detailMap := map[int64]details{}
+	if w.Details {
+		for _, c := range calls {
+			callId := fmt.Sprintf("%d", c.CallID)
+			var d details
+			d.units, err = w.Agent.GetCallUnits(callId)
+			if err != nil {
+				log.Printf("ERR: Watcher: GetCallUnits(%s): %s", callId, err.Error())
+			}
+			d.narratives, err = w.Agent.GetCallNarratives(callId)
+			if err != nil {
+				log.Printf("ERR: Watcher: GetCallNarratives(%s): %s", callId, err.Error())
+			}
+			d.narrativesOK = err == nil
+			detailMap[c.CallID] = d
+		}
+	}
+
+	now := time.Now()
+	events := []Event{}
+
+	w.l.Lock()
+	if w.calls == nil {
+		w.calls = map[int64]*watchState{}
+	}
+	seen := map[int64]bool{}
+	for _, c := range calls {
+		seen[c.CallID] = true
+		d := detailMap[c.CallID]
+
+		st, ok := w.calls[c.CallID]
+		if !ok {
+			st = &watchState{
+				call:       c,
+				units:      map[string]UnitObj{},
+				narratives: map[string]bool{},
+			}
+			for _, u := range d.units {
+				st.units[unitKey(u)] = u
+			}
+			for _, n := range d.narratives {
+				st.narratives[n.ID] = true
+			}
+			st.narrativesLoaded = d.narrativesOK
+			w.calls[c.CallID] = st
+			if !w.polled && w.SkipExisting {
+				continue
+			}
+			events = append(events, Event{
+				Type:       EventNewCall,
+				Time:       now,
+				Call:       c,
+				Units:      d.units,
+				Narratives: d.narratives,
+			})
+			continue
+		}
+
+		if callChanged(st.call, c) {
+			events = append(events, Event{Type: EventCallUpdated, Time: now, Call: c})
+		}
+		st.call = c
+
+		for _, u := range d.units {
+			prev, ok := st.units[unitKey(u)]
+			status := UnitStatus(u)
+			st.units[unitKey(u)] = u
+			if ok && UnitStatus(prev) == status {
+				continue
+			}
+			ev := Event{Type: EventUnitStatus, Time: now, Call: c, Status: status}
+			unit := u
+			ev.Unit = &unit
+			if ok {
+				ev.PreviousStatus = UnitStatus(prev)
+			}
+			events = append(events, ev)
+		}
+
+		for _, n := range d.narratives {
+			if st.narratives[n.ID] {
+				continue
+			}
+			st.narratives[n.ID] = true
+			if !st.narrativesLoaded {
+				// Already on the call when it was first seen
+				continue
+			}
+			narrative := n
+			events = append(events, Event{Type: EventNarrative, Time: now, Call: c, Narrative: &narrative})
+		}
+		if d.narrativesOK {
+			st.narrativesLoaded = true
+		}
+	}
+	for id, st := range w.calls {
+		if seen[id] {
+			continue
+		}
+		delete(w.calls, id)
+		events = append(events, Event{Type: EventCallClosed, Time: now, Call: st.call})
+	}
+	w.polled = true
+	w.lastPoll = now
+	handlers := append([]func(Event){}, w.handlers...)
+	w.l.Unlock()
+
+	for _, ev := range events {
+		for _, fn := range handlers {
+			fn(ev)
+		}
+	}
+
+	return events, nil
+}
+
+// UnitStatus determines the most recent status of a unit from its
+// timestamps.
+func UnitStatus(u UnitObj) string {
+	switch {
+	case u.ClearDateTime != "":
+		return UnitStatusCleared
+	case u.DepartHospitalDateTime != "":
+		return UnitStatusDepartHospital
+	case u.AtHospitalDateTime != "":
+		return UnitStatusAtHospital
+	case u.TransportDateTime != "":
+		return UnitStatusTransporting
+	case u.AtPatientDateTime != "":
+		return UnitStatusAtPatient
+	case u.ArriveDateTime != "":
+		return UnitStatusOnScene
+	case u.StagedDateTime != "":
+		return UnitStatusStaged
+	case u.EnrouteDateTime != "":
+		return UnitStatusEnroute
+	default:
+		return UnitStatusDispatched
+	}
+}
+
+// callChanged determines whether the user visible fields of a call have
+// changed between polls.
+func callChanged(a, b CallObj) bool {
+	return a.CallType != b.CallType ||
+		a.CallPriority != b.CallPriority ||
+		a.CallStatus != b.CallStatus ||
+		a.Location != b.Location ||
+		a.NatureOfCall != b.NatureOfCall ||
+		a.PrimaryUnit != b.PrimaryUnit ||
+		a.ArrivedDateTime != b.ArrivedDateTime ||
+		a.DispatchedDateTime != b.DispatchedDateTime ||
+		a.ClosedFlag != b.ClosedFlag ||
+		a.LatitudeY != b.LatitudeY ||
+		a.LongitudeX != b.LongitudeX
+}
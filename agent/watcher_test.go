@@ -0,0 +1,194 @@
+package agent
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+)
+
+// fakeCAD is a local stand-in for the CadView API used by tests which do
+// not need a live instance.
+type fakeCAD struct {
+	calls      []CallObj
+	units      map[string][]UnitObj
+	narratives map[string][]NarrativeObj
+	// failNarratives makes GetCallNarratives return a malformed response.
+	failNarratives bool
+	l              sync.Mutex
+}
+
+func (f *fakeCAD) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	f.l.Lock()
+	defer f.l.Unlock()
+	var out any
+	id := r.URL.Query().Get("id")
+	switch {
+	case strings.HasSuffix(r.URL.Path, "/api/Call/GetActiveCalls"):
+		out = f.calls
+	case strings.HasSuffix(r.URL.Path, "/api/Call/GetCallUnits"):
+		out = f.units[id]
+	case strings.HasSuffix(r.URL.Path, "/api/Call/GetCallNarratives"):
+		if f.failNarratives {
+			w.WriteHeader(http.StatusInternalServerError)
+			w.Write([]byte("{"))
+			return
+		}
+		out = f.narratives[id]
+	default:
+		out = true
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(out)
+}
+
+func newFakeAgent(t *testing.T, f *fakeCAD) *Agent {
+	srv := httptest.NewServer(f)
+	t.Cleanup(srv.Close)
+	a := &Agent{BaseUrl: srv.URL + "/"}
+	a.SetAuth(OidcObj{TokenType: "Bearer", AccessToken: "test"})
+	a.initialized = true
+	return a
+}
+
+func Test_Watcher_Poll(t *testing.T) {
+	f := &fakeCAD{
+		calls: []CallObj{{CallID: 1, CallType: "Structure Fire", Location: "120 FREEDLEY RD, Pomfret"}},
+		units: map[string][]UnitObj{
+			"1": {{UnitNumber: "ENG70", DispatchDateTime: "11/13/2022 12:19:46"}},
+		},
+		narratives: map[string][]NarrativeObj{},
+	}
+	w := &Watcher{Agent: newFakeAgent(t, f), Details: true}
+
+	events, err := w.Poll()
+	if err != nil {
+		t.Fatalf("ERR: Poll: %s", err.Error())
+	}
+	if len(events) != 1 || events[0].Type != EventNewCall || len(events[0].Units) != 1 {
+		t.Fatalf("expected one new call event with units, got %#v", events)
+	}
+
+	f.l.Lock()
+	f.units["1"][0].EnrouteDateTime = "11/13/2022 12:20:00"
+	f.units["1"] = append(f.units["1"], UnitObj{UnitNumber: "TANKER70", DispatchDateTime: "11/13/2022 12:21:00"})
+	f.narratives["1"] = []NarrativeObj{{ID: "10", Narrative: "working fire"}}
+	f.l.Unlock()
+
+	events, err = w.Poll()
+	if err != nil {
+		t.Fatalf("ERR: Poll: %s", err.Error())
+	}
+	got := map[string]string{}
+	for _, ev := range events {
+		switch ev.Type {
+		case EventUnitStatus:
+			got[ev.Unit.UnitNumber] = ev.Status
+		case EventNarrative:
+			got["narrative"] = ev.Narrative.Narrative
+		}
+	}
+	if got["ENG70"] != UnitStatusEnroute || got["TANKER70"] != UnitStatusDispatched || got["narrative"] != "working fire" {
+		t.Fatalf("unexpected events: %#v", got)
+	}
+
+	f.l.Lock()
+	f.calls = []CallObj{}
+	f.l.Unlock()
+
+	events, err = w.Poll()
+	if err != nil {
+		t.Fatalf("ERR: Poll: %s", err.Error())
+	}
+	if len(events) != 1 || events[0].Type != EventCallClosed {
+		t.Fatalf("expected one closed call event, got %#v", events)
+	}
+}
+
+func Test_Watcher_Details(t *testing.T) {
+	f := &fakeCAD{
+		calls: []CallObj{{CallID: 1, CallType: "MVA"}},
+		units: map[string][]UnitObj{
+			// The same unit assigned twice to one call
+			"1": {
+				{ID: "100", UnitNumber: "ENG70", DispatchDateTime: "11/13/2022 12:19:46", ClearDateTime: "11/13/2022 12:25:00"},
+				{ID: "101", UnitNumber: "ENG70", DispatchDateTime: "11/13/2022 12:30:00"},
+			},
+		},
+		narratives: map[string][]NarrativeObj{
+			"1": {{ID: "10", Narrative: "two cars"}},
+		},
+		failNarratives: true,
+	}
+	w := &Watcher{Agent: newFakeAgent(t, f), Details: true}
+
+	if _, err := w.Poll(); err != nil {
+		t.Fatalf("ERR: Poll: %s", err.Error())
+	}
+
+	// The narrative already on the call is not reported once it can be
+	// retrieved, nor do the two assignments of ENG70 flap
+	f.l.Lock()
+	f.failNarratives = false
+	f.l.Unlock()
+	events, err := w.Poll()
+	if err != nil {
+		t.Fatalf("ERR: Poll: %s", err.Error())
+	}
+	if len(events) != 0 {
+		t.Fatalf("expected no events, got %#v", events)
+	}
+
+	f.l.Lock()
+	f.narratives["1"] = append(f.narratives["1"], NarrativeObj{ID: "11", Narrative: "one patient"})
+	f.l.Unlock()
+	events, err = w.Poll()
+	if err != nil {
+		t.Fatalf("ERR: Poll: %s", err.Error())
+	}
+	if len(events) != 1 || events[0].Type != EventNarrative || events[0].Narrative.ID != "11" {
+		t.Fatalf("expected one narrative event, got %#v", events)
+	}
+	if units := w.CallUnits(1); len(units) != 2 {
+		t.Fatalf("expected both assignments of ENG70, got %#v", units)
+	}
+}
+
+// zoneTagger tags calls east of longitude -72 as "east".
+type zoneTagger struct{}
+
+func (zoneTagger) Tag(calls []CallObj) {
+	for k := range calls {
+		calls[k].Zones = nil
+		if calls[k].LongitudeX > -72 {
+			calls[k].Zones = []string{"east"}
+		}
+	}
+}
+
+func Test_Watcher_Moved(t *testing.T) {
+	f := &fakeCAD{
+		calls: []CallObj{{CallID: 1, CallType: "MVA", LatitudeY: 41.87, LongitudeX: -72.01}},
+	}
+	a := newFakeAgent(t, f)
+	a.Tagger = zoneTagger{}
+	w := &Watcher{Agent: a}
+
+	if _, err := w.Poll(); err != nil {
+		t.Fatalf("ERR: Poll: %s", err.Error())
+	}
+
+	// Moving a call reports it with its new zones
+	f.l.Lock()
+	f.calls[0].LongitudeX = -71.95
+	f.l.Unlock()
+	events, err := w.Poll()
+	if err != nil {
+		t.Fatalf("ERR: Poll: %s", err.Error())
+	}
+	if len(events) != 1 || events[0].Type != EventCallUpdated || len(events[0].Call.Zones) != 1 {
+		t.Fatalf("expected one updated call event in the east zone, got %#v", events)
+	}
+}